@@ -2,36 +2,56 @@ import { createContext, useContext, useEffect } from "react";
 import { Backdrop, CircularProgress } from "@mui/material";
 import { useQuery } from "@tanstack/react-query"
 import { Navigate } from "react-router-dom";
+import { getDefaultStore } from "jotai";
 
 import { User } from "../model";
 import { useAuthFetch } from "../hooks/api";
 import { UserSettingLoader } from "./UserSettingProvider";
-import { useAccessToken } from "../hooks/store";
+import { refreshTokenAtom, useAccessToken, useRefreshToken } from "../hooks/store";
 import { RESET } from "jotai/utils";
 
 const ctx = createContext<{
   user: User
 }>({} as any)
 
+// access tokens live for 15 minutes, a new pair is fetched well before that
+const TOKEN_STALE_TIME = 5 * 60 * 1000
+const TOKEN_REFRESH_INTERVAL = 10 * 60 * 1000
+
 const AuthProvider: React.FC<{
   children?: React.ReactNode
 }> = ({ children }) => {
   const authFetch = useAuthFetch()
   const { data, error, isLoading, dataUpdatedAt } = useQuery({
-    queryKey: ["auth", "refresh_token"],
+    queryKey: ["auth", "token"],
     queryFn: () => {
+      // read at call time, every exchange rotates the refresh token
+      const refreshToken = getDefaultStore().get(refreshTokenAtom)
+      if (!refreshToken) {
+        throw new Error("no refresh token")
+      }
       return authFetch<{
         token: string;
+        refreshToken: string;
         user: User;
-      }>(`/api/auth/refresh_token`)
+      }>(`/api/auth/token`, {
+        method: "POST",
+        body: JSON.stringify({ refreshToken }),
+      })
     },
+    staleTime: TOKEN_STALE_TIME,
+    refetchInterval: TOKEN_REFRESH_INTERVAL,
+    // a retried exchange would replay a rotated token and revoke the session
+    retry: false,
   })
   const [accessToken, setAccessToken] = useAccessToken()
+  const [, setRefreshToken] = useRefreshToken()
 
   useEffect(() => {
     if (data?.token) {
       console.log(`refresh_token`, new Date(dataUpdatedAt).toLocaleString());
       setAccessToken(data.token)
+      setRefreshToken(data.refreshToken)
     }
   }, [data?.token])
 
@@ -43,6 +63,7 @@ const AuthProvider: React.FC<{
     )
   } else if (error || !data?.token || !accessToken) {
     setAccessToken(RESET)
+    setRefreshToken(RESET)
     return (
       <Navigate to="/login" />
     )
@@ -59,4 +80,3 @@ const AuthProvider: React.FC<{
 export default AuthProvider;
 
 export const useAuth = () => useContext(ctx)
-
@@ -3,9 +3,8 @@ import MenuIcon from '@mui/icons-material/Menu';
 import { useLocation, useNavigate } from 'react-router-dom';
 import { useTranslation } from 'react-i18next';
 import ThemeSwitch from './ThemeSwitch';
-import { useAccessToken } from '../hooks/store';
+import { useLogout } from '../hooks/api';
 import { atom, useAtom, useAtomValue, useSetAtom } from 'jotai';
-import { RESET } from 'jotai/utils';
 import ArrowBackIosNewIcon from '@mui/icons-material/ArrowBackIosNew';
 import React, { useEffect } from 'react';
 
@@ -20,7 +19,7 @@ export default function NavBar() {
     setOpen(newOpen);
   };
   const navigate = useNavigate();
-  const [, setAccessToken] = useAccessToken()
+  const logout = useLogout()
   const theme = useTheme();
   const fullScreen = useMediaQuery(theme.breakpoints.up('md'));
   const { t } = useTranslation()
@@ -46,8 +45,7 @@ export default function NavBar() {
 
         <ListItem key="logout" disablePadding>
           <ListItemButton onClick={() => {
-            setAccessToken(RESET)
-            // navigate("/login")
+            logout()
           }}>
             <ListItemText primary={t("logout")} />
           </ListItemButton>
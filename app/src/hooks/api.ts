@@ -1,5 +1,6 @@
 import { useCallback } from "react"
-import { useAccessToken } from "./store"
+import { RESET } from "jotai/utils"
+import { useAccessToken, useRefreshToken } from "./store"
 
 export const useAuthFetch = () => {
   const [token] = useAccessToken()
@@ -31,3 +32,20 @@ export const useAuthFetch = () => {
     return res.blob()
   }), [token])
 }
+
+// useLogout revokes the session on the server and forgets its tokens
+export const useLogout = () => {
+  const [, setAccessToken] = useAccessToken()
+  const [refreshToken, setRefreshToken] = useRefreshToken()
+
+  return useCallback(() => {
+    if (refreshToken) {
+      fetch(`/api/auth/logout`, {
+        method: "POST",
+        body: JSON.stringify({ refreshToken }),
+      }).catch((err) => console.error(`logout`, err))
+    }
+    setAccessToken(RESET)
+    setRefreshToken(RESET)
+  }, [refreshToken])
+}
@@ -1,21 +1,30 @@
 import { useAtom } from 'jotai'
 import { atomWithStorage } from 'jotai/utils'
 
-export const accessTokenAtom = atomWithStorage<string | null>("access_token", null, {
-  getItem: (key) => localStorage.getItem(key),
-  setItem: (key, value) => {
-    console.log(`setAccessToken`, key, value)
+const tokenStorage = {
+  getItem: (key: string) => localStorage.getItem(key),
+  setItem: (key: string, value: string | null) => {
+    console.log(`setToken`, key)
     if (!value) {
       return localStorage.removeItem(key)
     }
     return localStorage.setItem(key, value)
   },
-  removeItem: (key) => localStorage.removeItem(key),
-}, {
+  removeItem: (key: string) => localStorage.removeItem(key),
+}
+
+export const accessTokenAtom = atomWithStorage<string | null>("access_token", null, tokenStorage, {
   getOnInit: true
 })
 
 export const useAccessToken = () => useAtom(accessTokenAtom);
 
+// refreshTokenAtom holds the long lived token exchanged at /api/auth/token for a new token pair
+export const refreshTokenAtom = atomWithStorage<string | null>("refresh_token", null, tokenStorage, {
+  getOnInit: true
+})
+
+export const useRefreshToken = () => useAtom(refreshTokenAtom);
+
 export const lastUsedCurrencyAtom = atomWithStorage<string | null>("last_used_currency", null, undefined, { getOnInit: true })
-export const useLastUsedCurrency = () => useAtom(lastUsedCurrencyAtom);
\ No newline at end of file
+export const useLastUsedCurrency = () => useAtom(lastUsedCurrencyAtom);
@@ -18,6 +18,8 @@ import InviteDialog from './routes/InviteDialog';
 import Expense from './routes/Expense';
 import GroupSettingDialog from './routes/GroupSettingDialog';
 import SetToken from './routes/SetToken';
+import VerifyEmail from './routes/VerifyEmail';
+import ResetPassword from './routes/ResetPassword';
 import ExpenseEditDialog from './routes/ExpenseEditDialog';
 
 import "./i18n";
@@ -33,6 +35,8 @@ const router = createBrowserRouter(
     <>
       <Route path="/login" element={<Login />} index />
       <Route path="/set-token" element={<SetToken />} index />
+      <Route path="/verify-email" element={<VerifyEmail />} />
+      <Route path="/reset-password" element={<ResetPassword />} />
       <Route path="/" element={<AuthProvider><Layout /></AuthProvider>}>
         <Route path="friends" element={<div>My Friends</div>}>
 
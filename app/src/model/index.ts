@@ -22,7 +22,10 @@ export interface ExpenseAttachment {
   id: string
   filename: string
   size: number;
+  thumbnailSize: number;
   mime: string;
+  url: string;
+  thumbnailUrl?: string;
   createAt: string;
   updateAt: string;
 }
@@ -217,7 +217,7 @@ const Attachments: React.FC<{}> = () => {
               <Stack key={attachment.id} direction="row" justifyContent="space-between" alignItems="center">
                 <a
                   target="_blank"
-                  href={`/static/photo/${attachment.id}`}
+                  href={attachment.url}
                 >
                   {attachment.filename}
                 </a>
@@ -233,22 +233,12 @@ const Attachments: React.FC<{}> = () => {
 const Photo: React.FC<{
   photo: ExpenseAttachment
 }> = ({ photo }) => {
-  const authFetch = useAuthFetch()
-  const { data } = useQuery({
-    queryKey: ["static", "photo", photo.id],
-    queryFn: () => {
-      return authFetch(`/static/photo/${photo.id}`, {
-        handleResponse: (response) => response.blob().then((value) => URL.createObjectURL(value))
-      })
-    }
-  })
-
   return (
-    <PhotoView src={data} key={photo.id}>
+    <PhotoView src={photo.url} key={photo.id}>
       <img
         key={photo.id}
         data-photoid={photo.id}
-        src={data}
+        src={photo.thumbnailUrl ?? photo.url}
         width={100}
         height={100}
         style={{
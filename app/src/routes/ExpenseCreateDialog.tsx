@@ -1,4 +1,4 @@
-import { Autocomplete, Checkbox, CircularProgress, Dialog, DialogActions, DialogContent, DialogTitle, IconButton, InputAdornment, MenuItem, Radio, Select, Stack, Table, TableBody, TableCell, TableContainer, TableHead, TableRow, TextField, Typography, darken, lighten, styled, useMediaQuery, useTheme } from "@mui/material";
+import { Autocomplete, Button, Checkbox, CircularProgress, Dialog, DialogActions, DialogContent, DialogTitle, IconButton, InputAdornment, MenuItem, Radio, Select, Stack, Table, TableBody, TableCell, TableContainer, TableHead, TableRow, TextField, Typography, darken, lighten, styled, useMediaQuery, useTheme } from "@mui/material";
 import { Controller, FormProvider, useForm, useFormContext } from "react-hook-form";
 import { useNavigate, useParams } from "react-router-dom";
 import { useMutation, useQuery, useQueryClient } from "@tanstack/react-query";
@@ -10,8 +10,9 @@ import { DatePicker } from '@mui/x-date-pickers/DatePicker';
 import dayjs, { Dayjs } from "dayjs";
 import { LoadingButton } from "@mui/lab";
 import CloseIcon from '@mui/icons-material/Close';
-import { Currency, User } from "../model";
-import { useEffect } from "react";
+import ReceiptIcon from '@mui/icons-material/Receipt';
+import { Currency, Expense, User } from "../model";
+import { useEffect, useRef, useState } from "react";
 import { useAuth } from "../components/AuthProvider";
 import NumericFormatCustom from "../components/NumericFormat";
 import FormattedAmount from "../components/FormattedAmount";
@@ -38,6 +39,15 @@ interface SplitUser extends User {
   // paid: boolean;
 }
 
+interface Receipt {
+  status: "done" | "skipped" | "failed";
+  suggestion: {
+    total?: string;
+    date?: string;
+    merchant?: string;
+  };
+}
+
 export default function ExpenseCreateDialog() {
   const navigate = useNavigate()
   const { groupId } = useParams<{ groupId: string }>()
@@ -46,9 +56,11 @@ export default function ExpenseCreateDialog() {
   const { t } = useTranslation()
   const queryClient = useQueryClient()
   const authFetch = useAuthFetch()
+  // the scanned receipt is attached to the expense once it is created
+  const [receipt, setReceipt] = useState<File | null>(null)
   const { mutateAsync, isPending } = useMutation({
     mutationFn: async (values: ExpenseFormValues) => {
-      return authFetch(`/api/group/${groupId}/expense`, {
+      return authFetch<Expense>(`/api/group/${groupId}/expense`, {
         method: 'POST',
         body: JSON.stringify({
           description: values.description,
@@ -73,7 +85,17 @@ export default function ExpenseCreateDialog() {
   const handleSubmit = async (values: ExpenseFormValues) => {
     try {
       console.debug(`submit`, values)
-      await mutateAsync(values)
+      const expense = await mutateAsync(values)
+      if (receipt) {
+        const formData = new FormData()
+        formData.append("file", receipt)
+        await authFetch(`/api/expense/${expense.id}/attachment`, {
+          method: 'POST',
+          body: formData,
+        }).catch(() => {
+          enqueueSnackbar(t("expense.create_dialog.receipt_upload_failed"), { variant: 'warning' })
+        })
+      }
       navigate("..")
       enqueueSnackbar(`expense created`, { variant: 'success' })
     } catch (err) {
@@ -102,6 +124,7 @@ export default function ExpenseCreateDialog() {
 
           <DialogContent dividers>
             <Stack gap={2}>
+              {groupId ? <ReceiptField groupId={groupId} receipt={receipt} onReceipt={setReceipt} /> : null}
               <CurrencyField />
               <AmountField />
               <Controller
@@ -150,6 +173,89 @@ export default function ExpenseCreateDialog() {
 }
 
 
+// ReceiptField scans a receipt and fills the fields it suggests
+const ReceiptField: React.FC<{
+  groupId: string
+  receipt: File | null
+  onReceipt: (file: File | null) => void
+}> = ({ groupId, receipt, onReceipt }) => {
+  const { setValue } = useFormContext<ExpenseFormValues>()
+  const { t } = useTranslation()
+  const { enqueueSnackbar } = useSnackbar()
+  const authFetch = useAuthFetch()
+  const inputRef = useRef<HTMLInputElement>(null)
+  const { mutateAsync, isPending } = useMutation({
+    mutationFn: (file: File) => {
+      const formData = new FormData()
+      formData.append("file", file)
+      return authFetch<Receipt>(`/api/group/${groupId}/receipt`, {
+        method: 'POST',
+        body: formData,
+      })
+    },
+  })
+
+  const handleFile = async (file: File | undefined) => {
+    if (!file) {
+      return
+    }
+    try {
+      const { status, suggestion } = await mutateAsync(file)
+      onReceipt(file)
+      if (status !== "done" || !(suggestion.total || suggestion.date || suggestion.merchant)) {
+        enqueueSnackbar(t("expense.create_dialog.receipt_not_read"), { variant: 'info' })
+        return
+      }
+      const options = { shouldValidate: true, shouldDirty: true }
+      if (suggestion.total) {
+        setValue("amount", suggestion.total, options)
+      }
+      if (suggestion.merchant) {
+        setValue("description", suggestion.merchant, options)
+      }
+      if (suggestion.date) {
+        // the date of the receipt, whatever the time zone of the server
+        setValue("date", dayjs(suggestion.date.slice(0, 10)), options)
+      }
+    } catch (err) {
+      enqueueSnackbar((err as Error).message, { variant: 'error' })
+    }
+  }
+
+  return (
+    <Stack direction="row" alignItems="center" gap={1}>
+      <input
+        ref={inputRef}
+        type="file"
+        accept="image/*,application/pdf"
+        hidden
+        onChange={(e) => {
+          handleFile(e.target.files?.[0])
+          e.target.value = ""
+        }}
+      />
+      <LoadingButton
+        variant="outlined"
+        loading={isPending}
+        startIcon={<ReceiptIcon />}
+        onClick={() => inputRef.current?.click()}
+      >
+        {t("expense.create_dialog.scan_receipt")}
+      </LoadingButton>
+      {receipt ? (
+        <>
+          <Typography variant="body2" color="text.secondary" noWrap sx={{ flex: 1 }}>
+            {receipt.name}
+          </Typography>
+          <Button size="small" onClick={() => onReceipt(null)}>
+            {t("expense.create_dialog.remove_receipt")}
+          </Button>
+        </>
+      ) : null}
+    </Stack>
+  )
+}
+
 const CurrencyField = () => {
   const { control } = useFormContext<ExpenseFormValues>()
   const authFetch = useAuthFetch()
@@ -9,7 +9,7 @@ import { LoadingButton } from "@mui/lab";
 
 import LoginIcon from '@mui/icons-material/Login';
 import GoogleIcon from '@mui/icons-material/Google';
-import { useAccessToken } from "../hooks/store";
+import { useAccessToken, useRefreshToken } from "../hooks/store";
 
 interface LoginForm {
   username: string;
@@ -19,11 +19,12 @@ interface LoginForm {
 const Login = () => {
 
   const [accessToken, setAccessToken] = useAccessToken()
+  const [, setRefreshToken] = useRefreshToken()
   const { enqueueSnackbar } = useSnackbar()
   const authFetch = useAuthFetch()
   const { mutateAsync, isPending } = useMutation({
     mutationFn: (data: LoginForm) => {
-      return authFetch<{ token: string; user: User; }>(
+      return authFetch<{ token: string; refreshToken: string; user: User; }>(
         `/api/auth/login`, {
         method: 'POST',
         body: JSON.stringify(data)
@@ -40,8 +41,9 @@ const Login = () => {
 
   const onSubmit = async (data: LoginForm) => {
     try {
-      const { token } = await mutateAsync(data)
+      const { token, refreshToken } = await mutateAsync(data)
       setAccessToken(token)
+      setRefreshToken(refreshToken)
       redirect('/')
     } catch (err) {
       enqueueSnackbar((err as Error).message, { variant: 'error' })
@@ -0,0 +1,110 @@
+import { Stack, TextField, Typography } from "@mui/material";
+import { LoadingButton } from "@mui/lab";
+import { useMutation } from "@tanstack/react-query";
+import { Controller, useForm } from "react-hook-form";
+import { useNavigate, useSearchParams } from "react-router-dom";
+import { useSnackbar } from "notistack";
+import { useTranslation } from "react-i18next";
+import { RESET } from "jotai/utils";
+import { useAuthFetch } from "../hooks/api";
+import { useAccessToken, useRefreshToken } from "../hooks/store";
+
+interface ResetPasswordForm {
+  password: string;
+  confirmPassword: string;
+}
+
+// ResetPassword sets a new password with the token of the link in the password reset email
+const ResetPassword = () => {
+  const { t } = useTranslation()
+  const navigate = useNavigate()
+  const { enqueueSnackbar } = useSnackbar()
+  const [searchParams] = useSearchParams()
+  const token = searchParams.get("token")
+  const [, setAccessToken] = useAccessToken()
+  const [, setRefreshToken] = useRefreshToken()
+  const authFetch = useAuthFetch()
+  const { mutateAsync, isPending } = useMutation({
+    mutationFn: (password: string) => authFetch(`/api/auth/password_reset/confirm`, {
+      method: "POST",
+      body: JSON.stringify({ token, password }),
+    }),
+  })
+
+  const { control, handleSubmit, getValues, formState } = useForm<ResetPasswordForm>({
+    mode: "onChange",
+    defaultValues: {
+      password: "",
+      confirmPassword: "",
+    }
+  })
+
+  const onSubmit = async (data: ResetPasswordForm) => {
+    try {
+      await mutateAsync(data.password)
+    } catch (err) {
+      enqueueSnackbar(t("reset_password.failed"), { variant: "error" })
+      return
+    }
+    // the reset ends every session, this one included
+    setAccessToken(RESET)
+    setRefreshToken(RESET)
+    enqueueSnackbar(t("reset_password.success_message"), { variant: "success" })
+    navigate("/login", { replace: true })
+  }
+
+  return (
+    <form onSubmit={handleSubmit(onSubmit)}>
+      <Stack sx={{
+        display: "flex",
+        justifyContent: "center",
+        alignItems: "center",
+        height: "100vh",
+      }}>
+        <Stack gap={2}>
+          <Typography variant="h6">{t("reset_password.title")}</Typography>
+          {!token ? (
+            <Typography>{t("reset_password.failed")}</Typography>
+          ) : (
+            <>
+              <Controller
+                name="password"
+                control={control}
+                rules={{ required: true }}
+                render={({ field }) => <TextField {...field} type="password" placeholder={t("reset_password.password")} autoComplete="new-password" autoFocus />}
+              />
+              <Controller
+                name="confirmPassword"
+                control={control}
+                rules={{
+                  required: true,
+                  validate: (value) => value === getValues("password") || t("reset_password.mismatch"),
+                }}
+                render={({ field, fieldState }) => (
+                  <TextField
+                    {...field}
+                    type="password"
+                    placeholder={t("reset_password.confirm_password")}
+                    autoComplete="new-password"
+                    error={!!fieldState.error?.message}
+                    helperText={fieldState.error?.message}
+                  />
+                )}
+              />
+              <LoadingButton
+                type="submit"
+                variant="outlined"
+                disabled={!formState.isValid || isPending}
+                loading={isPending}
+              >
+                {t("reset_password.submit_button")}
+              </LoadingButton>
+            </>
+          )}
+        </Stack>
+      </Stack>
+    </form>
+  )
+}
+
+export default ResetPassword;
@@ -1,20 +1,27 @@
-
 import { Navigate } from "react-router-dom";
-import { useAccessToken } from "../hooks/store";
-import { useEffect } from "react";
+import { useAccessToken, useRefreshToken } from "../hooks/store";
+import { useEffect, useState } from "react";
 
+// SetToken stores the tokens the server hands over in the fragment after a browser based login,
+// navigating away replaces the entry so they do not stay in the history
 const SetToken = () => {
-  let urlParams = new URLSearchParams(window.location.search);
   const setAccessToken = useAccessToken()[1]
+  const setRefreshToken = useRefreshToken()[1]
+  const [stored, setStored] = useState(false)
   useEffect(() => {
-    console.debug("urlParams", urlParams)
-    if (urlParams.get('access_token')) {
-      setAccessToken(urlParams.get('access_token'))
+    const params = new URLSearchParams(window.location.hash.slice(1))
+    if (params.get('access_token') && params.get('refresh_token')) {
+      setAccessToken(params.get('access_token'))
+      setRefreshToken(params.get('refresh_token'))
     }
-  }, [urlParams])
+    setStored(true)
+  }, [])
+  if (!stored) {
+    return null
+  }
   return (
-    <Navigate to="/login" />
+    <Navigate to="/" replace />
   )
 }
 
-export default SetToken;
\ No newline at end of file
+export default SetToken;
@@ -1,11 +1,10 @@
 import { List, ListItem, ListItemButton, ListItemText, Stack } from "@mui/material";
 import ThemeSwitch from "../components/ThemeSwitch";
-import { useAccessToken } from "../hooks/store";
+import { useLogout } from "../hooks/api";
 import { useTranslation } from "react-i18next";
-import { RESET } from "jotai/utils";
 
 export default function Settings() {
-  const [, setAccessToken] = useAccessToken()
+  const logout = useLogout()
   const { t } = useTranslation();
   return (
     <Stack gap={2}>
@@ -17,7 +16,7 @@ export default function Settings() {
 
         <ListItem key="logout" disablePadding>
           <ListItemButton onClick={() => {
-            setAccessToken(RESET)
+            logout()
           }}>
             <ListItemText primary={t("logout")} />
           </ListItemButton>
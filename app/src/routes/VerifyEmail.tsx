@@ -0,0 +1,57 @@
+import { Button, CircularProgress, Stack, Typography } from "@mui/material";
+import { useMutation } from "@tanstack/react-query";
+import { useEffect, useRef } from "react";
+import { useNavigate, useSearchParams } from "react-router-dom";
+import { useTranslation } from "react-i18next";
+import { useAuthFetch } from "../hooks/api";
+
+// VerifyEmail confirms the token of the link in the verification email
+const VerifyEmail = () => {
+  const { t } = useTranslation()
+  const navigate = useNavigate()
+  const [searchParams] = useSearchParams()
+  const token = searchParams.get("token")
+  const authFetch = useAuthFetch()
+  const { mutate, isSuccess, isError } = useMutation({
+    mutationFn: (token: string) => authFetch(`/api/auth/email_verification/confirm`, {
+      method: "POST",
+      body: JSON.stringify({ token }),
+    }),
+  })
+
+  // tokens are single use, strict mode must not confirm twice
+  const confirmed = useRef(false)
+  useEffect(() => {
+    if (token && !confirmed.current) {
+      confirmed.current = true
+      mutate(token)
+    }
+  }, [token])
+
+  return (
+    <Stack gap={2} sx={{
+      display: "flex",
+      justifyContent: "center",
+      alignItems: "center",
+      height: "100vh",
+    }}>
+      {!token || isError ? (
+        <Typography>{t("verify_email.failed")}</Typography>
+      ) : isSuccess ? (
+        <Typography>{t("verify_email.success")}</Typography>
+      ) : (
+        <>
+          <CircularProgress />
+          <Typography>{t("verify_email.verifying")}</Typography>
+        </>
+      )}
+      {(!token || isError || isSuccess) && (
+        <Button variant="outlined" onClick={() => navigate("/", { replace: true })}>
+          {t("verify_email.continue")}
+        </Button>
+      )}
+    </Stack>
+  )
+}
+
+export default VerifyEmail;
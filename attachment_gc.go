@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/waylen888/tab-buddy/blobstore"
+	"github.com/waylen888/tab-buddy/config"
+	"github.com/waylen888/tab-buddy/db"
+	"github.com/waylen888/tab-buddy/thumbnail"
+)
+
+// gcAttachments reconciles stored files with the attachment tables, reporting files without a row
+// and rows without a file. Nothing is changed unless asked to,
+// usage: tabbuddy gc-attachments [-delete] [-relink]
+func gcAttachments(ctx context.Context, cfg config.Config, database db.Database, args []string) error {
+	flags := flag.NewFlagSet("gc-attachments", flag.ContinueOnError)
+	deleteOrphans := flags.Bool("delete", false, "delete files without a row and rows without a file, reset missing thumbnails")
+	relink := flags.Bool("relink", false, "move legacy expense_photo rows with a file to expense_attachment")
+	minAge := flags.Duration("min-age", time.Hour, "ignore files newer than this, they may belong to an upload in progress")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	blobs, err := blobstore.Open(cfg.Storage, cfg.Storage.Backend)
+	if err != nil {
+		return fmt.Errorf("open storage: %w", err)
+	}
+	attachments, err := database.GetAllAttachments()
+	if err != nil {
+		return fmt.Errorf("get attachments: %w", err)
+	}
+	legacyPhotos, err := database.GetLegacyExpensePhotos()
+	if err != nil {
+		return fmt.Errorf("get legacy photos: %w", err)
+	}
+
+	stored := make(map[string]blobstore.Info)
+	if err := blobs.List(ctx, func(info blobstore.Info) error {
+		stored[info.Key] = info
+		return nil
+	}); err != nil {
+		return fmt.Errorf("list storage: %w", err)
+	}
+
+	known := make(map[string]bool)
+	var missingFiles, missingThumbnails, relinked, legacyMissing int
+	for _, attachment := range attachments {
+		known[attachment.ID] = true
+		if _, ok := stored[attachment.ID]; !ok {
+			missingFiles++
+			slog.Warn("attachment without file", "id", attachment.ID, "filename", attachment.Filename)
+			if *deleteOrphans {
+				if err := database.DeleteExpenseAttachment(attachment.ID); err != nil {
+					return fmt.Errorf("delete attachment %s: %w", attachment.ID, err)
+				}
+			}
+			continue
+		}
+		if _, ok := stored[thumbnail.Key(attachment.ID)]; !ok && attachment.ThumbnailSize > 0 {
+			// the thumbnail is generated again on the next request
+			missingThumbnails++
+			slog.Warn("attachment without thumbnail", "id", attachment.ID)
+			if *deleteOrphans {
+				if err := database.SetExpenseAttachmentThumbnail(attachment.ID, 0); err != nil {
+					return fmt.Errorf("reset thumbnail %s: %w", attachment.ID, err)
+				}
+			}
+		}
+	}
+
+	for _, photo := range legacyPhotos {
+		if known[photo.ID] {
+			// already relinked, only the legacy row is left
+			if *relink {
+				if err := database.DeleteLegacyExpensePhoto(photo.ID); err != nil {
+					return fmt.Errorf("delete legacy photo %s: %w", photo.ID, err)
+				}
+			}
+			continue
+		}
+		if _, ok := stored[photo.ID]; !ok {
+			legacyMissing++
+			slog.Warn("legacy photo without file", "id", photo.ID, "filename", photo.Filename)
+			if *deleteOrphans {
+				if err := database.DeleteLegacyExpensePhoto(photo.ID); err != nil {
+					return fmt.Errorf("delete legacy photo %s: %w", photo.ID, err)
+				}
+			}
+			continue
+		}
+		// the file is not an orphan, it is kept until the row is relinked
+		known[photo.ID] = true
+		slog.Info("legacy photo", "id", photo.ID, "filename", photo.Filename)
+		if *relink {
+			if err := database.RelinkLegacyExpensePhoto(photo.ID); err != nil {
+				return fmt.Errorf("relink legacy photo %s: %w", photo.ID, err)
+			}
+			relinked++
+		}
+	}
+
+	var orphanFiles int
+	var orphanBytes int64
+	for key, info := range stored {
+		owner := key
+		if attachmentID, ok := thumbnail.AttachmentID(key); ok {
+			owner = attachmentID
+		}
+		if known[owner] || time.Since(info.ModTime) < *minAge {
+			continue
+		}
+		orphanFiles++
+		orphanBytes += info.Size
+		slog.Warn("file without attachment", "key", key, "size", info.Size, "modTime", info.ModTime)
+		if *deleteOrphans {
+			if err := blobs.Delete(ctx, key); err != nil && !errors.Is(err, blobstore.ErrNotExist) {
+				return fmt.Errorf("delete file %s: %w", key, err)
+			}
+		}
+	}
+
+	slog.Info("gc attachments",
+		"attachments", len(attachments),
+		"files", len(stored),
+		"orphanFiles", orphanFiles,
+		"orphanBytes", orphanBytes,
+		"missingFiles", missingFiles,
+		"missingThumbnails", missingThumbnails,
+		"legacyPhotos", len(legacyPhotos),
+		"legacyMissingFiles", legacyMissing,
+		"relinked", relinked,
+		"deleted", *deleteOrphans,
+	)
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/waylen888/tab-buddy/backup"
+	"github.com/waylen888/tab-buddy/blobstore"
+	"github.com/waylen888/tab-buddy/config"
+	"github.com/waylen888/tab-buddy/db"
+)
+
+// createBackup writes a snapshot of the database and attachments while the server may keep running,
+// then deletes all but the newest snapshots,
+// usage: tabbuddy backup [-dir dir] [-keep n]
+func createBackup(ctx context.Context, cfg config.Config, database db.Database, args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	dir := flags.String("dir", cfg.Backup.Dir, "directory of the snapshots")
+	keep := flags.Int("keep", cfg.Backup.Keep, "how many snapshots to keep, 0 keeps all")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	blobs, err := blobstore.Open(cfg.Storage, cfg.Storage.Backend)
+	if err != nil {
+		return fmt.Errorf("open storage: %w", err)
+	}
+	path, err := backup.Create(ctx, database, blobs, *dir)
+	if err != nil {
+		return err
+	}
+	slog.Info("backup", "path", path)
+	if *keep <= 0 {
+		return nil
+	}
+	deleted, err := backup.Prune(*dir, *keep)
+	for _, path := range deleted {
+		slog.Info("delete backup", "path", path)
+	}
+	return err
+}
+
+// restoreBackup verifies a snapshot and restores the database and attachments from it,
+// the newest snapshot unless one is named. Stop the server first,
+// usage: tabbuddy restore [-dir dir] [-verify] [-force] [snapshot]
+func restoreBackup(ctx context.Context, cfg config.Config, databasePath string, args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	dir := flags.String("dir", cfg.Backup.Dir, "directory of the snapshots")
+	verifyOnly := flags.Bool("verify", false, "only verify the snapshot")
+	force := flags.Bool("force", false, "replace an existing database, it is kept with a .before-restore suffix")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var path string
+	switch flags.NArg() {
+	case 0:
+		snapshots, err := backup.List(*dir)
+		if err != nil {
+			return fmt.Errorf("list snapshots: %w", err)
+		}
+		if len(snapshots) == 0 {
+			return fmt.Errorf("no snapshot in %s", *dir)
+		}
+		path = snapshots[len(snapshots)-1].Path
+	case 1:
+		path = flags.Arg(0)
+		// a bare name is looked up in dir
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) && filepath.Base(path) == path {
+			path = filepath.Join(*dir, path)
+		}
+	default:
+		return errors.New("at most one snapshot can be restored")
+	}
+
+	if *verifyOnly {
+		manifest, err := backup.Verify(ctx, path)
+		if err != nil {
+			return err
+		}
+		slog.Info("verified backup", "path", path, "created_at", manifest.CreatedAt, "attachments", len(manifest.Attachments))
+		return nil
+	}
+
+	blobs, err := blobstore.Open(cfg.Storage, cfg.Storage.Backend)
+	if err != nil {
+		return fmt.Errorf("open storage: %w", err)
+	}
+	manifest, err := backup.Restore(ctx, path, databasePath, blobs, *force)
+	if errors.Is(err, backup.ErrExists) {
+		return fmt.Errorf("%s: %w, use -force to replace it", databasePath, err)
+	}
+	if err != nil {
+		return err
+	}
+	slog.Info("restored backup", "path", path, "created_at", manifest.CreatedAt, "database", databasePath, "attachments", len(manifest.Attachments))
+	return nil
+}
@@ -0,0 +1,256 @@
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/waylen888/tab-buddy/blobstore"
+	"github.com/waylen888/tab-buddy/db"
+	"github.com/waylen888/tab-buddy/db/sqlite"
+	"github.com/waylen888/tab-buddy/thumbnail"
+)
+
+// A snapshot is a directory named tabbuddy-<UTC time>, holding
+//
+//	tabbuddy.sqlite   a copy of the database taken with VACUUM INTO
+//	attachments/<key> the blobs of attachment rows and their thumbnails
+//	backup.json       the Manifest, written last
+//
+// Snapshots are built in a hidden temporary directory and renamed into place,
+// so a directory with the snapshot prefix is always complete.
+const (
+	namePrefix     = "tabbuddy-"
+	timeLayout     = "20060102T150405Z"
+	databaseFile   = "tabbuddy.sqlite"
+	attachmentsDir = "attachments"
+	manifestFile   = "backup.json"
+
+	manifestVersion = 1
+)
+
+// File describes a file of a snapshot.
+type File struct {
+	Key    string `json:"key,omitempty"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Manifest is written to backup.json of every snapshot.
+type Manifest struct {
+	Version     int       `json:"version"`
+	CreatedAt   time.Time `json:"createdAt"`
+	Database    File      `json:"database"`
+	Attachments []File    `json:"attachments"`
+}
+
+// Snapshot is a snapshot directory found by List.
+type Snapshot struct {
+	Name      string
+	Path      string
+	CreatedAt time.Time
+}
+
+// List returns the snapshots in dir, oldest first.
+func List(dir string) ([]Snapshot, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var snapshots []Snapshot
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), namePrefix) {
+			continue
+		}
+		createdAt, err := time.Parse(timeLayout, strings.TrimPrefix(entry.Name(), namePrefix))
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, Snapshot{
+			Name:      entry.Name(),
+			Path:      filepath.Join(dir, entry.Name()),
+			CreatedAt: createdAt,
+		})
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.Before(snapshots[j].CreatedAt)
+	})
+	return snapshots, nil
+}
+
+// Create writes a snapshot of the database and the attachment store to dir and returns its path.
+// Only blobs of attachment rows are copied, other files in the store are not backed up.
+// Attachments unchanged since the previous snapshot are hard linked to it instead of copied.
+func Create(ctx context.Context, database db.Database, blobs blobstore.Store, dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	now := time.Now().UTC()
+	name := namePrefix + now.Format(timeLayout)
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); err == nil {
+		return "", fmt.Errorf("snapshot %s already exists", name)
+	}
+
+	snapshots, err := List(dir)
+	if err != nil {
+		return "", fmt.Errorf("list snapshots: %w", err)
+	}
+	previous := make(map[string]File)
+	var previousPath string
+	if len(snapshots) > 0 {
+		previousPath = snapshots[len(snapshots)-1].Path
+		// a previous snapshot without a readable manifest is only not linked to
+		if manifest, err := readManifest(previousPath); err == nil {
+			for _, attachment := range manifest.Attachments {
+				previous[attachment.Key] = attachment
+			}
+		}
+	}
+
+	tmp, err := os.MkdirTemp(dir, ".tmp-"+name+"-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	if err := os.Mkdir(filepath.Join(tmp, attachmentsDir), 0755); err != nil {
+		return "", err
+	}
+
+	manifest := Manifest{Version: manifestVersion, CreatedAt: now, Attachments: []File{}}
+	// the database first, an attachment deleted meanwhile is missing but one added is only unreferenced
+	databasePath := filepath.Join(tmp, databaseFile)
+	if err := database.Backup(databasePath); err != nil {
+		return "", fmt.Errorf("backup database: %w", err)
+	}
+	if err := sqlite.CheckIntegrity(ctx, databasePath); err != nil {
+		return "", fmt.Errorf("check database: %w", err)
+	}
+	if manifest.Database, err = hashFile(databasePath); err != nil {
+		return "", err
+	}
+
+	ids, err := database.GetAttachmentIDs()
+	if err != nil {
+		return "", fmt.Errorf("get attachment ids: %w", err)
+	}
+	for _, id := range ids {
+		for _, key := range []string{id, thumbnail.Key(id)} {
+			info, err := blobs.Stat(ctx, key)
+			if errors.Is(err, blobstore.ErrNotExist) {
+				continue
+			} else if err != nil {
+				return "", fmt.Errorf("stat attachment %s: %w", key, err)
+			}
+			file, err := snapshotBlob(ctx, blobs, info, previous[key], previousPath, filepath.Join(tmp, attachmentsDir, key))
+			if err != nil {
+				return "", fmt.Errorf("copy attachment %s: %w", key, err)
+			}
+			manifest.Attachments = append(manifest.Attachments, file)
+		}
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(tmp, manifestFile), data, 0644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Prune deletes all but the newest keep snapshots in dir and returns the paths deleted.
+func Prune(dir string, keep int) ([]string, error) {
+	snapshots, err := List(dir)
+	if err != nil {
+		return nil, err
+	}
+	var deleted []string
+	for len(snapshots) > keep {
+		if err := os.RemoveAll(snapshots[0].Path); err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, snapshots[0].Path)
+		snapshots = snapshots[1:]
+	}
+	return deleted, nil
+}
+
+func readManifest(path string) (Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(path, manifestFile))
+	if err != nil {
+		return Manifest{}, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return Manifest{}, fmt.Errorf("parse %s: %w", manifestFile, err)
+	}
+	if manifest.Version != manifestVersion {
+		return Manifest{}, fmt.Errorf("unsupported backup version %d", manifest.Version)
+	}
+	return manifest, nil
+}
+
+// snapshotBlob links dst to the previous snapshot's copy of the blob when its size is
+// unchanged, and copies the blob otherwise.
+func snapshotBlob(ctx context.Context, blobs blobstore.Store, info blobstore.Info, previous File, previousPath string, dst string) (File, error) {
+	if previous.Key != "" && previous.Size == info.Size {
+		if err := os.Link(filepath.Join(previousPath, attachmentsDir, info.Key), dst); err == nil {
+			return previous, nil
+		}
+	}
+	return copyBlob(ctx, blobs, info.Key, dst)
+}
+
+func copyBlob(ctx context.Context, blobs blobstore.Store, key string, dst string) (File, error) {
+	r, _, err := blobs.Get(ctx, key)
+	if err != nil {
+		return File{}, err
+	}
+	defer r.Close()
+	file, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return File{}, err
+	}
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(file, hash), r)
+	if err != nil {
+		file.Close()
+		return File{}, err
+	}
+	if err := file.Close(); err != nil {
+		return File{}, err
+	}
+	return File{Key: key, Size: size, SHA256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+func hashFile(path string) (File, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return File{}, err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return File{}, err
+	}
+	return File{Size: size, SHA256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
@@ -0,0 +1,205 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/xid"
+	"github.com/waylen888/tab-buddy/blobstore"
+	"github.com/waylen888/tab-buddy/db"
+	"github.com/waylen888/tab-buddy/db/entity"
+	"github.com/waylen888/tab-buddy/db/sqlite"
+)
+
+// newBackupTest returns a database with an expense of alice holding one attachment, and the
+// store of the attachment.
+func newBackupTest(t *testing.T) (db.Database, *blobstore.Local, entity.ExpenseAttachment, []byte) {
+	t.Helper()
+	dir := t.TempDir()
+	database, err := sqlite.New(context.Background(), filepath.Join(dir, "test.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	blobs, err := blobstore.NewLocal(filepath.Join(dir, "blobs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice, err := database.CreateUser("alice", "Alice", "alice@example.com", "password", entity.UserCreateTypeDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	group, err := database.CreateGroup("trip", alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expense, err := database.CreateExpense(entity.CreateExpenseArguments{
+		GroupID:        group.ID,
+		Amount:         "100",
+		TWDRate:        "1",
+		Description:    "dinner",
+		Date:           time.Now(),
+		CurrencyCode:   "TWD",
+		SplitUsers:     []entity.SplitUser{{User: entity.User{ID: alice.ID}, Paid: true, Owed: true}},
+		CreateByUserID: alice.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte("receipt")
+	now := time.Now()
+	attachment := entity.ExpenseAttachment{ID: xid.New().String(), Filename: "receipt.png", Size: int64(len(data)), MIME: "image/png", CreateAt: now, UpdateAt: now}
+	if err := blobs.Put(context.Background(), attachment.ID, bytes.NewReader(data), attachment.Size, attachment.MIME); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.CreateExpenseAttachments(entity.CreateExpenseAttachmentsArgument{
+		ExpenseID:   expense.ID,
+		Attachments: []entity.ExpenseAttachment{attachment},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// a file in the store without a row is not backed up
+	if err := blobs.Put(context.Background(), "orphan", strings.NewReader("orphan"), 6, ""); err != nil {
+		t.Fatal(err)
+	}
+	return database, blobs, attachment, data
+}
+
+func TestCreateVerifyRestore(t *testing.T) {
+	ctx := context.Background()
+	database, blobs, attachment, data := newBackupTest(t)
+	dir := t.TempDir()
+
+	path, err := Create(ctx, database, blobs, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshots, err := List(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 1 || snapshots[0].Path != path {
+		t.Fatalf("snapshots = %+v, want %s", snapshots, path)
+	}
+	manifest, err := Verify(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Attachments) != 1 || manifest.Attachments[0].Key != attachment.ID || manifest.Attachments[0].Size != attachment.Size {
+		t.Errorf("manifest attachments = %+v, want only the attachment", manifest.Attachments)
+	}
+
+	// restored to a server without the database and the files
+	target := t.TempDir()
+	databasePath := filepath.Join(target, "tabbuddy.sqlite")
+	restoredBlobs, err := blobstore.NewLocal(filepath.Join(target, "blobs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Restore(ctx, path, databasePath, restoredBlobs, false); err != nil {
+		t.Fatal(err)
+	}
+	file, _, err := restoredBlobs.Get(ctx, attachment.ID)
+	if err != nil {
+		t.Fatalf("get restored attachment: %v", err)
+	}
+	got, err := io.ReadAll(file)
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("restored attachment = %q, want %q", got, data)
+	}
+	restored, err := sqlite.New(ctx, databasePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user, err := restored.GetUserByUsername("alice"); err != nil || user.Email != "alice@example.com" {
+		t.Errorf("restored user = %+v, %v", user, err)
+	}
+	if _, err := restored.GetExpenseAttachment(attachment.ID); err != nil {
+		t.Errorf("restored attachment row: %v", err)
+	}
+
+	// an existing database is only replaced with force, and kept aside
+	if _, err := Restore(ctx, path, databasePath, restoredBlobs, false); !errors.Is(err, ErrExists) {
+		t.Errorf("restore over a database = %v, want ErrExists", err)
+	}
+	if _, err := Restore(ctx, path, databasePath, restoredBlobs, true); err != nil {
+		t.Fatalf("restore with force: %v", err)
+	}
+	kept, err := filepath.Glob(databasePath + ".before-restore-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kept) == 0 {
+		t.Error("replaced database not kept")
+	}
+}
+
+func TestVerifyDetectsDamage(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name   string
+		damage func(t *testing.T, path string, attachment entity.ExpenseAttachment)
+	}{
+		{"changed attachment", func(t *testing.T, path string, attachment entity.ExpenseAttachment) {
+			if err := os.WriteFile(filepath.Join(path, attachmentsDir, attachment.ID), []byte("RECEIPT"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"missing attachment", func(t *testing.T, path string, attachment entity.ExpenseAttachment) {
+			if err := os.Remove(filepath.Join(path, attachmentsDir, attachment.ID)); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"truncated database", func(t *testing.T, path string, attachment entity.ExpenseAttachment) {
+			if err := os.Truncate(filepath.Join(path, databaseFile), 1024); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"key out of the snapshot", func(t *testing.T, path string, attachment entity.ExpenseAttachment) {
+			manifest, err := readManifest(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			manifest.Attachments[0].Key = "../" + databaseFile
+			data, err := json.Marshal(manifest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(path, manifestFile), data, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			database, blobs, attachment, _ := newBackupTest(t)
+			path, err := Create(ctx, database, blobs, t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			test.damage(t, path, attachment)
+			if _, err := Verify(ctx, path); err == nil {
+				t.Error("damaged snapshot verified")
+			}
+			databasePath := filepath.Join(t.TempDir(), "tabbuddy.sqlite")
+			if _, err := Restore(ctx, path, databasePath, blobs, false); err == nil {
+				t.Error("damaged snapshot restored")
+			}
+			if _, err := os.Stat(databasePath); !errors.Is(err, os.ErrNotExist) {
+				t.Error("database written from a damaged snapshot")
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/waylen888/tab-buddy/blobstore"
+	"github.com/waylen888/tab-buddy/db/sqlite"
+)
+
+// ErrExists is returned by Restore when the database file exists and overwriting was not asked for.
+var ErrExists = errors.New("database already exists")
+
+// Verify checks the database of the snapshot at path with the sqlite integrity check
+// and every file of the snapshot against the size and checksum in its manifest.
+func Verify(ctx context.Context, path string) (Manifest, error) {
+	manifest, err := readManifest(path)
+	if err != nil {
+		return Manifest{}, err
+	}
+	if err := checkFile(filepath.Join(path, databaseFile), manifest.Database); err != nil {
+		return Manifest{}, err
+	}
+	if err := sqlite.CheckIntegrity(ctx, filepath.Join(path, databaseFile)); err != nil {
+		return Manifest{}, err
+	}
+	for _, attachment := range manifest.Attachments {
+		if !validKey(attachment.Key) {
+			return Manifest{}, fmt.Errorf("invalid attachment key %q", attachment.Key)
+		}
+		if err := checkFile(filepath.Join(path, attachmentsDir, attachment.Key), attachment); err != nil {
+			return Manifest{}, err
+		}
+	}
+	return manifest, nil
+}
+
+// Restore verifies the snapshot at path, then copies its database to databasePath and puts back
+// the attachments missing from blobs or differing in size. An existing database is only replaced
+// when force is set and is kept next to it with a .before-restore suffix. The server must not be
+// running meanwhile.
+func Restore(ctx context.Context, path string, databasePath string, blobs blobstore.Store, force bool) (Manifest, error) {
+	manifest, err := Verify(ctx, path)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("verify: %w", err)
+	}
+
+	if _, err := os.Stat(databasePath); err == nil {
+		if !force {
+			return Manifest{}, ErrExists
+		}
+		suffix := ".before-restore-" + time.Now().UTC().Format(timeLayout)
+		// a journal left behind would be applied to the restored database
+		for _, name := range []string{databasePath, databasePath + "-journal", databasePath + "-wal", databasePath + "-shm"} {
+			if err := os.Rename(name, name+suffix); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return Manifest{}, err
+			}
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return Manifest{}, err
+	}
+	if err := copyFile(filepath.Join(path, databaseFile), databasePath); err != nil {
+		return Manifest{}, fmt.Errorf("copy database: %w", err)
+	}
+
+	for _, attachment := range manifest.Attachments {
+		info, err := blobs.Stat(ctx, attachment.Key)
+		if err == nil && info.Size == attachment.Size {
+			continue
+		}
+		if err != nil && !errors.Is(err, blobstore.ErrNotExist) {
+			return Manifest{}, fmt.Errorf("stat attachment %s: %w", attachment.Key, err)
+		}
+		if err := putFile(ctx, blobs, attachment, filepath.Join(path, attachmentsDir, attachment.Key)); err != nil {
+			return Manifest{}, fmt.Errorf("put attachment %s: %w", attachment.Key, err)
+		}
+	}
+	return manifest, nil
+}
+
+func validKey(key string) bool {
+	return key != "" && key != "." && key != ".." && !strings.ContainsAny(key, `/\`)
+}
+
+func checkFile(path string, want File) error {
+	got, err := hashFile(path)
+	if err != nil {
+		return err
+	}
+	if got.Size != want.Size || got.SHA256 != want.SHA256 {
+		return fmt.Errorf("%s does not match the manifest", path)
+	}
+	return nil
+}
+
+func copyFile(src string, dst string) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	tmp, err := os.CreateTemp(filepath.Dir(dst), filepath.Base(dst)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+func putFile(ctx context.Context, blobs blobstore.Store, attachment File, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return blobs.Put(ctx, attachment.Key, file, attachment.Size, "")
+}
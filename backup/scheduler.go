@@ -0,0 +1,76 @@
+package backup
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/waylen888/tab-buddy/blobstore"
+	"github.com/waylen888/tab-buddy/config"
+	"github.com/waylen888/tab-buddy/db"
+)
+
+// checkInterval is how often the age of the newest snapshot is checked.
+const checkInterval = time.Hour
+
+// Scheduler takes a snapshot every interval and prunes the old ones.
+type Scheduler struct {
+	db       db.Database
+	blobs    blobstore.Store
+	dir      string
+	interval time.Duration
+	keep     int
+}
+
+func NewScheduler(db db.Database, blobs blobstore.Store, cfg config.BackupSetting) *Scheduler {
+	return &Scheduler{
+		db:       db,
+		blobs:    blobs,
+		dir:      cfg.Dir,
+		interval: time.Duration(cfg.Interval),
+		keep:     cfg.Keep,
+	}
+}
+
+// Run takes snapshots until ctx is done, it does nothing when scheduled backups are disabled.
+func (s *Scheduler) Run(ctx context.Context) error {
+	if s.interval <= 0 {
+		return nil
+	}
+	ticker := time.NewTicker(min(checkInterval, s.interval))
+	defer ticker.Stop()
+	for {
+		s.snapshotDue(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// snapshotDue takes a snapshot when the newest one is older than the interval,
+// so restarting the server does not postpone it.
+func (s *Scheduler) snapshotDue(ctx context.Context, now time.Time) {
+	snapshots, err := List(s.dir)
+	if err != nil {
+		slog.Error("list backups", "dir", s.dir, "error", err)
+		return
+	}
+	if len(snapshots) > 0 && now.Sub(snapshots[len(snapshots)-1].CreatedAt) < s.interval {
+		return
+	}
+	path, err := Create(ctx, s.db, s.blobs, s.dir)
+	if err != nil {
+		slog.Error("backup", "dir", s.dir, "error", err)
+		return
+	}
+	slog.Info("backup", "path", path)
+	deleted, err := Prune(s.dir, s.keep)
+	if err != nil {
+		slog.Error("prune backups", "dir", s.dir, "error", err)
+	}
+	for _, path := range deleted {
+		slog.Info("delete backup", "path", path)
+	}
+}
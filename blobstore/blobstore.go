@@ -0,0 +1,51 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/waylen888/tab-buddy/config"
+)
+
+// ErrNotExist is returned when a blob is not in the store.
+var ErrNotExist = errors.New("blob does not exist")
+
+// Info describes a stored blob.
+type Info struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Store keeps attachment files by key.
+type Store interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, Info, error)
+	Delete(ctx context.Context, key string) error
+	Stat(ctx context.Context, key string) (Info, error)
+	// List calls fn for every blob in the store, stopping at the first error.
+	List(ctx context.Context, fn func(Info) error) error
+}
+
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+)
+
+// Open returns the store of backend, configured by cfg.
+func Open(cfg config.StorageSetting, backend string) (Store, error) {
+	switch backend {
+	case BackendLocal:
+		return NewLocal(cfg.Local.Dir)
+	case BackendS3:
+		s3, err := NewS3(cfg.S3)
+		if err != nil {
+			return nil, err
+		}
+		return s3, nil
+	}
+	return nil, fmt.Errorf("unknown storage backend %q", backend)
+}
@@ -0,0 +1,197 @@
+package blobstore
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/waylen888/tab-buddy/config"
+)
+
+// S3 stores blobs in a bucket of an S3 compatible service.
+type S3 struct {
+	endpoint   *url.URL
+	bucket     string
+	prefix     string
+	pathStyle  bool
+	signer     signer
+	httpClient *http.Client
+}
+
+func NewS3(cfg config.S3Storage) (*S3, error) {
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parse endpoint: %w", err)
+	}
+	if endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("endpoint %q needs a scheme and a host", cfg.Endpoint)
+	}
+	return &S3{
+		endpoint:  endpoint,
+		bucket:    cfg.Bucket,
+		prefix:    cfg.Prefix,
+		pathStyle: cfg.PathStyle,
+		signer: signer{
+			accessKeyID:     cfg.AccessKeyID,
+			secretAccessKey: cfg.SecretAccessKey,
+			region:          cfg.Region,
+			service:         "s3",
+		},
+		httpClient: &http.Client{
+			Timeout: time.Minute * 5,
+		},
+	}, nil
+}
+
+func (s *S3) objectURL(key string, query url.Values) *url.URL {
+	u := *s.endpoint
+	objectPath := "/"
+	if key != "" {
+		objectPath += s.prefix + key
+	}
+	if s.pathStyle {
+		u.Path = "/" + s.bucket + objectPath
+	} else {
+		u.Host = s.bucket + "." + u.Host
+		u.Path = objectPath
+	}
+	// encode the query the same way it is signed
+	u.RawQuery = canonicalQuery(query)
+	return &u
+}
+
+func (s *S3) do(ctx context.Context, method string, u *url.URL, body io.Reader, size int64, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("new request: %w", err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	s.signer.sign(req, time.Now())
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+	if res.StatusCode == http.StatusNotFound {
+		io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+		return nil, ErrNotExist
+	}
+	if res.StatusCode/100 != 2 {
+		defer res.Body.Close()
+		var e struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		xml.NewDecoder(io.LimitReader(res.Body, 64<<10)).Decode(&e)
+		return nil, fmt.Errorf("receive status: %s %s %s", res.Status, e.Code, e.Message)
+	}
+	return res, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	res, err := s.do(ctx, http.MethodPut, s.objectURL(key, nil), r, size, header)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, res.Body)
+	return res.Body.Close()
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, Info, error) {
+	res, err := s.do(ctx, http.MethodGet, s.objectURL(key, nil), nil, 0, nil)
+	if err != nil {
+		return nil, Info{}, err
+	}
+	return res.Body, infoFromHeader(key, res), nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	// DELETE succeeds for missing objects, check first so callers get ErrNotExist like the local store
+	if _, err := s.Stat(ctx, key); err != nil {
+		return err
+	}
+	res, err := s.do(ctx, http.MethodDelete, s.objectURL(key, nil), nil, 0, nil)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, res.Body)
+	return res.Body.Close()
+}
+
+func (s *S3) Stat(ctx context.Context, key string) (Info, error) {
+	res, err := s.do(ctx, http.MethodHead, s.objectURL(key, nil), nil, 0, nil)
+	if err != nil {
+		return Info{}, err
+	}
+	res.Body.Close()
+	return infoFromHeader(key, res), nil
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *S3) List(ctx context.Context, fn func(Info) error) error {
+	token := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		if s.prefix != "" {
+			query.Set("prefix", s.prefix)
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		res, err := s.do(ctx, http.MethodGet, s.objectURL("", query), nil, 0, nil)
+		if err != nil {
+			return err
+		}
+		var result listBucketResult
+		err = xml.NewDecoder(res.Body).Decode(&result)
+		res.Body.Close()
+		if err != nil {
+			return fmt.Errorf("decode list result: %w", err)
+		}
+		for _, object := range result.Contents {
+			info := Info{
+				Key:     strings.TrimPrefix(object.Key, s.prefix),
+				Size:    object.Size,
+				ModTime: object.LastModified,
+			}
+			if err := fn(info); err != nil {
+				return err
+			}
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+func infoFromHeader(key string, res *http.Response) Info {
+	size, _ := strconv.ParseInt(res.Header.Get("Content-Length"), 10, 64)
+	modTime, _ := http.ParseTime(res.Header.Get("Last-Modified"))
+	return Info{Key: key, Size: size, ModTime: modTime}
+}
@@ -0,0 +1,76 @@
+package calc
+
+import (
+	"sort"
+
+	"github.com/shopspring/decimal"
+)
+
+// Expense is an amount split between users, all expenses given to Balances must be in one currency.
+type Expense struct {
+	Amount     string
+	SplitUsers []SplitUser
+}
+
+// Balances returns what each user of the expenses is owed, negative when they owe.
+func Balances(expenses []Expense) map[string]decimal.Decimal {
+	balances := make(map[string]decimal.Decimal)
+	for _, expense := range expenses {
+		for _, user := range expense.SplitUsers {
+			balances[user.ID] = balances[user.ID].Add(SplitValue(expense.Amount, expense.SplitUsers, user.ID))
+		}
+	}
+	return balances
+}
+
+// Transfer is a payment that settles part of the balances.
+type Transfer struct {
+	From   string
+	To     string
+	Amount decimal.Decimal
+}
+
+// SettleUp suggests transfers that bring all balances to zero, paying the largest debts to the
+// largest credits first to keep the list short. Balances are rounded to places, remainders
+// smaller than that are ignored.
+func SettleUp(balances map[string]decimal.Decimal, places int32) []Transfer {
+	type entry struct {
+		id     string
+		amount decimal.Decimal
+	}
+	var creditors, debtors []entry
+	for id, balance := range balances {
+		balance = balance.Round(places)
+		switch balance.Sign() {
+		case 1:
+			creditors = append(creditors, entry{id, balance})
+		case -1:
+			debtors = append(debtors, entry{id, balance.Neg()})
+		}
+	}
+	byAmount := func(entries []entry) func(i, j int) bool {
+		return func(i, j int) bool {
+			if !entries[i].amount.Equal(entries[j].amount) {
+				return entries[i].amount.GreaterThan(entries[j].amount)
+			}
+			return entries[i].id < entries[j].id
+		}
+	}
+	sort.Slice(creditors, byAmount(creditors))
+	sort.Slice(debtors, byAmount(debtors))
+
+	var transfers []Transfer
+	for i, j := 0, 0; i < len(debtors) && j < len(creditors); {
+		amount := decimal.Min(debtors[i].amount, creditors[j].amount)
+		transfers = append(transfers, Transfer{From: debtors[i].id, To: creditors[j].id, Amount: amount})
+		debtors[i].amount = debtors[i].amount.Sub(amount)
+		creditors[j].amount = creditors[j].amount.Sub(amount)
+		if debtors[i].amount.IsZero() {
+			i++
+		}
+		if creditors[j].amount.IsZero() {
+			j++
+		}
+	}
+	return transfers
+}
@@ -0,0 +1,77 @@
+package calc
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestBalances(t *testing.T) {
+	balances := Balances([]Expense{
+		// a pays 90 for a, b and c
+		{Amount: "90", SplitUsers: []SplitUser{{ID: "a", Paid: true, Owed: true}, {ID: "b", Owed: true}, {ID: "c", Owed: true}}},
+		// b pays 40 for a only
+		{Amount: "40", SplitUsers: []SplitUser{{ID: "b", Paid: true}, {ID: "a", Owed: true}}},
+		// c pays 100 for c and d
+		{Amount: "100", SplitUsers: []SplitUser{{ID: "c", Paid: true, Owed: true}, {ID: "d", Owed: true}}},
+	})
+	want := map[string]string{"a": "20", "b": "10", "c": "20", "d": "-50"}
+	if len(balances) != len(want) {
+		t.Errorf("Balances() = %v, want %v", balances, want)
+	}
+	sum := decimal.Zero
+	for id, amount := range want {
+		if !balances[id].Equal(decimal.RequireFromString(amount)) {
+			t.Errorf("balance of %s = %s, want %s", id, balances[id], amount)
+		}
+		sum = sum.Add(balances[id])
+	}
+	if !sum.IsZero() {
+		t.Errorf("balances add up to %s", sum)
+	}
+}
+
+func TestSettleUp(t *testing.T) {
+	tests := []struct {
+		name     string
+		balances map[string]string
+		want     []string
+	}{
+		{
+			name:     "settled",
+			balances: map[string]string{"a": "0", "b": "0.001"},
+			want:     nil,
+		},
+		{
+			name:     "one debtor pays everyone",
+			balances: map[string]string{"a": "20", "b": "10", "c": "20", "d": "-50"},
+			want:     []string{"d->a 20", "d->c 20", "d->b 10"},
+		},
+		{
+			name:     "largest debt to largest credit first",
+			balances: map[string]string{"a": "70", "b": "30", "c": "-60", "d": "-40"},
+			want:     []string{"c->a 60", "d->a 10", "d->b 30"},
+		},
+		{
+			name:     "thirds are rounded",
+			balances: map[string]string{"a": "66.666666", "b": "-33.333333", "c": "-33.333333"},
+			want:     []string{"b->a 33.33", "c->a 33.33"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			balances := make(map[string]decimal.Decimal)
+			for id, amount := range tt.balances {
+				balances[id] = decimal.RequireFromString(amount)
+			}
+			var got []string
+			for _, transfer := range SettleUp(balances, 2) {
+				got = append(got, transfer.From+"->"+transfer.To+" "+transfer.Amount.String())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("SettleUp() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -20,23 +20,22 @@ func SplitValue(expenseAmount string, splitUsers []SplitUser, userID string) (su
 		}
 		return 0
 	}))
-	avg := amount.Div(owedUserCount)
-	one := decimal.NewFromInt(1)
-	if owedUserCount.Equal(decimal.Zero) {
+	if owedUserCount.IsZero() {
 		return
 	}
+	avg := amount.Div(owedUserCount)
+	one := decimal.NewFromInt(1)
 	for _, user := range splitUsers {
 		if user.ID != userID {
 			continue
 		}
 		if user.Paid {
-			if owedUserCount.Equal(one) {
-				if user.Owed {
-					// me paid and me owed
-					// do nothing
-				} else {
-					sum = sum.Add(amount)
-				}
+			if !user.Owed {
+				// me paid for the others only
+				sum = sum.Add(amount)
+			} else if owedUserCount.Equal(one) {
+				// me paid and me owed
+				// do nothing
 			} else {
 				// avg * (numberOfUsers - 1)
 				sum = sum.Add(avg.Mul(owedUserCount.Sub(one)))
@@ -0,0 +1,103 @@
+package calc
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestSplitValue(t *testing.T) {
+	tests := []struct {
+		name       string
+		amount     string
+		splitUsers []SplitUser
+		userID     string
+		want       string
+	}{
+		{
+			name:       "one owed, paid for themselves",
+			amount:     "100",
+			splitUsers: []SplitUser{{ID: "a", Paid: true, Owed: true}},
+			userID:     "a",
+			want:       "0",
+		},
+		{
+			name:       "one owed, payer is credited the whole amount",
+			amount:     "100",
+			splitUsers: []SplitUser{{ID: "a", Paid: true}, {ID: "b", Owed: true}},
+			userID:     "a",
+			want:       "100",
+		},
+		{
+			name:       "one owed, debtor owes the whole amount",
+			amount:     "100",
+			splitUsers: []SplitUser{{ID: "a", Paid: true}, {ID: "b", Owed: true}},
+			userID:     "b",
+			want:       "-100",
+		},
+		{
+			name:       "two owed, payer in the split is credited the other half",
+			amount:     "100",
+			splitUsers: []SplitUser{{ID: "a", Paid: true, Owed: true}, {ID: "b", Owed: true}},
+			userID:     "a",
+			want:       "50",
+		},
+		{
+			name:       "two owed, debtor owes half",
+			amount:     "100",
+			splitUsers: []SplitUser{{ID: "a", Paid: true, Owed: true}, {ID: "b", Owed: true}},
+			userID:     "b",
+			want:       "-50",
+		},
+		{
+			name:       "two owed, payer outside the split is credited the whole amount",
+			amount:     "100",
+			splitUsers: []SplitUser{{ID: "a", Paid: true}, {ID: "b", Owed: true}, {ID: "c", Owed: true}},
+			userID:     "a",
+			want:       "100",
+		},
+		{
+			name:       "n owed, payer in the split is credited the shares of the others",
+			amount:     "90",
+			splitUsers: []SplitUser{{ID: "a", Paid: true, Owed: true}, {ID: "b", Owed: true}, {ID: "c", Owed: true}},
+			userID:     "a",
+			want:       "60",
+		},
+		{
+			name:       "n owed, debtor owes one share",
+			amount:     "90",
+			splitUsers: []SplitUser{{ID: "a", Paid: true, Owed: true}, {ID: "b", Owed: true}, {ID: "c", Owed: true}},
+			userID:     "c",
+			want:       "-30",
+		},
+		{
+			name:       "n owed, payer outside the split is credited the whole amount",
+			amount:     "90",
+			splitUsers: []SplitUser{{ID: "a", Paid: true}, {ID: "b", Owed: true}, {ID: "c", Owed: true}, {ID: "d", Owed: true}},
+			userID:     "a",
+			want:       "90",
+		},
+		{
+			name:       "nobody owed",
+			amount:     "100",
+			splitUsers: []SplitUser{{ID: "a", Paid: true}, {ID: "b"}},
+			userID:     "a",
+			want:       "0",
+		},
+		{
+			name:       "user not in the split",
+			amount:     "100",
+			splitUsers: []SplitUser{{ID: "a", Paid: true}, {ID: "b", Owed: true}},
+			userID:     "z",
+			want:       "0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SplitValue(tt.amount, tt.splitUsers, tt.userID)
+			if !got.Equal(decimal.RequireFromString(tt.want)) {
+				t.Errorf("SplitValue() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
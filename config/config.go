@@ -1,17 +1,32 @@
 package config
 
 import (
+	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/pelletier/go-toml/v2"
 )
 
 type Config struct {
-	GoogleOAuth GoogleOAuth `toml:"google_oauth"`
-	HTTPSetting HTTPSetting `toml:"http_setting"`
-	DataDir     string      `toml:"data_dir"`
-	SMTP        SMTPSetting `toml:"smtp"`
+	GoogleOAuth GoogleOAuth       `toml:"google_oauth"`
+	HTTPSetting HTTPSetting       `toml:"http_setting"`
+	DataDir     string            `toml:"data_dir"`
+	SMTP        SMTPSetting       `toml:"smtp"`
+	Token       TokenSetting      `toml:"token"`
+	OIDC        []OIDCProvider    `toml:"oidc"`
+	Storage     StorageSetting    `toml:"storage"`
+	Attachment  AttachmentSetting `toml:"attachment"`
+	Receipt     ReceiptSetting    `toml:"receipt"`
+	Outbox      OutboxSetting     `toml:"outbox"`
+	WebPush     WebPushSetting    `toml:"web_push"`
+	Digest      DigestSetting     `toml:"digest"`
+	Backup      BackupSetting     `toml:"backup"`
+	Report      ReportSetting     `toml:"report"`
+	// Admins are the usernames allowed to use the /api/admin endpoints.
+	Admins []string `toml:"admins"`
 }
 
 type GoogleOAuth struct {
@@ -20,8 +35,20 @@ type GoogleOAuth struct {
 	RedirectURL  string `toml:"redirect_url"`
 }
 
+type OIDCProvider struct {
+	// Name identifies the provider in urls and linked accounts, e.g. "google".
+	Name         string   `toml:"name"`
+	Issuer       string   `toml:"issuer"`
+	ClientID     string   `toml:"client_id"`
+	ClientSecret string   `toml:"client_secret"`
+	RedirectURL  string   `toml:"redirect_url"`
+	Scopes       []string `toml:"scopes"`
+}
+
 type HTTPSetting struct {
-	Listen       string `toml:"listen"`
+	Listen string `toml:"listen"`
+	// PublicURL is the external address of the app, used to build links in emails.
+	PublicURL    string `toml:"public_url"`
 	CertFilePath string `toml:"cert_filepath"`
 	KeyFilePath  string `toml:"key_filepath"`
 }
@@ -30,6 +57,114 @@ type SMTPSetting struct {
 	Host     string `toml:"host"`
 	Username string `toml:"username"`
 	Password string `toml:"password"`
+	// From is the sender address, e.g. "Tab Buddy <noreply@example.com>", defaults to username.
+	From string `toml:"from"`
+	// TLS is "starttls" (default), "tls" for implicit tls (port 465) or "none".
+	TLS string `toml:"tls"`
+}
+
+type AttachmentSetting struct {
+	// MaxFileSize is the largest accepted upload in bytes.
+	MaxFileSize int64 `toml:"max_file_size"`
+	// AllowedMIMETypes are the content types accepted, detected from the file content.
+	AllowedMIMETypes []string `toml:"allowed_mime_types"`
+	// GroupQuota is the total bytes of attachments a group may store, defaults to 1 GiB.
+	// A negative value disables the quota, it is 0 after loading.
+	GroupQuota int64 `toml:"group_quota"`
+}
+
+type OutboxSetting struct {
+	// MaxAttempts is how many times an email is tried before it is dead-lettered, defaults to 8.
+	MaxAttempts int `toml:"max_attempts"`
+	// BaseDelay is the wait after the first failure, doubled on every retry up to MaxDelay.
+	BaseDelay Duration `toml:"base_delay"`
+	MaxDelay  Duration `toml:"max_delay"`
+}
+
+type DigestSetting struct {
+	// Interval is how often users get a digest email, defaults to a week.
+	// A negative value disables digests.
+	Interval Duration `toml:"interval"`
+}
+
+type BackupSetting struct {
+	// Dir is where snapshots of the database and attachments are written, defaults to data_dir/backups.
+	Dir string `toml:"dir"`
+	// Interval is how often the server takes a snapshot, 0 (default) disables scheduled backups.
+	Interval Duration `toml:"interval"`
+	// Keep is how many snapshots are kept, older ones are deleted, defaults to 7.
+	Keep int `toml:"keep"`
+}
+
+type ReportSetting struct {
+	// FontFile is a TrueType font for PDF reports. The default Go font has no CJK glyphs,
+	// groups with such names need a font that covers them, e.g. DroidSansFallbackFull.ttf.
+	FontFile string `toml:"font_file"`
+}
+
+type WebPushSetting struct {
+	// VAPIDPublicKey and VAPIDPrivateKey are base64url encoded P-256 keys,
+	// create them with "tabbuddy vapid-keys". Push notifications are disabled without them.
+	VAPIDPublicKey  string `toml:"vapid_public_key"`
+	VAPIDPrivateKey string `toml:"vapid_private_key"`
+	// Subject is the contact given to push services, a mailto: or https: url.
+	Subject string `toml:"subject"`
+	// AllowHTTP accepts plain http subscription endpoints and ones on private addresses,
+	// only for testing with a local push service.
+	AllowHTTP bool `toml:"allow_http"`
+}
+
+type ReceiptSetting struct {
+	// Extractor reads the text of uploaded receipts, "auto" (default), "tesseract" or "none".
+	// auto uses tesseract when it is installed.
+	Extractor     string `toml:"extractor"`
+	TesseractPath string `toml:"tesseract_path"`
+	// Languages are the tesseract languages, e.g. "eng+chi_tra".
+	Languages string `toml:"languages"`
+}
+
+type StorageSetting struct {
+	// Backend is where attachments are stored, "local" (default) or "s3".
+	Backend string       `toml:"backend"`
+	Local   LocalStorage `toml:"local"`
+	S3      S3Storage    `toml:"s3"`
+}
+
+type LocalStorage struct {
+	// Dir defaults to data_dir/attachments. It must hold nothing but attachments,
+	// gc-attachments -delete removes every file no attachment refers to.
+	Dir string `toml:"dir"`
+}
+
+type S3Storage struct {
+	// Endpoint is the service url, e.g. "https://s3.ap-northeast-1.amazonaws.com".
+	Endpoint        string `toml:"endpoint"`
+	Region          string `toml:"region"`
+	Bucket          string `toml:"bucket"`
+	AccessKeyID     string `toml:"access_key_id"`
+	SecretAccessKey string `toml:"secret_access_key"`
+	// Prefix is prepended to every object key.
+	Prefix string `toml:"prefix"`
+	// PathStyle puts the bucket in the path instead of the host, needed by most self hosted services.
+	PathStyle bool `toml:"path_style"`
+}
+
+type TokenSetting struct {
+	// Keys are the HMAC keys accepted when verifying tokens, identified by the kid header.
+	Keys []TokenKey `toml:"keys"`
+	// KeyFile is an optional toml file holding more [[keys]] entries.
+	KeyFile string `toml:"key_file"`
+	// ActiveKeyID is the key used to sign new tokens, defaults to the last key.
+	ActiveKeyID     string   `toml:"active_key_id"`
+	AccessTokenTTL  Duration `toml:"access_token_ttl"`
+	RefreshTokenTTL Duration `toml:"refresh_token_ttl"`
+	// AttachmentURLTTL is how long signed attachment urls stay valid.
+	AttachmentURLTTL Duration `toml:"attachment_url_ttl"`
+}
+
+type TokenKey struct {
+	ID     string `toml:"id"`
+	Secret string `toml:"secret"`
 }
 
 func New(cfgPath string) (Config, error) {
@@ -53,5 +188,130 @@ func New(cfgPath string) (Config, error) {
 		cfg.DataDir, _ = filepath.Abs(cfg.DataDir)
 	}
 
+	if cfg.SMTP.From == "" {
+		cfg.SMTP.From = cfg.SMTP.Username
+	}
+	switch cfg.SMTP.TLS {
+	case "":
+		cfg.SMTP.TLS = "starttls"
+	case "starttls", "tls", "none":
+	default:
+		return Config{}, fmt.Errorf("smtp: unknown tls mode %q", cfg.SMTP.TLS)
+	}
+
+	if cfg.Outbox.MaxAttempts <= 0 {
+		cfg.Outbox.MaxAttempts = 8
+	}
+	if cfg.Outbox.BaseDelay == 0 {
+		cfg.Outbox.BaseDelay = Duration(30 * time.Second)
+	}
+	if cfg.Outbox.MaxDelay == 0 {
+		cfg.Outbox.MaxDelay = Duration(6 * time.Hour)
+	}
+
+	if cfg.Digest.Interval == 0 {
+		cfg.Digest.Interval = Duration(7 * 24 * time.Hour)
+	}
+
+	if cfg.Backup.Dir == "" {
+		cfg.Backup.Dir = filepath.Join(cfg.DataDir, "backups")
+	}
+	if cfg.Backup.Keep <= 0 {
+		cfg.Backup.Keep = 7
+	}
+
+	if cfg.Attachment.MaxFileSize == 0 {
+		cfg.Attachment.MaxFileSize = 10 << 20
+	}
+	if cfg.Attachment.AllowedMIMETypes == nil {
+		cfg.Attachment.AllowedMIMETypes = []string{
+			"image/jpeg", "image/png", "image/webp", "image/gif", "image/heif", "application/pdf",
+		}
+	}
+	if cfg.Attachment.GroupQuota == 0 {
+		cfg.Attachment.GroupQuota = 1 << 30
+	} else if cfg.Attachment.GroupQuota < 0 {
+		cfg.Attachment.GroupQuota = 0
+	}
+
+	if cfg.Receipt.Extractor == "" {
+		cfg.Receipt.Extractor = "auto"
+	}
+	if cfg.Receipt.TesseractPath == "" {
+		cfg.Receipt.TesseractPath = "tesseract"
+	}
+
+	if cfg.Storage.Backend == "" {
+		cfg.Storage.Backend = "local"
+	}
+	if cfg.Storage.Local.Dir == "" {
+		cfg.Storage.Local.Dir = filepath.Join(cfg.DataDir, "attachments")
+	}
+	if cfg.Storage.Backend == "s3" || cfg.Storage.S3.Endpoint != "" {
+		if err := cfg.Storage.S3.validate(); err != nil {
+			return Config{}, fmt.Errorf("storage: %w", err)
+		}
+	}
+
+	for _, provider := range cfg.OIDC {
+		if provider.Name == "" || provider.Issuer == "" || provider.ClientID == "" {
+			return Config{}, fmt.Errorf("oidc: name, issuer and client_id are required")
+		}
+	}
+
+	if err := cfg.Token.load(); err != nil {
+		return Config{}, fmt.Errorf("token: %w", err)
+	}
+
 	return cfg, nil
 }
+
+func (s *S3Storage) validate() error {
+	if s.Endpoint == "" || s.Bucket == "" {
+		return fmt.Errorf("s3: endpoint and bucket are required")
+	}
+	if _, err := url.Parse(s.Endpoint); err != nil {
+		return fmt.Errorf("s3: endpoint: %w", err)
+	}
+	if s.Region == "" {
+		s.Region = "us-east-1"
+	}
+	return nil
+}
+
+func (t *TokenSetting) load() error {
+	if t.KeyFile != "" {
+		file, err := os.Open(t.KeyFile)
+		if err != nil {
+			return fmt.Errorf("open key file: %w", err)
+		}
+		defer file.Close()
+		var keyFile struct {
+			Keys []TokenKey `toml:"keys"`
+		}
+		if err := toml.NewDecoder(file).Decode(&keyFile); err != nil {
+			return fmt.Errorf("decode key file: %w", err)
+		}
+		t.Keys = append(t.Keys, keyFile.Keys...)
+	}
+
+	for _, key := range t.Keys {
+		if key.ID == "" || key.Secret == "" {
+			return fmt.Errorf("key id and secret are required")
+		}
+	}
+	if t.ActiveKeyID == "" && len(t.Keys) > 0 {
+		t.ActiveKeyID = t.Keys[len(t.Keys)-1].ID
+	}
+
+	if t.AccessTokenTTL == 0 {
+		t.AccessTokenTTL = Duration(time.Minute * 15)
+	}
+	if t.RefreshTokenTTL == 0 {
+		t.RefreshTokenTTL = Duration(time.Hour * 24 * 30)
+	}
+	if t.AttachmentURLTTL == 0 {
+		t.AttachmentURLTTL = Duration(time.Hour)
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package config
+
+import "time"
+
+// Duration is a time.Duration that can be decoded from a TOML string such as "15m".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d Duration) Duration() time.Duration {
+	return time.Duration(d)
+}
@@ -1,6 +1,8 @@
 package db
 
 import (
+	"time"
+
 	"github.com/waylen888/tab-buddy/db/entity"
 )
 
@@ -8,22 +10,54 @@ type Database interface {
 	GetUser(ID string) (entity.User, error)
 	GetUserByUsername(username string) (entity.User, error)
 	CreateUser(username, displayName, email, password string, createType entity.UserCreateType) (entity.User, error)
+	GetUsersByEmail(email string) ([]entity.User, error)
+	UpdateUserPassword(userID string, password string) error
+	SetUserEmailVerified(userID string, email string, verifiedAt time.Time) error
+	UpdateUser(userID string, displayName *string, email *string) (entity.User, error)
+	DeleteUser(userID string) error
 	ExpenseAccessPermissions(userID string, expenseID string) error
 
+	CreateUserToken(args entity.CreateUserTokenArguments) (entity.UserToken, error)
+	GetUserToken(purpose entity.UserTokenPurpose, tokenHash string) (entity.UserToken, error)
+	UseUserToken(ID string) error
+	CountUserTokensSince(purpose entity.UserTokenPurpose, email string, since time.Time) (int, error)
+
+	GetUserIdentity(provider string, subject string) (entity.UserIdentity, error)
+	GetUserIdentities(userID string) ([]entity.UserIdentity, error)
+	CreateUserIdentity(identity entity.UserIdentity) (entity.UserIdentity, error)
+	DeleteUserIdentity(userID string, provider string) error
+	CreateOAuthState(state entity.OAuthState) error
+	TakeOAuthState(state string) (entity.OAuthState, error)
+
+	CreateRefreshToken(args entity.CreateRefreshTokenArguments) (entity.RefreshToken, error)
+	GetRefreshToken(tokenHash string) (entity.RefreshToken, error)
+	RevokeRefreshToken(ID string) error
+
+	CreateSession(args entity.CreateSessionArguments) (entity.Session, error)
+	GetSession(ID string) (entity.Session, error)
+	GetUserSessions(userID string) ([]entity.Session, error)
+	TouchSession(ID string, ip string, lastSeenAt time.Time) error
+	RevokeSession(ID string, userID string) error
+	RevokeUserSessions(userID string, exceptSessionID string) error
+
 	GetUserSetting(ID string) (entity.UserSetting, error)
-	UpdateUserSetting(userID string, themeMode *string, pushNotification *bool) (entity.UserSetting, error)
+	UpdateUserSetting(userID string, themeMode *string, pushNotification *bool, emailDigest *bool) (entity.UserSetting, error)
+	GetDigestRecipients(sentBefore time.Time) ([]entity.User, error)
+	SetDigestSentAt(userID string, sentAt time.Time) error
 
 	GetGroups(userID string) ([]entity.Group, error)
 	GetGroup(ID string, userID string) (entity.Group, error)
 	CreateGroup(name string, ownerID string) (entity.Group, error)
-	UpdateGroup(ID string, name string, convertToTwd bool) (entity.Group, error)
+	UpdateGroup(ID string, name string, convertToTwd bool, reminderIntervalDays *int) (entity.Group, error)
 	DeleteGroup(ID string) error
 	GetGroupMembers(ID string) ([]entity.User, error)
-	AddUserToGroupByUsername(groupID string, username *string, email *string) error
+	AddUserToGroupByUsername(groupID string, username *string, email *string) (entity.User, error)
 	RemoveMemeberFromGroup(groupID string, userID string) error
 	GetGroupExpenses(groupID string) ([]entity.ExpenseWithSplitUser, error)
 	GetExpense(ID string) (entity.ExpenseWithSplitUser, error)
 	CreateExpense(arg entity.CreateExpenseArguments) (entity.Expense, error)
+	CreateExpenses(groupID string, placeholders []entity.User, args []entity.CreateExpenseArguments) ([]entity.Expense, error)
+	RestoreGroup(args entity.RestoreGroupArguments) (entity.Group, error)
 	UpdateExpense(arg entity.UpdateExpenseArguments) (entity.Expense, error)
 
 	GetCurrency(code string) (entity.Currency, error)
@@ -32,11 +66,50 @@ type Database interface {
 	CreateComment(args entity.CreateCommentArguments) (entity.Comment, error)
 	DeleteComment(args entity.DeleteCommentArguments) error
 	GetExpenseComments(expenseID string) ([]entity.Comment, error)
+	GetComment(ID string) (entity.Comment, error)
 
 	CreateExpenseAttachments(args entity.CreateExpenseAttachmentsArgument) error
 	DeleteExpenseAttachment(ID string) error
 	GetExpenseAttachments(expenseID string) ([]entity.ExpenseAttachment, error)
 	GetExpenseAttachment(ID string) (entity.ExpenseAttachment, error)
+	GetCommentAttachments(expenseID string) ([]entity.ExpenseAttachment, error)
+	SetExpenseAttachmentThumbnail(ID string, size int64) error
+	AttachmentAccessPermissions(userID string, attachmentID string) error
+	GetExpenseGroupID(expenseID string) (string, error)
+	GetGroupAttachmentUsage(groupID string) (entity.AttachmentUsage, error)
+	GetAllAttachments() ([]entity.ExpenseAttachment, error)
+	GetLegacyExpensePhotos() ([]entity.LegacyExpensePhoto, error)
+	GetAttachmentIDs() ([]string, error)
+	RelinkLegacyExpensePhoto(ID string) error
+	DeleteLegacyExpensePhoto(ID string) error
+
+	GetUnprocessedAttachments(limit int) ([]entity.ExpenseAttachment, error)
+	SaveAttachmentText(text entity.AttachmentText) error
+	GetAttachmentText(attachmentID string) (entity.AttachmentText, error)
+
+	CreateOutboxMail(args entity.CreateOutboxMailArguments) (entity.OutboxMail, error)
+	GetDueOutboxMails(now time.Time, limit int) ([]entity.OutboxMail, error)
+	GetOutboxMails(status entity.OutboxMailStatus, limit int) ([]entity.OutboxMail, error)
+	MarkOutboxMailSent(ID string, sentAt time.Time) error
+	MarkOutboxMailFailed(ID string, status entity.OutboxMailStatus, lastError string, nextAttemptAt time.Time) error
+	RetryOutboxMail(ID string) error
+
+	CreateNotification(args entity.CreateNotificationArguments) (entity.Notification, error)
+	GetNotifications(userID string, before string, limit int, unreadOnly bool) ([]entity.Notification, error)
+	CountUnreadNotifications(userID string) (int, error)
+	MarkNotificationRead(userID string, ID string, readAt time.Time) error
+	MarkAllNotificationsRead(userID string, readAt time.Time) error
+	GetNotificationPreferences(userID string) ([]entity.NotificationPreference, error)
+	SetNotificationPreferences(userID string, preferences []entity.NotificationPreference) error
+
+	CreatePushSubscription(args entity.CreatePushSubscriptionArguments) (entity.PushSubscription, error)
+	GetUserPushSubscriptions(userID string) ([]entity.PushSubscription, error)
+	DeletePushSubscription(userID string, endpoint string) error
+
+	CreateReminder(args entity.CreateReminderArguments) (entity.Reminder, error)
+	GetLastReminder(groupID string, userID string) (entity.Reminder, error)
+	GetGroupsWithReminders() ([]entity.Group, error)
 
+	Backup(path string) error
 	Close() error
 }
@@ -0,0 +1,18 @@
+package entity
+
+// RestoreGroupArguments is a whole group read from an archive, its IDs are all new and its
+// users are already mapped to users of this server.
+type RestoreGroupArguments struct {
+	Group Group
+	// Placeholders are created for people of the archive without a user here.
+	Placeholders []User
+	MemberIDs    []string
+	Expenses     []RestoreExpense
+}
+
+type RestoreExpense struct {
+	ExpenseWithSplitUser
+	Comments []Comment
+	// Attachments of comments have their CommentID set, their files are stored by the caller.
+	Attachments []ExpenseAttachment
+}
@@ -0,0 +1,26 @@
+package entity
+
+import "time"
+
+type AttachmentTextStatus uint8
+
+var (
+	AttachmentTextStatusDone AttachmentTextStatus = 1
+	// AttachmentTextStatusSkipped is set when the extractor does not handle the file type.
+	AttachmentTextStatusSkipped AttachmentTextStatus = 2
+	AttachmentTextStatusFailed  AttachmentTextStatus = 3
+)
+
+// AttachmentText is the text extracted from an attachment and the receipt fields parsed from it,
+// attachments without one are still waiting to be processed.
+type AttachmentText struct {
+	AttachmentID string
+	Status       AttachmentTextStatus
+	Text         string
+	// Total is the amount as a decimal string, empty when none was found.
+	Total    string
+	Date     *time.Time
+	Merchant string
+	Error    string
+	CreateAt time.Time
+}
@@ -1,6 +1,10 @@
 package entity
 
-import "time"
+import (
+	"time"
+
+	"github.com/waylen888/tab-buddy/calc"
+)
 
 type Expense struct {
 	ID           string    `db:"id"`
@@ -53,3 +57,19 @@ type UpdateExpenseArguments struct {
 	Note         string
 	SplitUsers   []SplitUser
 }
+
+// TWDExpense converts the expense to TWD for calc.Balances.
+func (expense ExpenseWithSplitUser) TWDExpense() calc.Expense {
+	splitUsers := make([]calc.SplitUser, 0, len(expense.SplitUsers))
+	for _, su := range expense.SplitUsers {
+		splitUsers = append(splitUsers, calc.SplitUser{
+			ID:   su.ID,
+			Paid: su.Paid,
+			Owed: su.Owed,
+		})
+	}
+	return calc.Expense{
+		Amount:     calc.TWDBool(true).ToTWD(expense.Amount, expense.TWDRate, 4),
+		SplitUsers: splitUsers,
+	}
+}
@@ -10,6 +10,8 @@ type Group struct {
 	ID           string
 	Name         string
 	ConvertToTwd calc.TWDBool
-	CreateAt     time.Time
-	UpdateAt     time.Time
+	// ReminderIntervalDays is how often members who owe are reminded automatically, 0 when never.
+	ReminderIntervalDays int
+	CreateAt             time.Time
+	UpdateAt             time.Time
 }
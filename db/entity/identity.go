@@ -0,0 +1,24 @@
+package entity
+
+import "time"
+
+// UserIdentity links an account of an external identity provider to a user.
+type UserIdentity struct {
+	Provider string
+	Subject  string
+	UserID   string
+	Email    string
+	CreateAt time.Time
+}
+
+// OAuthState is a pending authorization request, consumed once by the callback.
+type OAuthState struct {
+	State        string
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	// LinkUserID is set when a logged in user links another provider.
+	LinkUserID string
+	ExpireAt   time.Time
+	CreateAt   time.Time
+}
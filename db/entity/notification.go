@@ -0,0 +1,37 @@
+package entity
+
+import "time"
+
+// Notification is an entry in a user's in-app inbox.
+type Notification struct {
+	ID        string
+	UserID    string
+	Event     string
+	GroupID   string
+	ExpenseID string
+	ActorID   string
+	Title     string
+	Body      string
+	Link      string
+	ReadAt    *time.Time
+	CreateAt  time.Time
+}
+
+type CreateNotificationArguments struct {
+	UserID    string
+	Event     string
+	GroupID   string
+	ExpenseID string
+	ActorID   string
+	Title     string
+	Body      string
+	Link      string
+}
+
+// NotificationPreference turns a channel on or off for one event type,
+// events and channels without a stored preference use the defaults of the notify package.
+type NotificationPreference struct {
+	Event   string
+	Channel string
+	Enabled bool
+}
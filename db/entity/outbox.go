@@ -0,0 +1,36 @@
+package entity
+
+import "time"
+
+type OutboxMailStatus uint8
+
+var (
+	OutboxMailStatusQueued OutboxMailStatus = 0
+	OutboxMailStatusSent   OutboxMailStatus = 1
+	// OutboxMailStatusDead is set after the last attempt failed, it is only sent again when retried by hand.
+	OutboxMailStatusDead OutboxMailStatus = 2
+)
+
+// OutboxMail is a rendered email waiting to be sent by the outbox worker.
+type OutboxMail struct {
+	ID string
+	// Recipients are the addresses joined by ",".
+	Recipients    string
+	Subject       string
+	Text          string
+	HTML          string
+	Status        OutboxMailStatus
+	Attempts      int
+	LastError     string
+	NextAttemptAt time.Time
+	SentAt        *time.Time
+	CreateAt      time.Time
+	UpdateAt      time.Time
+}
+
+type CreateOutboxMailArguments struct {
+	Recipients []string
+	Subject    string
+	Text       string
+	HTML       string
+}
@@ -3,15 +3,39 @@ package entity
 import "time"
 
 type CreateExpenseAttachmentsArgument struct {
-	ExpenseID   string
+	ExpenseID string
+	// CommentID is set for attachments posted in the comments of the expense.
+	CommentID   *string
 	Attachments []ExpenseAttachment
 }
 
+// LegacyExpensePhoto is a row of expense_photo, which held attachments before expense_attachment.
+type LegacyExpensePhoto struct {
+	ID        string
+	ExpenseID string
+	Filename  string
+	Size      int64
+	MIME      string
+	CreateAt  time.Time
+	UpdateAt  time.Time
+}
+
+// AttachmentUsage is the storage used by the attachments of a group.
+type AttachmentUsage struct {
+	Count int64
+	Bytes int64
+}
+
 type ExpenseAttachment struct {
-	ID       string
-	Filename string
-	Size     int64
-	MIME     string
-	CreateAt time.Time
-	UpdateAt time.Time
+	ID        string
+	ExpenseID string
+	Filename  string
+	Size      int64
+	// ThumbnailSize is 0 until a thumbnail was generated.
+	ThumbnailSize int64
+	MIME          string
+	// CommentID is the comment owning the attachment, nil when it belongs to the expense itself.
+	CommentID *string
+	CreateAt  time.Time
+	UpdateAt  time.Time
 }
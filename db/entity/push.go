@@ -0,0 +1,22 @@
+package entity
+
+import "time"
+
+// PushSubscription is a browser registered to receive web push notifications for a user.
+type PushSubscription struct {
+	ID        string
+	UserID    string
+	Endpoint  string
+	P256dh    string
+	Auth      string
+	UserAgent string
+	CreateAt  time.Time
+}
+
+type CreatePushSubscriptionArguments struct {
+	UserID    string
+	Endpoint  string
+	P256dh    string
+	Auth      string
+	UserAgent string
+}
@@ -0,0 +1,26 @@
+package entity
+
+import "time"
+
+// Reminder records a member being reminded of what they owe in a group.
+type Reminder struct {
+	ID      string
+	GroupID string
+	UserID  string
+	// SentBy is the member who sent it, empty for automatic reminders.
+	SentBy   string
+	Amount   string
+	CreateAt time.Time
+}
+
+type CreateReminderArguments struct {
+	GroupID string
+	UserID  string
+	SentBy  string
+	Amount  string
+	// CreateAt is when the reminder is sent.
+	CreateAt time.Time
+	// Since is the start of the throttle window, the reminder is not created
+	// when the user was already reminded in the group after it.
+	Since time.Time
+}
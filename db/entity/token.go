@@ -0,0 +1,36 @@
+package entity
+
+import "time"
+
+type RefreshToken struct {
+	ID        string
+	UserID    string
+	SessionID string
+	TokenHash string
+	ExpireAt  time.Time
+	RevokeAt  *time.Time
+	CreateAt  time.Time
+}
+
+type CreateRefreshTokenArguments struct {
+	UserID    string
+	SessionID string
+	TokenHash string
+	ExpireAt  time.Time
+}
+
+type Session struct {
+	ID         string
+	UserID     string
+	UserAgent  string
+	IP         string
+	CreateAt   time.Time
+	LastSeenAt time.Time
+	RevokeAt   *time.Time
+}
+
+type CreateSessionArguments struct {
+	UserID    string
+	UserAgent string
+	IP        string
+}
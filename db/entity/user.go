@@ -11,17 +11,21 @@ type UserCreateType uint8
 var (
 	UserCreateTypeDefault UserCreateType = 0
 	UserCreateTypeGoogle  UserCreateType = 1
+	UserCreateTypeOIDC    UserCreateType = 2
+	// UserCreateTypePlaceholder is a person named in an import who has no account, they cannot log in.
+	UserCreateTypePlaceholder UserCreateType = 3
 )
 
 type User struct {
-	ID          string
-	Username    string
-	DisplayName string
-	Email       string
-	CreateType  UserCreateType
-	Password    string
-	CreateAt    time.Time
-	UpdateAt    time.Time
+	ID              string
+	Username        string
+	DisplayName     string
+	Email           string
+	EmailVerifiedAt *time.Time
+	CreateType      UserCreateType
+	Password        string
+	CreateAt        time.Time
+	UpdateAt        time.Time
 }
 
 func (u User) CheckPassword(plainPassword string) error {
@@ -31,4 +35,33 @@ func (u User) CheckPassword(plainPassword string) error {
 type UserSetting struct {
 	ThemeMode        string
 	PushNotification bool
+	// EmailDigest is true unless the user opted out of digest emails.
+	EmailDigest bool
+}
+
+type UserTokenPurpose uint8
+
+var (
+	UserTokenPurposeEmailVerification UserTokenPurpose = 1
+	UserTokenPurposePasswordReset     UserTokenPurpose = 2
+)
+
+// UserToken is a single-use token sent to the user by email.
+type UserToken struct {
+	ID        string
+	UserID    string
+	Purpose   UserTokenPurpose
+	Email     string
+	TokenHash string
+	ExpireAt  time.Time
+	UseAt     *time.Time
+	CreateAt  time.Time
+}
+
+type CreateUserTokenArguments struct {
+	UserID    string
+	Purpose   UserTokenPurpose
+	Email     string
+	TokenHash string
+	ExpireAt  time.Time
 }
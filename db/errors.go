@@ -3,6 +3,9 @@ package db
 import "errors"
 
 var (
-	ErrUserAlreadyInGroup  = errors.New("user already in group")
-	ErrUserStillHasExpense = errors.New("the user still has outstanding expenses")
+	ErrUserAlreadyInGroup    = errors.New("user already in group")
+	ErrUserStillHasExpense   = errors.New("the user still has outstanding expenses")
+	ErrTokenAlreadyUsed      = errors.New("token already used")
+	ErrIdentityAlreadyLinked = errors.New("identity already linked to a user")
+	ErrAlreadyReminded       = errors.New("user already reminded")
 )
@@ -0,0 +1,93 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/waylen888/tab-buddy/db/entity"
+)
+
+// RestoreGroup creates a group with all its expenses, comments and attachments in one transaction.
+func (s *sqlite) RestoreGroup(args entity.RestoreGroupArguments) (entity.Group, error) {
+	currencies := make(map[string]entity.Currency)
+	for _, expense := range args.Expenses {
+		if _, ok := currencies[expense.CurrencyCode]; ok {
+			continue
+		}
+		currency, err := s.GetCurrency(expense.CurrencyCode)
+		if err != nil {
+			return entity.Group{}, fmt.Errorf("get currency %s: %w", expense.CurrencyCode, err)
+		}
+		currencies[expense.CurrencyCode] = currency
+	}
+
+	group := args.Group
+	return group, s.WithTx(context.TODO(), func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO "group" (id, name, convert_to_twd, reminder_interval_days, create_at, update_at)
+			VALUES (@id, @name, @convert_to_twd, @reminder_interval_days, @create_at, @update_at)`,
+			sql.Named("id", group.ID),
+			sql.Named("name", group.Name),
+			sql.Named("convert_to_twd", group.ConvertToTwd),
+			sql.Named("reminder_interval_days", group.ReminderIntervalDays),
+			sql.Named("create_at", group.CreateAt),
+			sql.Named("update_at", group.UpdateAt),
+		)
+		if err != nil {
+			return fmt.Errorf("insert into group: %w", err)
+		}
+		now := time.Now()
+		for _, user := range args.Placeholders {
+			if err := insertPlaceholder(ctx, tx, user, now); err != nil {
+				return err
+			}
+		}
+		for _, userID := range args.MemberIDs {
+			if err := insertGroupMember(ctx, tx, group.ID, userID); err != nil {
+				return fmt.Errorf("add member %s: %w", userID, err)
+			}
+		}
+
+		for _, expense := range args.Expenses {
+			err := insertExpense(ctx, tx, group.ID, expense.Expense, expense.SplitUsers, currencies[expense.CurrencyCode])
+			if err != nil {
+				return fmt.Errorf("insert expense %s: %w", expense.ID, err)
+			}
+			for _, comment := range expense.Comments {
+				_, err := tx.ExecContext(ctx,
+					`INSERT INTO expense_comment (id, expense_id, content, create_by, create_at, update_at)
+					VALUES (@id, @expense_id, @content, @create_by, @create_at, @update_at)`,
+					sql.Named("id", comment.ID),
+					sql.Named("expense_id", expense.ID),
+					sql.Named("content", comment.Content),
+					sql.Named("create_by", comment.CreateBy),
+					sql.Named("create_at", comment.CreateAt),
+					sql.Named("update_at", comment.UpdateAt),
+				)
+				if err != nil {
+					return fmt.Errorf("insert comment %s: %w", comment.ID, err)
+				}
+			}
+			for _, attachment := range expense.Attachments {
+				_, err := tx.ExecContext(ctx,
+					`INSERT INTO expense_attachment (id, expense_id, comment_id, filename, size, thumbnail_size, mime, create_at, update_at)
+					VALUES (@id, @expense_id, @comment_id, @filename, @size, 0, @mime, @create_at, @update_at)`,
+					sql.Named("id", attachment.ID),
+					sql.Named("expense_id", expense.ID),
+					sql.Named("comment_id", attachment.CommentID),
+					sql.Named("filename", attachment.Filename),
+					sql.Named("size", attachment.Size),
+					sql.Named("mime", attachment.MIME),
+					sql.Named("create_at", attachment.CreateAt),
+					sql.Named("update_at", attachment.UpdateAt),
+				)
+				if err != nil {
+					return fmt.Errorf("insert attachment %s: %w", attachment.ID, err)
+				}
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,55 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/georgysavva/scany/v2/sqlscan"
+	"github.com/waylen888/tab-buddy/db/entity"
+)
+
+func (s *sqlite) GetUnprocessedAttachments(limit int) ([]entity.ExpenseAttachment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	attachments := make([]entity.ExpenseAttachment, 0)
+	err := sqlscan.Select(ctx, s.rwDB, &attachments, `
+		SELECT id, filename, size, thumbnail_size, mime, create_at, update_at
+		FROM expense_attachment
+		WHERE id NOT IN (SELECT attachment_id FROM attachment_text)
+		ORDER BY create_at
+		LIMIT @limit`,
+		sql.Named("limit", limit),
+	)
+	return attachments, err
+}
+
+func (s *sqlite) SaveAttachmentText(text entity.AttachmentText) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	_, err := s.rwDB.ExecContext(ctx, `
+		INSERT OR REPLACE INTO attachment_text (attachment_id, status, text, total, date, merchant, error, create_at)
+		VALUES (@attachment_id, @status, @text, @total, @date, @merchant, @error, @create_at)`,
+		sql.Named("attachment_id", text.AttachmentID),
+		sql.Named("status", text.Status),
+		sql.Named("text", text.Text),
+		sql.Named("total", text.Total),
+		sql.Named("date", text.Date),
+		sql.Named("merchant", text.Merchant),
+		sql.Named("error", text.Error),
+		sql.Named("create_at", text.CreateAt),
+	)
+	return err
+}
+
+func (s *sqlite) GetAttachmentText(attachmentID string) (entity.AttachmentText, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	var text entity.AttachmentText
+	err := sqlscan.Get(ctx, s.rwDB, &text, `
+		SELECT attachment_id, status, text, total, date, merchant, error, create_at
+		FROM attachment_text
+		WHERE attachment_id = @attachment_id`,
+		sql.Named("attachment_id", attachmentID),
+	)
+	return text, err
+}
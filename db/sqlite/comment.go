@@ -26,6 +26,21 @@ func (s *sqlite) GetExpenseComments(expenseID string) ([]entity.Comment, error)
 	)
 }
 
+func (s *sqlite) GetComment(ID string) (entity.Comment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	var comment entity.Comment
+	return comment, sqlscan.Get(ctx, s.rwDB, &comment,
+		`
+		SELECT 
+			ec.id, ec.expense_id, ec.content, ec.create_by, ec.create_at, ec.update_at, user.display_name
+		FROM expense_comment ec
+		JOIN user ON ec.create_by = user.id
+		WHERE ec.id = @id`,
+		sql.Named("id", ID),
+	)
+}
+
 func (s *sqlite) CreateComment(args entity.CreateCommentArguments) (entity.Comment, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
 	defer cancel()
@@ -12,12 +12,14 @@ func (s *sqlite) CreateExpenseAttachments(args entity.CreateExpenseAttachmentsAr
 	return s.WithTx(context.Background(), func(ctx context.Context, tx *sql.Tx) error {
 		for _, attachment := range args.Attachments {
 			_, err := tx.ExecContext(ctx, `
-			INSERT INTO expense_attachment (id, expense_id, filename, size, mime, create_at, update_at) 
-			VALUES (@id, @expense_id, @filename, @size, @mime, @create_at, @update_at);`,
+			INSERT INTO expense_attachment (id, expense_id, comment_id, filename, size, thumbnail_size, mime, create_at, update_at) 
+			VALUES (@id, @expense_id, @comment_id, @filename, @size, @thumbnail_size, @mime, @create_at, @update_at);`,
 				sql.Named("id", attachment.ID),
 				sql.Named("expense_id", args.ExpenseID),
+				sql.Named("comment_id", args.CommentID),
 				sql.Named("filename", attachment.Filename),
 				sql.Named("size", attachment.Size),
+				sql.Named("thumbnail_size", attachment.ThumbnailSize),
 				sql.Named("mime", attachment.MIME),
 				sql.Named("create_at", attachment.CreateAt),
 				sql.Named("update_at", attachment.UpdateAt),
@@ -34,9 +36,22 @@ func (s *sqlite) GetExpenseAttachments(expenseID string) ([]entity.ExpenseAttach
 	eps := make([]entity.ExpenseAttachment, 0)
 	return eps, s.WithTx(context.Background(), func(ctx context.Context, tx *sql.Tx) error {
 		return sqlscan.Select(ctx, tx, &eps, `
-			SELECT id, filename, size, mime, create_at, update_at
+			SELECT id, filename, size, thumbnail_size, mime, create_at, update_at
 			FROM expense_attachment
-			WHERE expense_id = @expense_id;`,
+			WHERE expense_id = @expense_id AND comment_id IS NULL;`,
+			sql.Named("expense_id", expenseID),
+		)
+	})
+}
+
+// GetCommentAttachments returns the attachments of all comments of an expense.
+func (s *sqlite) GetCommentAttachments(expenseID string) ([]entity.ExpenseAttachment, error) {
+	eps := make([]entity.ExpenseAttachment, 0)
+	return eps, s.WithTx(context.Background(), func(ctx context.Context, tx *sql.Tx) error {
+		return sqlscan.Select(ctx, tx, &eps, `
+			SELECT id, filename, size, thumbnail_size, mime, comment_id, create_at, update_at
+			FROM expense_attachment
+			WHERE expense_id = @expense_id AND comment_id IS NOT NULL;`,
 			sql.Named("expense_id", expenseID),
 		)
 	})
@@ -46,7 +61,7 @@ func (s *sqlite) GetExpenseAttachment(ID string) (entity.ExpenseAttachment, erro
 	var ep entity.ExpenseAttachment
 	return ep, s.WithTx(context.Background(), func(ctx context.Context, tx *sql.Tx) error {
 		return sqlscan.Get(ctx, tx, &ep, `
-		SELECT id, filename, size, mime, create_at, update_at
+		SELECT id, expense_id, filename, size, thumbnail_size, mime, comment_id, create_at, update_at
 		FROM expense_attachment
 		WHERE id = @id;`,
 			sql.Named("id", ID),
@@ -64,3 +79,131 @@ func (s *sqlite) DeleteExpenseAttachment(ID string) error {
 		return err
 	})
 }
+
+func (s *sqlite) SetExpenseAttachmentThumbnail(ID string, size int64) error {
+	return s.WithTx(context.Background(), func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+		UPDATE expense_attachment
+		SET thumbnail_size = @thumbnail_size
+		WHERE id = @id;`,
+			sql.Named("id", ID),
+			sql.Named("thumbnail_size", size),
+		)
+		return err
+	})
+}
+
+func (s *sqlite) AttachmentAccessPermissions(userID string, attachmentID string) error {
+	ctx, cancel := context.WithTimeout(context.TODO(), s.timeout)
+	defer cancel()
+	var ok bool
+	err := sqlscan.Get(ctx, s.rwDB, &ok, `
+		SELECT 1
+		FROM expense_attachment
+		JOIN group_expense
+		ON group_expense.expense_id = expense_attachment.expense_id
+		JOIN group_member
+		ON group_member.group_id = group_expense.group_id
+		WHERE expense_attachment.id = @attachment_id AND group_member.user_id = @user_id`,
+		sql.Named("attachment_id", attachmentID),
+		sql.Named("user_id", userID),
+	)
+	return err
+}
+
+func (s *sqlite) GetExpenseGroupID(expenseID string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.TODO(), s.timeout)
+	defer cancel()
+	var groupID string
+	err := sqlscan.Get(ctx, s.rwDB, &groupID, `
+		SELECT group_id FROM group_expense WHERE expense_id = @expense_id`,
+		sql.Named("expense_id", expenseID),
+	)
+	return groupID, err
+}
+
+func (s *sqlite) GetGroupAttachmentUsage(groupID string) (entity.AttachmentUsage, error) {
+	ctx, cancel := context.WithTimeout(context.TODO(), s.timeout)
+	defer cancel()
+	var usage entity.AttachmentUsage
+	err := sqlscan.Get(ctx, s.rwDB, &usage, `
+		SELECT COUNT(*) AS count, COALESCE(SUM(size + thumbnail_size), 0) AS bytes
+		FROM expense_attachment
+		JOIN group_expense
+		ON group_expense.expense_id = expense_attachment.expense_id
+		WHERE group_expense.group_id = @group_id`,
+		sql.Named("group_id", groupID),
+	)
+	return usage, err
+}
+
+func (s *sqlite) GetAllAttachments() ([]entity.ExpenseAttachment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	attachments := make([]entity.ExpenseAttachment, 0)
+	err := sqlscan.Select(ctx, s.rwDB, &attachments, `
+		SELECT id, filename, size, thumbnail_size, mime, create_at, update_at
+		FROM expense_attachment`,
+	)
+	return attachments, err
+}
+
+func (s *sqlite) GetLegacyExpensePhotos() ([]entity.LegacyExpensePhoto, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	photos := make([]entity.LegacyExpensePhoto, 0)
+	err := sqlscan.Select(ctx, s.rwDB, &photos, `
+		SELECT id, expense_id, filename, size, mime, create_at, update_at
+		FROM expense_photo`,
+	)
+	return photos, err
+}
+
+// GetAttachmentIDs returns the ids of all attachments and legacy photos, the keys their
+// files are stored under.
+func (s *sqlite) GetAttachmentIDs() ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	ids := make([]string, 0)
+	err := sqlscan.Select(ctx, s.rwDB, &ids, `
+		SELECT id FROM expense_attachment
+		UNION
+		SELECT id FROM expense_photo`,
+	)
+	return ids, err
+}
+
+// RelinkLegacyExpensePhoto moves a legacy photo row to expense_attachment, keeping its id
+// so the stored file still matches.
+func (s *sqlite) RelinkLegacyExpensePhoto(ID string) error {
+	return s.WithTx(context.Background(), func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+		INSERT OR IGNORE INTO expense_attachment (id, expense_id, filename, size, thumbnail_size, mime, create_at, update_at)
+		SELECT id, expense_id, filename, size, 0, mime, create_at, update_at
+		FROM expense_photo
+		WHERE id = @id
+		LIMIT 1;`,
+			sql.Named("id", ID),
+		)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+		DELETE FROM expense_photo
+		WHERE id = @id;`,
+			sql.Named("id", ID),
+		)
+		return err
+	})
+}
+
+func (s *sqlite) DeleteLegacyExpensePhoto(ID string) error {
+	return s.WithTx(context.Background(), func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+		DELETE FROM expense_photo
+		WHERE id = @id;`,
+			sql.Named("id", ID),
+		)
+		return err
+	})
+}
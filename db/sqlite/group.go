@@ -85,7 +85,7 @@ func (s *sqlite) CreateGroup(name string, ownerID string) (entity.Group, error)
 	})
 }
 
-func (s *sqlite) UpdateGroup(ID string, name string, convertToTwd bool) (entity.Group, error) {
+func (s *sqlite) UpdateGroup(ID string, name string, convertToTwd bool, reminderIntervalDays *int) (entity.Group, error) {
 	ctx, cancel := context.WithTimeout(context.TODO(), s.timeout)
 	defer cancel()
 	var group entity.Group
@@ -94,11 +94,13 @@ func (s *sqlite) UpdateGroup(ID string, name string, convertToTwd bool) (entity.
 		`UPDATE "group" 
 		SET name = @name, 
 		convert_to_twd = @convert_to_twd,
+		reminder_interval_days = COALESCE(@reminder_interval_days, reminder_interval_days),
 		update_at = @update_at
 		WHERE id = @id RETURNING *`,
 		sql.Named("id", ID),
 		sql.Named("name", name),
 		sql.Named("convert_to_twd", convertToTwd),
+		sql.Named("reminder_interval_days", reminderIntervalDays),
 		sql.Named("update_at", time.Now()),
 	)
 	return group, err
@@ -122,7 +124,7 @@ func (s *sqlite) GetGroupExpenses(groupID string) ([]entity.ExpenseWithSplitUser
 
 	if err := sqlscan.Select(
 		ctx, s.rwDB, &expenses,
-		`SELECT id, amount, description, date, currency_code, category, twd_rate, create_at, update_at, created_by
+		`SELECT id, amount, description, date, currency_code, category, twd_rate, note, create_at, update_at, created_by
 		FROM expense 
 		JOIN group_expense 
 			ON expense.id = group_expense.expense_id 
@@ -202,6 +204,57 @@ func (s *sqlite) CreateExpense(args entity.CreateExpenseArguments) (entity.Expen
 	if err != nil {
 		return entity.Expense{}, err
 	}
+	tx, err := s.rwDB.BeginTx(ctx, nil)
+	if err != nil {
+		return entity.Expense{}, err
+	}
+	defer tx.Rollback()
+
+	expense, err := createExpense(ctx, tx, args, currency)
+	if err != nil {
+		return expense, err
+	}
+	return expense, tx.Commit()
+}
+
+// CreateExpenses adds the placeholders to the group and creates the expenses in one transaction,
+// nothing is written when any of them fails.
+func (s *sqlite) CreateExpenses(groupID string, placeholders []entity.User, args []entity.CreateExpenseArguments) ([]entity.Expense, error) {
+	currencies := make(map[string]entity.Currency)
+	for _, arg := range args {
+		if _, ok := currencies[arg.CurrencyCode]; ok {
+			continue
+		}
+		currency, err := s.GetCurrency(arg.CurrencyCode)
+		if err != nil {
+			return nil, fmt.Errorf("get currency %s: %w", arg.CurrencyCode, err)
+		}
+		currencies[arg.CurrencyCode] = currency
+	}
+
+	expenses := make([]entity.Expense, 0, len(args))
+	return expenses, s.WithTx(context.TODO(), func(ctx context.Context, tx *sql.Tx) error {
+		now := time.Now()
+		for _, user := range placeholders {
+			if err := insertPlaceholder(ctx, tx, user, now); err != nil {
+				return err
+			}
+			if err := insertGroupMember(ctx, tx, groupID, user.ID); err != nil {
+				return fmt.Errorf("add placeholder %s: %w", user.DisplayName, err)
+			}
+		}
+		for _, arg := range args {
+			expense, err := createExpense(ctx, tx, arg, currencies[arg.CurrencyCode])
+			if err != nil {
+				return fmt.Errorf("create expense %q: %w", arg.Description, err)
+			}
+			expenses = append(expenses, expense)
+		}
+		return nil
+	})
+}
+
+func createExpense(ctx context.Context, tx *sql.Tx, args entity.CreateExpenseArguments, currency entity.Currency) (entity.Expense, error) {
 	expense := entity.Expense{
 		ID:           xid.New().String(),
 		Amount:       args.Amount,
@@ -214,13 +267,11 @@ func (s *sqlite) CreateExpense(args entity.CreateExpenseArguments) (entity.Expen
 		CreateAt:     time.Now(),
 		CreatedBy:    args.CreateByUserID,
 	}
-	tx, err := s.rwDB.BeginTx(ctx, nil)
-	if err != nil {
-		return expense, err
-	}
-	defer tx.Rollback()
+	return expense, insertExpense(ctx, tx, args.GroupID, expense, args.SplitUsers, currency)
+}
 
-	_, err = tx.ExecContext(
+func insertExpense(ctx context.Context, tx *sql.Tx, groupID string, expense entity.Expense, splitUsers []entity.SplitUser, currency entity.Currency) error {
+	_, err := tx.ExecContext(
 		ctx,
 		`INSERT INTO expense (id, amount, description, date, currency_code, category, twd_rate, note, create_at, update_at, created_by) 
 		VALUES (@id, @amount, @description, @date, @currency_code, @category, @twd_rate, @note, @create_at, @update_at, @created_by)`,
@@ -237,17 +288,17 @@ func (s *sqlite) CreateExpense(args entity.CreateExpenseArguments) (entity.Expen
 		sql.Named("created_by", expense.CreatedBy),
 	)
 	if err != nil {
-		return expense, err
+		return err
 	}
 	_, err = tx.ExecContext(ctx, `INSERT INTO group_expense(group_id, expense_id) VALUES (@group_id, @expense_id);`,
-		sql.Named("group_id", args.GroupID),
+		sql.Named("group_id", groupID),
 		sql.Named("expense_id", expense.ID),
 	)
 	if err != nil {
-		return expense, err
+		return err
 	}
 
-	for _, user := range args.SplitUsers {
+	for _, user := range splitUsers {
 		_, err = tx.ExecContext(
 			ctx,
 			`INSERT INTO user_expense(user_id, expense_id, type, amount, paid, owed) 
@@ -256,7 +307,7 @@ func (s *sqlite) CreateExpense(args entity.CreateExpenseArguments) (entity.Expen
 			sql.Named("expense_id", expense.ID),
 			sql.Named("type", 0),
 			sql.Named("amount",
-				calc.SplitValue(expense.Amount, lo.Map(args.SplitUsers, func(su entity.SplitUser, _ int) calc.SplitUser {
+				calc.SplitValue(expense.Amount, lo.Map(splitUsers, func(su entity.SplitUser, _ int) calc.SplitUser {
 					return calc.SplitUser{
 						ID:   su.ID,
 						Paid: su.Paid,
@@ -268,10 +319,36 @@ func (s *sqlite) CreateExpense(args entity.CreateExpenseArguments) (entity.Expen
 			sql.Named("owed", user.Owed),
 		)
 		if err != nil {
-			return expense, err
+			return err
 		}
 	}
-	return expense, tx.Commit()
+	return nil
+}
+
+// insertPlaceholder creates a user without an account, who cannot log in.
+func insertPlaceholder(ctx context.Context, tx *sql.Tx, user entity.User, now time.Time) error {
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO "user" (id, username, display_name, email, create_type, password, create_at, update_at)
+		VALUES (@id, @username, @display_name, '', @create_type, '', @create_at, @create_at)`,
+		sql.Named("id", user.ID),
+		sql.Named("username", user.Username),
+		sql.Named("display_name", user.DisplayName),
+		sql.Named("create_type", entity.UserCreateTypePlaceholder),
+		sql.Named("create_at", now),
+	)
+	if err != nil {
+		return fmt.Errorf("create placeholder %s: %w", user.DisplayName, err)
+	}
+	return nil
+}
+
+func insertGroupMember(ctx context.Context, tx *sql.Tx, groupID string, userID string) error {
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO group_member (group_id, user_id) VALUES (@group_id, @user_id)`,
+		sql.Named("group_id", groupID),
+		sql.Named("user_id", userID),
+	)
+	return err
 }
 
 func (s *sqlite) UpdateExpense(args entity.UpdateExpenseArguments) (entity.Expense, error) {
@@ -373,31 +450,44 @@ func (s *sqlite) GetGroupMembers(ID string) ([]entity.User, error) {
 	return members, nil
 }
 
-func (s *sqlite) AddUserToGroupByUsername(groupID string, username *string, email *string) error {
-	ctx, cancel := context.WithTimeout(context.TODO(), s.timeout)
-	defer cancel()
-	_, err := s.rwDB.ExecContext(
-		ctx,
-		`INSERT INTO group_member (group_id, user_id) 
-		VALUES (
-			(SELECT id FROM "group" WHERE id = @group_id), 
-			(
-				SELECT id FROM "user" 
-				WHERE 1 = 1 
-				AND (1 = (CASE WHEN @username IS NULL THEN 1 ELSE 0 END) OR username = @username)
-				AND (1 = (CASE WHEN @email IS NULL THEN 1 ELSE 0 END) OR email = @email)
-			)
-		)`,
-		sql.Named("group_id", groupID),
-		sql.Named("username", username),
-		sql.Named("email", email),
-	)
+// AddUserToGroupByUsername adds the user matching username and email to the group and returns it,
+// sql.ErrNoRows is returned when there is no such user.
+// AddUserToGroupByUsername adds the user with either username or email to the group, exactly one
+// of them must be given. Placeholders are never matched, it returns sql.ErrNoRows when there is no such user.
+func (s *sqlite) AddUserToGroupByUsername(groupID string, username *string, email *string) (entity.User, error) {
+	if (lo.FromPtr(username) == "") == (lo.FromPtr(email) == "") {
+		return entity.User{}, errors.New("exactly one of username and email is required")
+	}
+	var user entity.User
+	err := s.WithTx(context.TODO(), func(ctx context.Context, tx *sql.Tx) error {
+		err := sqlscan.Get(ctx, tx, &user, `
+			SELECT * FROM "user"
+			WHERE create_type != @placeholder
+			AND (1 = (CASE WHEN @username IS NULL THEN 1 ELSE 0 END) OR username = @username)
+			AND (1 = (CASE WHEN @email IS NULL THEN 1 ELSE 0 END) OR email = @email)
+			LIMIT 1`,
+			sql.Named("username", lo.EmptyableToPtr(lo.FromPtr(username))),
+			sql.Named("email", lo.EmptyableToPtr(lo.FromPtr(email))),
+			sql.Named("placeholder", entity.UserCreateTypePlaceholder),
+		)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(
+			ctx,
+			`INSERT INTO group_member (group_id, user_id) 
+			VALUES ((SELECT id FROM "group" WHERE id = @group_id), @user_id)`,
+			sql.Named("group_id", groupID),
+			sql.Named("user_id", user.ID),
+		)
+		return err
+	})
 
 	var sqliteErr sqlite3.Error
 	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == 1555 {
-		return db.ErrUserAlreadyInGroup
+		return user, db.ErrUserAlreadyInGroup
 	}
-	return err
+	return user, err
 }
 
 func (s *sqlite) RemoveMemeberFromGroup(groupID string, userID string) error {
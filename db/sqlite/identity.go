@@ -0,0 +1,108 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/georgysavva/scany/v2/sqlscan"
+	"github.com/mattn/go-sqlite3"
+	"github.com/waylen888/tab-buddy/db"
+	"github.com/waylen888/tab-buddy/db/entity"
+)
+
+func (s *sqlite) GetUserIdentity(provider string, subject string) (entity.UserIdentity, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	var identity entity.UserIdentity
+	return identity, sqlscan.Get(ctx, s.rwDB, &identity,
+		`SELECT provider, subject, user_id, email, create_at
+		FROM user_identity
+		WHERE provider = @provider AND subject = @subject`,
+		sql.Named("provider", provider),
+		sql.Named("subject", subject),
+	)
+}
+
+func (s *sqlite) GetUserIdentities(userID string) ([]entity.UserIdentity, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	identities := make([]entity.UserIdentity, 0)
+	return identities, sqlscan.Select(ctx, s.rwDB, &identities,
+		`SELECT provider, subject, user_id, email, create_at
+		FROM user_identity
+		WHERE user_id = @user_id
+		ORDER BY create_at`,
+		sql.Named("user_id", userID),
+	)
+}
+
+func (s *sqlite) CreateUserIdentity(identity entity.UserIdentity) (entity.UserIdentity, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	identity.CreateAt = time.Now()
+	_, err := s.rwDB.ExecContext(ctx,
+		`INSERT INTO user_identity (provider, subject, user_id, email, create_at)
+		VALUES (@provider, @subject, @user_id, @email, @create_at)`,
+		sql.Named("provider", identity.Provider),
+		sql.Named("subject", identity.Subject),
+		sql.Named("user_id", identity.UserID),
+		sql.Named("email", identity.Email),
+		sql.Named("create_at", identity.CreateAt),
+	)
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrConstraint {
+		return identity, db.ErrIdentityAlreadyLinked
+	}
+	return identity, err
+}
+
+func (s *sqlite) DeleteUserIdentity(userID string, provider string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	_, err := s.rwDB.ExecContext(ctx,
+		`DELETE FROM user_identity WHERE user_id = @user_id AND provider = @provider`,
+		sql.Named("user_id", userID),
+		sql.Named("provider", provider),
+	)
+	return err
+}
+
+func (s *sqlite) CreateOAuthState(state entity.OAuthState) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	_, err := s.rwDB.ExecContext(ctx,
+		`INSERT INTO oauth_state (state, provider, nonce, code_verifier, link_user_id, expire_at, create_at)
+		VALUES (@state, @provider, @nonce, @code_verifier, @link_user_id, @expire_at, @create_at)`,
+		sql.Named("state", state.State),
+		sql.Named("provider", state.Provider),
+		sql.Named("nonce", state.Nonce),
+		sql.Named("code_verifier", state.CodeVerifier),
+		sql.Named("link_user_id", state.LinkUserID),
+		sql.Named("expire_at", state.ExpireAt),
+		sql.Named("create_at", state.CreateAt),
+	)
+	return err
+}
+
+// TakeOAuthState returns and deletes the state, so each state is used once.
+// Expired states are cleaned up along the way.
+func (s *sqlite) TakeOAuthState(state string) (entity.OAuthState, error) {
+	var oauthState entity.OAuthState
+	return oauthState, s.WithTx(context.Background(), func(ctx context.Context, tx *sql.Tx) error {
+		err := sqlscan.Get(ctx, tx, &oauthState,
+			`DELETE FROM oauth_state WHERE state = @state
+			RETURNING state, provider, nonce, code_verifier, link_user_id, expire_at, create_at`,
+			sql.Named("state", state),
+		)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			`DELETE FROM oauth_state WHERE expire_at < @now`,
+			sql.Named("now", time.Now()),
+		)
+		return err
+	})
+}
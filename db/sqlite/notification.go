@@ -0,0 +1,139 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/georgysavva/scany/v2/sqlscan"
+	"github.com/rs/xid"
+	"github.com/waylen888/tab-buddy/db/entity"
+)
+
+func (s *sqlite) CreateNotification(args entity.CreateNotificationArguments) (entity.Notification, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	now := time.Now()
+	notification := entity.Notification{
+		ID:        xid.NewWithTime(now).String(),
+		UserID:    args.UserID,
+		Event:     args.Event,
+		GroupID:   args.GroupID,
+		ExpenseID: args.ExpenseID,
+		ActorID:   args.ActorID,
+		Title:     args.Title,
+		Body:      args.Body,
+		Link:      args.Link,
+		CreateAt:  now,
+	}
+	_, err := s.rwDB.ExecContext(ctx, `
+		INSERT INTO notification (id, user_id, event, group_id, expense_id, actor_id, title, body, link, create_at)
+		VALUES (@id, @user_id, @event, @group_id, @expense_id, @actor_id, @title, @body, @link, @create_at)`,
+		sql.Named("id", notification.ID),
+		sql.Named("user_id", notification.UserID),
+		sql.Named("event", notification.Event),
+		sql.Named("group_id", notification.GroupID),
+		sql.Named("expense_id", notification.ExpenseID),
+		sql.Named("actor_id", notification.ActorID),
+		sql.Named("title", notification.Title),
+		sql.Named("body", notification.Body),
+		sql.Named("link", notification.Link),
+		sql.Named("create_at", notification.CreateAt),
+	)
+	return notification, err
+}
+
+func (s *sqlite) GetNotificationPreferences(userID string) ([]entity.NotificationPreference, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	preferences := make([]entity.NotificationPreference, 0)
+	err := sqlscan.Select(ctx, s.rwDB, &preferences, `
+		SELECT event, channel, enabled FROM notification_preference WHERE user_id = @user_id`,
+		sql.Named("user_id", userID),
+	)
+	return preferences, err
+}
+
+func (s *sqlite) SetNotificationPreferences(userID string, preferences []entity.NotificationPreference) error {
+	return s.WithTx(context.Background(), func(ctx context.Context, tx *sql.Tx) error {
+		for _, preference := range preferences {
+			_, err := tx.ExecContext(ctx, `
+				INSERT OR REPLACE INTO notification_preference (user_id, event, channel, enabled)
+				VALUES (@user_id, @event, @channel, @enabled)`,
+				sql.Named("user_id", userID),
+				sql.Named("event", preference.Event),
+				sql.Named("channel", preference.Channel),
+				sql.Named("enabled", preference.Enabled),
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetNotifications returns the user's newest notifications first, older than the
+// notification before when it is not empty.
+func (s *sqlite) GetNotifications(userID string, before string, limit int, unreadOnly bool) ([]entity.Notification, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	notifications := make([]entity.Notification, 0)
+	err := sqlscan.Select(ctx, s.rwDB, &notifications, `
+		SELECT * FROM notification
+		WHERE user_id = @user_id
+		AND (@before = "" OR id < @before)
+		AND (@unread_only = 0 OR read_at IS NULL)
+		ORDER BY id DESC
+		LIMIT @limit`,
+		sql.Named("user_id", userID),
+		sql.Named("before", before),
+		sql.Named("unread_only", unreadOnly),
+		sql.Named("limit", limit),
+	)
+	return notifications, err
+}
+
+func (s *sqlite) CountUnreadNotifications(userID string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	var count int
+	err := sqlscan.Get(ctx, s.rwDB, &count, `
+		SELECT COUNT(*) FROM notification WHERE user_id = @user_id AND read_at IS NULL`,
+		sql.Named("user_id", userID),
+	)
+	return count, err
+}
+
+// MarkNotificationRead returns sql.ErrNoRows when the user has no such notification.
+func (s *sqlite) MarkNotificationRead(userID string, ID string, readAt time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	result, err := s.rwDB.ExecContext(ctx, `
+		UPDATE notification SET read_at = COALESCE(read_at, @read_at)
+		WHERE id = @id AND user_id = @user_id`,
+		sql.Named("id", ID),
+		sql.Named("user_id", userID),
+		sql.Named("read_at", readAt),
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+func (s *sqlite) MarkAllNotificationsRead(userID string, readAt time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	_, err := s.rwDB.ExecContext(ctx, `
+		UPDATE notification SET read_at = @read_at
+		WHERE user_id = @user_id AND read_at IS NULL`,
+		sql.Named("user_id", userID),
+		sql.Named("read_at", readAt),
+	)
+	return err
+}
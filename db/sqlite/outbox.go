@@ -0,0 +1,134 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/georgysavva/scany/v2/sqlscan"
+	"github.com/rs/xid"
+	"github.com/waylen888/tab-buddy/db/entity"
+)
+
+func (s *sqlite) CreateOutboxMail(args entity.CreateOutboxMailArguments) (entity.OutboxMail, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	now := time.Now()
+	mail := entity.OutboxMail{
+		ID:            xid.NewWithTime(now).String(),
+		Recipients:    strings.Join(args.Recipients, ","),
+		Subject:       args.Subject,
+		Text:          args.Text,
+		HTML:          args.HTML,
+		Status:        entity.OutboxMailStatusQueued,
+		NextAttemptAt: now,
+		CreateAt:      now,
+		UpdateAt:      now,
+	}
+	_, err := s.rwDB.ExecContext(ctx, `
+		INSERT INTO mail_outbox (id, recipients, subject, text, html, status, next_attempt_at, create_at, update_at)
+		VALUES (@id, @recipients, @subject, @text, @html, @status, @next_attempt_at, @create_at, @update_at)`,
+		sql.Named("id", mail.ID),
+		sql.Named("recipients", mail.Recipients),
+		sql.Named("subject", mail.Subject),
+		sql.Named("text", mail.Text),
+		sql.Named("html", mail.HTML),
+		sql.Named("status", mail.Status),
+		sql.Named("next_attempt_at", mail.NextAttemptAt),
+		sql.Named("create_at", mail.CreateAt),
+		sql.Named("update_at", mail.UpdateAt),
+	)
+	return mail, err
+}
+
+func (s *sqlite) GetDueOutboxMails(now time.Time, limit int) ([]entity.OutboxMail, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	mails := make([]entity.OutboxMail, 0)
+	err := sqlscan.Select(ctx, s.rwDB, &mails, `
+		SELECT * FROM mail_outbox
+		WHERE status = @status AND next_attempt_at <= @now
+		ORDER BY next_attempt_at
+		LIMIT @limit`,
+		sql.Named("status", entity.OutboxMailStatusQueued),
+		sql.Named("now", now),
+		sql.Named("limit", limit),
+	)
+	return mails, err
+}
+
+// GetOutboxMails lists mails for the admin, without their bodies.
+func (s *sqlite) GetOutboxMails(status entity.OutboxMailStatus, limit int) ([]entity.OutboxMail, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	mails := make([]entity.OutboxMail, 0)
+	err := sqlscan.Select(ctx, s.rwDB, &mails, `
+		SELECT id, recipients, subject, status, attempts, last_error, next_attempt_at, sent_at, create_at, update_at
+		FROM mail_outbox
+		WHERE status = @status
+		ORDER BY create_at DESC
+		LIMIT @limit`,
+		sql.Named("status", status),
+		sql.Named("limit", limit),
+	)
+	return mails, err
+}
+
+// MarkOutboxMailSent also clears the bodies, links in them such as password resets must not outlive delivery.
+func (s *sqlite) MarkOutboxMailSent(ID string, sentAt time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	_, err := s.rwDB.ExecContext(ctx, `
+		UPDATE mail_outbox
+		SET status = @status, attempts = attempts + 1, sent_at = @sent_at, update_at = @sent_at,
+			text = '', html = ''
+		WHERE id = @id`,
+		sql.Named("id", ID),
+		sql.Named("status", entity.OutboxMailStatusSent),
+		sql.Named("sent_at", sentAt),
+	)
+	return err
+}
+
+// MarkOutboxMailFailed records a failed attempt, status is queued to try again at nextAttemptAt or dead.
+func (s *sqlite) MarkOutboxMailFailed(ID string, status entity.OutboxMailStatus, lastError string, nextAttemptAt time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	_, err := s.rwDB.ExecContext(ctx, `
+		UPDATE mail_outbox
+		SET status = @status, attempts = attempts + 1, last_error = @last_error,
+			next_attempt_at = @next_attempt_at, update_at = @update_at
+		WHERE id = @id`,
+		sql.Named("id", ID),
+		sql.Named("status", status),
+		sql.Named("last_error", lastError),
+		sql.Named("next_attempt_at", nextAttemptAt),
+		sql.Named("update_at", time.Now()),
+	)
+	return err
+}
+
+// RetryOutboxMail queues a dead mail again with a fresh number of attempts.
+func (s *sqlite) RetryOutboxMail(ID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	now := time.Now()
+	result, err := s.rwDB.ExecContext(ctx, `
+		UPDATE mail_outbox
+		SET status = @queued, attempts = 0, next_attempt_at = @now, update_at = @now
+		WHERE id = @id AND status = @dead`,
+		sql.Named("id", ID),
+		sql.Named("queued", entity.OutboxMailStatusQueued),
+		sql.Named("dead", entity.OutboxMailStatusDead),
+		sql.Named("now", now),
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return err
+}
@@ -0,0 +1,65 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/georgysavva/scany/v2/sqlscan"
+	"github.com/rs/xid"
+	"github.com/waylen888/tab-buddy/db/entity"
+)
+
+// CreatePushSubscription stores the subscription, an existing one with the same endpoint is
+// replaced as the browser may have been used by another user since.
+func (s *sqlite) CreatePushSubscription(args entity.CreatePushSubscriptionArguments) (entity.PushSubscription, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	now := time.Now()
+	subscription := entity.PushSubscription{
+		ID:        xid.NewWithTime(now).String(),
+		UserID:    args.UserID,
+		Endpoint:  args.Endpoint,
+		P256dh:    args.P256dh,
+		Auth:      args.Auth,
+		UserAgent: args.UserAgent,
+		CreateAt:  now,
+	}
+	_, err := s.rwDB.ExecContext(ctx, `
+		INSERT OR REPLACE INTO push_subscription (id, user_id, endpoint, p256dh, auth, user_agent, create_at)
+		VALUES (@id, @user_id, @endpoint, @p256dh, @auth, @user_agent, @create_at)`,
+		sql.Named("id", subscription.ID),
+		sql.Named("user_id", subscription.UserID),
+		sql.Named("endpoint", subscription.Endpoint),
+		sql.Named("p256dh", subscription.P256dh),
+		sql.Named("auth", subscription.Auth),
+		sql.Named("user_agent", subscription.UserAgent),
+		sql.Named("create_at", subscription.CreateAt),
+	)
+	return subscription, err
+}
+
+func (s *sqlite) GetUserPushSubscriptions(userID string) ([]entity.PushSubscription, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	subscriptions := make([]entity.PushSubscription, 0)
+	err := sqlscan.Select(ctx, s.rwDB, &subscriptions, `
+		SELECT * FROM push_subscription WHERE user_id = @user_id ORDER BY create_at`,
+		sql.Named("user_id", userID),
+	)
+	return subscriptions, err
+}
+
+// DeletePushSubscription removes the subscription with endpoint, any user's when userID is empty.
+func (s *sqlite) DeletePushSubscription(userID string, endpoint string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	_, err := s.rwDB.ExecContext(ctx, `
+		DELETE FROM push_subscription
+		WHERE endpoint = @endpoint AND (@user_id = "" OR user_id = @user_id)`,
+		sql.Named("user_id", userID),
+		sql.Named("endpoint", endpoint),
+	)
+	return err
+}
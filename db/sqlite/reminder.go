@@ -0,0 +1,79 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/georgysavva/scany/v2/sqlscan"
+	"github.com/rs/xid"
+	"github.com/waylen888/tab-buddy/db"
+	"github.com/waylen888/tab-buddy/db/entity"
+)
+
+// CreateReminder returns db.ErrAlreadyReminded when the user was reminded in the group after args.Since.
+func (s *sqlite) CreateReminder(args entity.CreateReminderArguments) (entity.Reminder, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	reminder := entity.Reminder{
+		ID:       xid.NewWithTime(args.CreateAt).String(),
+		GroupID:  args.GroupID,
+		UserID:   args.UserID,
+		SentBy:   args.SentBy,
+		Amount:   args.Amount,
+		CreateAt: args.CreateAt,
+	}
+	// checked in the same statement, so concurrent reminders cannot both pass the throttle
+	result, err := s.rwDB.ExecContext(ctx, `
+		INSERT INTO reminder (id, group_id, user_id, sent_by, amount, create_at)
+		SELECT @id, @group_id, @user_id, @sent_by, @amount, @create_at
+		WHERE NOT EXISTS (
+			SELECT 1 FROM reminder
+			WHERE group_id = @group_id AND user_id = @user_id AND create_at > @since
+		)`,
+		sql.Named("id", reminder.ID),
+		sql.Named("group_id", reminder.GroupID),
+		sql.Named("user_id", reminder.UserID),
+		sql.Named("sent_by", reminder.SentBy),
+		sql.Named("amount", reminder.Amount),
+		sql.Named("create_at", reminder.CreateAt),
+		sql.Named("since", args.Since),
+	)
+	if err != nil {
+		return entity.Reminder{}, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return entity.Reminder{}, err
+	}
+	if n == 0 {
+		return entity.Reminder{}, db.ErrAlreadyReminded
+	}
+	return reminder, nil
+}
+
+// GetLastReminder returns sql.ErrNoRows when the user was never reminded in the group.
+func (s *sqlite) GetLastReminder(groupID string, userID string) (entity.Reminder, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	var reminder entity.Reminder
+	err := sqlscan.Get(ctx, s.rwDB, &reminder, `
+		SELECT * FROM reminder
+		WHERE group_id = @group_id AND user_id = @user_id
+		ORDER BY create_at DESC
+		LIMIT 1`,
+		sql.Named("group_id", groupID),
+		sql.Named("user_id", userID),
+	)
+	return reminder, err
+}
+
+func (s *sqlite) GetGroupsWithReminders() ([]entity.Group, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	groups := make([]entity.Group, 0)
+	err := sqlscan.Select(ctx, s.rwDB, &groups, `
+		SELECT * FROM "group" WHERE reminder_interval_days > 0`,
+	)
+	return groups, err
+}
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS "attachment_text" (
+	"attachment_id"	TEXT NOT NULL,
+	"status"	INTEGER NOT NULL,
+	"text"	TEXT NOT NULL DEFAULT "",
+	"total"	TEXT NOT NULL DEFAULT "",
+	"date"	DATETIME,
+	"merchant"	TEXT NOT NULL DEFAULT "",
+	"error"	TEXT NOT NULL DEFAULT "",
+	"create_at"	DATETIME NOT NULL,
+	PRIMARY KEY("attachment_id"),
+	FOREIGN KEY("attachment_id") REFERENCES "expense_attachment"("id") ON DELETE CASCADE
+);
@@ -2,10 +2,12 @@ CREATE TABLE IF NOT EXISTS "expense_attachment" (
 	"id"	TEXT NOT NULL,
 	"filename"	TEXT NOT NULL,
 	"size"	INTEGER NOT NULL,
+	"thumbnail_size"	INTEGER NOT NULL DEFAULT 0,
 	"mime"	TEXT NOT NULL,
 	"create_at"	DATETIME NOT NULL,
 	"update_at"	DATETIME NOT NULL,
 	"expense_id"	TEXT NOT NULL,
+	"comment_id"	TEXT REFERENCES "expense_comment"("id") ON DELETE CASCADE,
 	PRIMARY KEY("id"),
 	FOREIGN KEY("expense_id") REFERENCES "expense"("id") ON DELETE CASCADE
 );
\ No newline at end of file
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS "mail_outbox" (
+	"id"	TEXT NOT NULL,
+	"recipients"	TEXT NOT NULL,
+	"subject"	TEXT NOT NULL,
+	"text"	TEXT NOT NULL,
+	"html"	TEXT NOT NULL DEFAULT "",
+	"status"	INTEGER NOT NULL DEFAULT 0,
+	"attempts"	INTEGER NOT NULL DEFAULT 0,
+	"last_error"	TEXT NOT NULL DEFAULT "",
+	"next_attempt_at"	DATETIME NOT NULL,
+	"sent_at"	DATETIME,
+	"create_at"	DATETIME NOT NULL,
+	"update_at"	DATETIME NOT NULL,
+	PRIMARY KEY("id")
+);
+CREATE INDEX IF NOT EXISTS "mail_outbox_due" ON "mail_outbox" ("status", "next_attempt_at");
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS "notification" (
+	"id"	TEXT NOT NULL,
+	"user_id"	TEXT NOT NULL,
+	"event"	TEXT NOT NULL,
+	"group_id"	TEXT NOT NULL,
+	"expense_id"	TEXT NOT NULL DEFAULT "",
+	"actor_id"	TEXT NOT NULL DEFAULT "",
+	"title"	TEXT NOT NULL,
+	"body"	TEXT NOT NULL,
+	"link"	TEXT NOT NULL DEFAULT "",
+	"read_at"	DATETIME,
+	"create_at"	DATETIME NOT NULL,
+	PRIMARY KEY("id"),
+	FOREIGN KEY("user_id") REFERENCES "user"("id") ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "notification_user" ON "notification" ("user_id", "create_at");
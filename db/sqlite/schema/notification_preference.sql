@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS "notification_preference" (
+	"user_id"	TEXT NOT NULL,
+	"event"	TEXT NOT NULL,
+	"channel"	TEXT NOT NULL,
+	"enabled"	BOOLEAN NOT NULL,
+	PRIMARY KEY("user_id", "event", "channel"),
+	FOREIGN KEY("user_id") REFERENCES "user"("id") ON DELETE CASCADE
+);
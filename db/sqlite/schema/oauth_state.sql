@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS "oauth_state" (
+	"state"	TEXT NOT NULL,
+	"provider"	TEXT NOT NULL,
+	"nonce"	TEXT NOT NULL,
+	"code_verifier"	TEXT NOT NULL,
+	"link_user_id"	TEXT NOT NULL DEFAULT "",
+	"expire_at"	DATETIME NOT NULL,
+	"create_at"	DATETIME NOT NULL,
+	PRIMARY KEY("state")
+);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS "push_subscription" (
+	"id"	TEXT NOT NULL,
+	"user_id"	TEXT NOT NULL,
+	"endpoint"	TEXT NOT NULL UNIQUE,
+	"p256dh"	TEXT NOT NULL,
+	"auth"	TEXT NOT NULL,
+	"user_agent"	TEXT NOT NULL DEFAULT "",
+	"create_at"	DATETIME NOT NULL,
+	PRIMARY KEY("id"),
+	FOREIGN KEY("user_id") REFERENCES "user"("id") ON DELETE CASCADE
+);
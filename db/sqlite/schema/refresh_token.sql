@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS "refresh_token" (
+	"id"	TEXT NOT NULL,
+	"user_id"	TEXT NOT NULL,
+	"session_id"	TEXT NOT NULL DEFAULT "",
+	"token_hash"	TEXT NOT NULL UNIQUE,
+	"expire_at"	DATETIME NOT NULL,
+	"revoke_at"	DATETIME,
+	"create_at"	DATETIME NOT NULL,
+	PRIMARY KEY("id"),
+	FOREIGN KEY("user_id") REFERENCES "user"("id") ON DELETE CASCADE
+);
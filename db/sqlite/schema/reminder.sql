@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS "reminder" (
+	"id"	TEXT NOT NULL,
+	"group_id"	TEXT NOT NULL,
+	"user_id"	TEXT NOT NULL,
+	-- sent_by is empty for automatic reminders
+	"sent_by"	TEXT NOT NULL DEFAULT "",
+	"amount"	TEXT NOT NULL,
+	"create_at"	DATETIME NOT NULL,
+	PRIMARY KEY("id"),
+	FOREIGN KEY("group_id") REFERENCES "group"("id") ON DELETE CASCADE,
+	FOREIGN KEY("user_id") REFERENCES "user"("id") ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "reminder_group_user" ON "reminder" ("group_id", "user_id", "create_at");
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS "session" (
+	"id"	TEXT NOT NULL,
+	"user_id"	TEXT NOT NULL,
+	"user_agent"	TEXT NOT NULL,
+	"ip"	TEXT NOT NULL,
+	"create_at"	DATETIME NOT NULL,
+	"last_seen_at"	DATETIME NOT NULL,
+	"revoke_at"	DATETIME,
+	PRIMARY KEY("id"),
+	FOREIGN KEY("user_id") REFERENCES "user"("id") ON DELETE CASCADE
+);
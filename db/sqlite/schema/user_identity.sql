@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS "user_identity" (
+	"provider"	TEXT NOT NULL,
+	"subject"	TEXT NOT NULL,
+	"user_id"	TEXT NOT NULL,
+	"email"	TEXT NOT NULL,
+	"create_at"	DATETIME NOT NULL,
+	PRIMARY KEY("provider", "subject"),
+	UNIQUE("user_id", "provider"),
+	FOREIGN KEY("user_id") REFERENCES "user"("id") ON DELETE CASCADE
+);
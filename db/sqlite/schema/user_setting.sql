@@ -3,6 +3,8 @@ CREATE TABLE IF NOT EXISTS "user_setting" (
 	"user_id"	TEXT NOT NULL,
 	"theme_mode"	TEXT NOT NULL,
 	"push_notification"	BOOLEAN NOT NULL DEFAULT 0,
+	"email_digest"	BOOLEAN NOT NULL DEFAULT 1,
+	"digest_sent_at"	DATETIME,
 	PRIMARY KEY("user_id"),
 	FOREIGN KEY("user_id") REFERENCES "user"("id") ON DELETE CASCADE
 );
\ No newline at end of file
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS "user_token" (
+	"id"	TEXT NOT NULL,
+	"user_id"	TEXT NOT NULL,
+	"purpose"	INTEGER NOT NULL,
+	"email"	TEXT NOT NULL,
+	"token_hash"	TEXT NOT NULL UNIQUE,
+	"expire_at"	DATETIME NOT NULL,
+	"use_at"	DATETIME,
+	"create_at"	DATETIME NOT NULL,
+	PRIMARY KEY("id"),
+	FOREIGN KEY("user_id") REFERENCES "user"("id") ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "user_token_email" ON "user_token" ("purpose", "email", "create_at");
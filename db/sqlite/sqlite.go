@@ -6,6 +6,7 @@ import (
 	"embed"
 	"fmt"
 	"io/fs"
+	"os"
 	"strings"
 	"time"
 
@@ -129,18 +130,55 @@ func (s *sqlite) initialize(ctx context.Context) error {
 		return err
 	}
 
+	for _, migration := range columnMigrations {
+		if err := addColumnIfNotExists(ctx, tx, migration.A, migration.B, migration.C); err != nil {
+			return fmt.Errorf("add column (%s.%s): %w", migration.A, migration.B, err)
+		}
+	}
+
 	if err := s.prepareCurrency(ctx, tx); err != nil {
 		return fmt.Errorf("prepareCurrency: %w", err)
 	}
 	return tx.Commit()
 }
 
+// columnMigrations are columns added to tables after they were first created,
+// new databases already get them from the CREATE TABLE statements.
+var columnMigrations = []lo.Tuple3[string, string, string]{
+	lo.T3("refresh_token", "session_id", `TEXT NOT NULL DEFAULT ""`),
+	lo.T3("user", "email_verified_at", `DATETIME`),
+	lo.T3("expense_attachment", "thumbnail_size", `INTEGER NOT NULL DEFAULT 0`),
+	lo.T3("expense_attachment", "comment_id", `TEXT REFERENCES "expense_comment"("id") ON DELETE CASCADE`),
+	lo.T3("expense", "note", `TEXT NOT NULL DEFAULT ""`),
+	lo.T3("user_setting", "email_digest", `BOOLEAN NOT NULL DEFAULT 1`),
+	lo.T3("user_setting", "digest_sent_at", `DATETIME`),
+	lo.T3("group", "reminder_interval_days", `INTEGER NOT NULL DEFAULT 0`),
+}
+
+func addColumnIfNotExists(ctx context.Context, tx *sql.Tx, table, column, definition string) error {
+	var count int
+	err := tx.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM pragma_table_info(@table) WHERE name = @column`,
+		sql.Named("table", table),
+		sql.Named("column", column),
+	).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	_, err = tx.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %q ADD COLUMN %q %s`, table, column, definition))
+	return err
+}
+
 var tableSchemes = []lo.Tuple2[string, string]{
 	lo.T2("group", `
 CREATE TABLE IF NOT EXISTS "group" (
   "id"	TEXT NOT NULL,
   "name"	TEXT NOT NULL,
 	"convert_to_twd"	BOOLEAN NOT NULL DEFAULT 0,
+	"reminder_interval_days"	INTEGER NOT NULL DEFAULT 0,
   "create_at" DATETIME NOT NULL,
   "update_at" DATETIME NOT NULL,
   PRIMARY KEY("id")
@@ -160,6 +198,7 @@ CREATE TABLE IF NOT EXISTS "user" (
   "display_name"	TEXT NOT NULL,
 	"password" TEXT NOT NULL,
 	"email" TEXT NOT NULL,
+	"email_verified_at" DATETIME,
 	"create_type" INTEGER NOT NULL DEFAULT 0, 
   "create_at" DATETIME NOT NULL,
   "update_at" DATETIME NOT NULL,
@@ -186,6 +225,7 @@ CREATE TABLE IF NOT EXISTS "expense" (
 	"currency_code" TEXT NOT NULL,
 	"category" TEXT NOT NULL DEFAULT "",
 	"twd_rate" TEXT NOT NULL,
+	"note" TEXT NOT NULL DEFAULT "",
   "create_at" DATETIME NOT NULL,
   "update_at" DATETIME NOT NULL,
 	"created_by" TEXT NOT NULL,
@@ -236,3 +276,44 @@ CREATE TABLE IF NOT EXISTS "expense_photo" (
 	FOREIGN KEY("expense_id") REFERENCES "expense"("id") ON DELETE CASCADE
 );`),
 }
+
+// Backup writes a consistent copy of the database to path, which must not exist yet.
+// It is not bound by the usual timeout, copying a large database takes a while.
+func (s *sqlite) Backup(path string) error {
+	_, err := s.rwDB.ExecContext(context.Background(), `VACUUM INTO @path`, sql.Named("path", path))
+	return err
+}
+
+// CheckIntegrity opens the database file at path read-only and runs the sqlite integrity check on it.
+func CheckIntegrity(ctx context.Context, path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	conn, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?mode=ro", path))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	rows, err := conn.QueryContext(ctx, `PRAGMA integrity_check`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var problems []string
+	for rows.Next() {
+		var result string
+		if err := rows.Scan(&result); err != nil {
+			return err
+		}
+		if result != "ok" {
+			problems = append(problems, result)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("integrity check failed: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/georgysavva/scany/v2/sqlscan"
+	"github.com/rs/xid"
+	"github.com/waylen888/tab-buddy/db"
+	"github.com/waylen888/tab-buddy/db/entity"
+)
+
+func (s *sqlite) CreateRefreshToken(args entity.CreateRefreshTokenArguments) (entity.RefreshToken, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	now := time.Now()
+	token := entity.RefreshToken{
+		ID:        xid.NewWithTime(now).String(),
+		UserID:    args.UserID,
+		SessionID: args.SessionID,
+		TokenHash: args.TokenHash,
+		ExpireAt:  args.ExpireAt,
+		CreateAt:  now,
+	}
+	_, err := s.rwDB.ExecContext(ctx,
+		`INSERT INTO refresh_token (id, user_id, session_id, token_hash, expire_at, create_at)
+		VALUES (@id, @user_id, @session_id, @token_hash, @expire_at, @create_at)`,
+		sql.Named("id", token.ID),
+		sql.Named("user_id", token.UserID),
+		sql.Named("session_id", token.SessionID),
+		sql.Named("token_hash", token.TokenHash),
+		sql.Named("expire_at", token.ExpireAt),
+		sql.Named("create_at", token.CreateAt),
+	)
+	return token, err
+}
+
+func (s *sqlite) GetRefreshToken(tokenHash string) (entity.RefreshToken, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	var token entity.RefreshToken
+	return token, sqlscan.Get(ctx, s.rwDB, &token,
+		`SELECT id, user_id, session_id, token_hash, expire_at, revoke_at, create_at
+		FROM refresh_token
+		WHERE token_hash = @token_hash`,
+		sql.Named("token_hash", tokenHash),
+	)
+}
+
+// RevokeRefreshToken returns db.ErrTokenAlreadyUsed when the token was revoked meanwhile.
+func (s *sqlite) RevokeRefreshToken(ID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	result, err := s.rwDB.ExecContext(ctx,
+		`UPDATE refresh_token SET revoke_at = @revoke_at WHERE id = @id AND revoke_at IS NULL`,
+		sql.Named("id", ID),
+		sql.Named("revoke_at", time.Now()),
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return db.ErrTokenAlreadyUsed
+	}
+	return nil
+}
+
+func (s *sqlite) CreateSession(args entity.CreateSessionArguments) (entity.Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	now := time.Now()
+	session := entity.Session{
+		ID:         xid.NewWithTime(now).String(),
+		UserID:     args.UserID,
+		UserAgent:  args.UserAgent,
+		IP:         args.IP,
+		CreateAt:   now,
+		LastSeenAt: now,
+	}
+	_, err := s.rwDB.ExecContext(ctx,
+		`INSERT INTO session (id, user_id, user_agent, ip, create_at, last_seen_at)
+		VALUES (@id, @user_id, @user_agent, @ip, @create_at, @last_seen_at)`,
+		sql.Named("id", session.ID),
+		sql.Named("user_id", session.UserID),
+		sql.Named("user_agent", session.UserAgent),
+		sql.Named("ip", session.IP),
+		sql.Named("create_at", session.CreateAt),
+		sql.Named("last_seen_at", session.LastSeenAt),
+	)
+	return session, err
+}
+
+func (s *sqlite) GetSession(ID string) (entity.Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	var session entity.Session
+	return session, sqlscan.Get(ctx, s.rwDB, &session,
+		`SELECT id, user_id, user_agent, ip, create_at, last_seen_at, revoke_at
+		FROM session
+		WHERE id = @id`,
+		sql.Named("id", ID),
+	)
+}
+
+func (s *sqlite) GetUserSessions(userID string) ([]entity.Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	sessions := make([]entity.Session, 0)
+	return sessions, sqlscan.Select(ctx, s.rwDB, &sessions,
+		`SELECT id, user_id, user_agent, ip, create_at, last_seen_at, revoke_at
+		FROM session
+		WHERE user_id = @user_id AND revoke_at IS NULL
+		ORDER BY last_seen_at DESC`,
+		sql.Named("user_id", userID),
+	)
+}
+
+func (s *sqlite) TouchSession(ID string, ip string, lastSeenAt time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	_, err := s.rwDB.ExecContext(ctx,
+		`UPDATE session SET ip = @ip, last_seen_at = @last_seen_at WHERE id = @id`,
+		sql.Named("id", ID),
+		sql.Named("ip", ip),
+		sql.Named("last_seen_at", lastSeenAt),
+	)
+	return err
+}
+
+// RevokeSession returns sql.ErrNoRows when the user has no such active session.
+func (s *sqlite) RevokeSession(ID string, userID string) error {
+	return s.WithTx(context.Background(), func(ctx context.Context, tx *sql.Tx) error {
+		now := time.Now()
+		result, err := tx.ExecContext(ctx,
+			`UPDATE session SET revoke_at = @revoke_at
+			WHERE id = @id AND user_id = @user_id AND revoke_at IS NULL`,
+			sql.Named("id", ID),
+			sql.Named("user_id", userID),
+			sql.Named("revoke_at", now),
+		)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return sql.ErrNoRows
+		}
+		_, err = tx.ExecContext(ctx,
+			`UPDATE refresh_token SET revoke_at = @revoke_at
+			WHERE session_id = @session_id AND user_id = @user_id AND revoke_at IS NULL`,
+			sql.Named("session_id", ID),
+			sql.Named("user_id", userID),
+			sql.Named("revoke_at", now),
+		)
+		return err
+	})
+}
+
+// RevokeUserSessions revokes every session of the user except exceptSessionID, which may be empty.
+func (s *sqlite) RevokeUserSessions(userID string, exceptSessionID string) error {
+	return s.WithTx(context.Background(), func(ctx context.Context, tx *sql.Tx) error {
+		now := time.Now()
+		_, err := tx.ExecContext(ctx,
+			`UPDATE session SET revoke_at = @revoke_at
+			WHERE user_id = @user_id AND id != @except_id AND revoke_at IS NULL`,
+			sql.Named("user_id", userID),
+			sql.Named("except_id", exceptSessionID),
+			sql.Named("revoke_at", now),
+		)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			`UPDATE refresh_token SET revoke_at = @revoke_at
+			WHERE user_id = @user_id AND session_id != @except_id AND revoke_at IS NULL`,
+			sql.Named("user_id", userID),
+			sql.Named("except_id", exceptSessionID),
+			sql.Named("revoke_at", now),
+		)
+		return err
+	})
+}
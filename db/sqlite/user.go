@@ -8,6 +8,7 @@ import (
 
 	"github.com/georgysavva/scany/v2/sqlscan"
 	"github.com/rs/xid"
+	"github.com/waylen888/tab-buddy/db"
 	"github.com/waylen888/tab-buddy/db/entity"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -94,28 +95,307 @@ func (s *sqlite) GetUserSetting(ID string) (entity.UserSetting, error) {
 		ctx,
 		s.rwDB,
 		&userSetting,
-		`SELECT theme_mode, push_notification FROM "user_setting" WHERE user_id = @id`,
+		`SELECT theme_mode, push_notification, email_digest FROM "user_setting" WHERE user_id = @id`,
 		sql.Named("id", ID),
 	)
 	return userSetting, err
 }
 
-func (s *sqlite) UpdateUserSetting(userID string, themeMode *string, pushNotification *bool) (entity.UserSetting, error) {
+func (s *sqlite) UpdateUserSetting(userID string, themeMode *string, pushNotification *bool, emailDigest *bool) (entity.UserSetting, error) {
 	var setting entity.UserSetting
 	err := s.WithTx(context.Background(), func(ctx context.Context, tx *sql.Tx) error {
 		_, err := tx.ExecContext(
 			ctx,
-			`INSERT OR REPLACE INTO "user_setting" (user_id, theme_mode, push_notification)
+			`INSERT OR REPLACE INTO "user_setting" (user_id, theme_mode, push_notification, email_digest, digest_sent_at)
 			VALUES (
 				@user_id,
 				COALESCE(@theme_mode, (SELECT theme_mode FROM user_setting WHERE user_id = @user_id), ""),
-				COALESCE(@push_notification, (SELECT push_notification FROM user_setting WHERE user_id = @user_id), 0)
+				COALESCE(@push_notification, (SELECT push_notification FROM user_setting WHERE user_id = @user_id), 0),
+				COALESCE(@email_digest, (SELECT email_digest FROM user_setting WHERE user_id = @user_id), 1),
+				(SELECT digest_sent_at FROM user_setting WHERE user_id = @user_id)
 			)`,
 			sql.Named("user_id", userID),
 			sql.Named("theme_mode", themeMode),
 			sql.Named("push_notification", pushNotification),
+			sql.Named("email_digest", emailDigest),
+		)
+		if err != nil {
+			return err
+		}
+		return sqlscan.Get(ctx, tx, &setting,
+			`SELECT theme_mode, push_notification, email_digest FROM "user_setting" WHERE user_id = @user_id`,
+			sql.Named("user_id", userID),
 		)
-		return err
 	})
 	return setting, err
 }
+
+func (s *sqlite) GetUsersByEmail(email string) ([]entity.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	users := make([]entity.User, 0)
+	return users, sqlscan.Select(
+		ctx,
+		s.rwDB,
+		&users,
+		`SELECT * FROM "user" WHERE email = @email`,
+		sql.Named("email", email),
+	)
+}
+
+func (s *sqlite) UpdateUserPassword(userID string, password string) error {
+	if password == "" {
+		return fmt.Errorf("password is required")
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	_, err = s.rwDB.ExecContext(
+		ctx,
+		`UPDATE "user" SET password = @password, update_at = @update_at WHERE id = @id`,
+		sql.Named("id", userID),
+		sql.Named("password", string(hashedPassword)),
+		sql.Named("update_at", time.Now()),
+	)
+	return err
+}
+
+// SetUserEmailVerified marks email as verified, unless the user changed it meanwhile.
+func (s *sqlite) SetUserEmailVerified(userID string, email string, verifiedAt time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	result, err := s.rwDB.ExecContext(
+		ctx,
+		`UPDATE "user" SET email_verified_at = @email_verified_at WHERE id = @id AND email = @email`,
+		sql.Named("id", userID),
+		sql.Named("email", email),
+		sql.Named("email_verified_at", verifiedAt),
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s *sqlite) CreateUserToken(args entity.CreateUserTokenArguments) (entity.UserToken, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	now := time.Now()
+	token := entity.UserToken{
+		ID:        xid.NewWithTime(now).String(),
+		UserID:    args.UserID,
+		Purpose:   args.Purpose,
+		Email:     args.Email,
+		TokenHash: args.TokenHash,
+		ExpireAt:  args.ExpireAt,
+		CreateAt:  now,
+	}
+	_, err := s.rwDB.ExecContext(
+		ctx,
+		`INSERT INTO user_token (id, user_id, purpose, email, token_hash, expire_at, create_at)
+		VALUES (@id, @user_id, @purpose, @email, @token_hash, @expire_at, @create_at)`,
+		sql.Named("id", token.ID),
+		sql.Named("user_id", token.UserID),
+		sql.Named("purpose", token.Purpose),
+		sql.Named("email", token.Email),
+		sql.Named("token_hash", token.TokenHash),
+		sql.Named("expire_at", token.ExpireAt),
+		sql.Named("create_at", token.CreateAt),
+	)
+	return token, err
+}
+
+func (s *sqlite) GetUserToken(purpose entity.UserTokenPurpose, tokenHash string) (entity.UserToken, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	var token entity.UserToken
+	return token, sqlscan.Get(
+		ctx,
+		s.rwDB,
+		&token,
+		`SELECT id, user_id, purpose, email, token_hash, expire_at, use_at, create_at
+		FROM user_token
+		WHERE purpose = @purpose AND token_hash = @token_hash`,
+		sql.Named("purpose", purpose),
+		sql.Named("token_hash", tokenHash),
+	)
+}
+
+func (s *sqlite) UseUserToken(ID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	result, err := s.rwDB.ExecContext(
+		ctx,
+		`UPDATE user_token SET use_at = @use_at WHERE id = @id AND use_at IS NULL`,
+		sql.Named("id", ID),
+		sql.Named("use_at", time.Now()),
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return db.ErrTokenAlreadyUsed
+	}
+	return nil
+}
+
+func (s *sqlite) CountUserTokensSince(purpose entity.UserTokenPurpose, email string, since time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	var count int
+	return count, sqlscan.Get(
+		ctx,
+		s.rwDB,
+		&count,
+		`SELECT COUNT(*) FROM user_token WHERE purpose = @purpose AND email = @email AND create_at > @since`,
+		sql.Named("purpose", purpose),
+		sql.Named("email", email),
+		sql.Named("since", since),
+	)
+}
+
+// UpdateUser updates the given profile fields, changing email resets its verification.
+func (s *sqlite) UpdateUser(userID string, displayName *string, email *string) (entity.User, error) {
+	if displayName != nil && *displayName == "" {
+		return entity.User{}, fmt.Errorf("displayName is required")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	var user entity.User
+	err := sqlscan.Get(
+		ctx,
+		s.rwDB,
+		&user,
+		`UPDATE "user"
+		SET display_name = COALESCE(@display_name, display_name),
+			email_verified_at = CASE WHEN @email IS NULL OR @email = email THEN email_verified_at ELSE NULL END,
+			email = COALESCE(@email, email),
+			update_at = @update_at
+		WHERE id = @id
+		RETURNING *`,
+		sql.Named("id", userID),
+		sql.Named("display_name", displayName),
+		sql.Named("email", email),
+		sql.Named("update_at", time.Now()),
+	)
+	return user, err
+}
+
+// DeleteUser anonymizes the user instead of deleting the row, so expenses and
+// comments of other members keep pointing at an existing user.
+func (s *sqlite) DeleteUser(userID string) error {
+	return s.WithTx(context.Background(), func(ctx context.Context, tx *sql.Tx) error {
+		now := time.Now()
+		_, err := tx.ExecContext(
+			ctx,
+			`UPDATE "user"
+			SET username = @username,
+				display_name = @display_name,
+				email = "",
+				email_verified_at = NULL,
+				password = "",
+				update_at = @update_at
+			WHERE id = @id`,
+			sql.Named("id", userID),
+			sql.Named("username", "deleted:"+userID),
+			sql.Named("display_name", "Deleted user"),
+			sql.Named("update_at", now),
+		)
+		if err != nil {
+			return fmt.Errorf("anonymize user: %w", err)
+		}
+
+		var groupIDs []string
+		err = sqlscan.Select(ctx, tx, &groupIDs,
+			`SELECT group_id FROM group_member WHERE user_id = @user_id`,
+			sql.Named("user_id", userID),
+		)
+		if err != nil {
+			return fmt.Errorf("select groups: %w", err)
+		}
+		_, err = tx.ExecContext(ctx,
+			`DELETE FROM group_member WHERE user_id = @user_id`,
+			sql.Named("user_id", userID),
+		)
+		if err != nil {
+			return fmt.Errorf("delete group member: %w", err)
+		}
+		for _, groupID := range groupIDs {
+			// a group nobody is left in can never be reached again
+			_, err = tx.ExecContext(ctx,
+				`DELETE FROM expense WHERE id IN (SELECT expense_id FROM group_expense WHERE group_id = @group_id)
+				AND NOT EXISTS (SELECT 1 FROM group_member WHERE group_id = @group_id)`,
+				sql.Named("group_id", groupID),
+			)
+			if err != nil {
+				return fmt.Errorf("delete orphan expenses: %w", err)
+			}
+			_, err = tx.ExecContext(ctx,
+				`DELETE FROM "group" WHERE id = @group_id
+				AND NOT EXISTS (SELECT 1 FROM group_member WHERE group_id = @group_id)`,
+				sql.Named("group_id", groupID),
+			)
+			if err != nil {
+				return fmt.Errorf("delete orphan group: %w", err)
+			}
+		}
+
+		for _, query := range []string{
+			`DELETE FROM user_setting WHERE user_id = @user_id`,
+			`DELETE FROM user_token WHERE user_id = @user_id`,
+			`DELETE FROM user_identity WHERE user_id = @user_id`,
+			`DELETE FROM notification WHERE user_id = @user_id`,
+			`DELETE FROM notification_preference WHERE user_id = @user_id`,
+			`DELETE FROM push_subscription WHERE user_id = @user_id`,
+			`DELETE FROM reminder WHERE user_id = @user_id`,
+			`UPDATE session SET revoke_at = @now WHERE user_id = @user_id AND revoke_at IS NULL`,
+			`UPDATE refresh_token SET revoke_at = @now WHERE user_id = @user_id AND revoke_at IS NULL`,
+		} {
+			_, err = tx.ExecContext(ctx, query,
+				sql.Named("user_id", userID),
+				sql.Named("now", now),
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetDigestRecipients returns users with an email who did not opt out of digests
+// and were last sent one before sentBefore, or never.
+func (s *sqlite) GetDigestRecipients(sentBefore time.Time) ([]entity.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	users := make([]entity.User, 0)
+	err := sqlscan.Select(ctx, s.rwDB, &users, `
+		SELECT "user".* FROM "user"
+		LEFT JOIN user_setting ON user_setting.user_id = "user".id
+		WHERE "user".email != ""
+		AND COALESCE(user_setting.email_digest, 1) = 1
+		AND (user_setting.digest_sent_at IS NULL OR user_setting.digest_sent_at < @sent_before)`,
+		sql.Named("sent_before", sentBefore),
+	)
+	return users, err
+}
+
+func (s *sqlite) SetDigestSentAt(userID string, sentAt time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	_, err := s.rwDB.ExecContext(ctx, `
+		INSERT INTO user_setting (user_id, theme_mode, digest_sent_at) VALUES (@user_id, "", @sent_at)
+		ON CONFLICT (user_id) DO UPDATE SET digest_sent_at = @sent_at`,
+		sql.Named("user_id", userID),
+		sql.Named("sent_at", sentAt),
+	)
+	return err
+}
@@ -0,0 +1,206 @@
+package digest
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/shopspring/decimal"
+	"github.com/waylen888/tab-buddy/calc"
+	"github.com/waylen888/tab-buddy/config"
+	"github.com/waylen888/tab-buddy/db"
+	"github.com/waylen888/tab-buddy/db/entity"
+	"github.com/waylen888/tab-buddy/mail"
+)
+
+const (
+	// checkInterval is how often users due for a digest are looked for.
+	checkInterval = time.Hour
+	// activityLimit is how many recent expenses a group lists.
+	activityLimit = 5
+)
+
+// Scheduler emails users a periodic digest of their balances and the recent activity of their groups.
+type Scheduler struct {
+	db        db.Database
+	outbox    *mail.Outbox
+	interval  time.Duration
+	publicURL string
+}
+
+func NewScheduler(db db.Database, outbox *mail.Outbox, cfg config.DigestSetting, publicURL string) *Scheduler {
+	return &Scheduler{
+		db:        db,
+		outbox:    outbox,
+		interval:  time.Duration(cfg.Interval),
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+	}
+}
+
+// Run sends digests until ctx is done, it does nothing when digests are disabled.
+func (s *Scheduler) Run(ctx context.Context) error {
+	if s.interval <= 0 {
+		return nil
+	}
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for {
+		s.sendDue(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) sendDue(ctx context.Context, now time.Time) {
+	users, err := s.db.GetDigestRecipients(now.Add(-s.interval))
+	if err != nil {
+		slog.Error("get digest recipients", "error", err)
+		return
+	}
+	for _, user := range users {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := s.send(user, now); err != nil {
+			slog.Error("send digest", "user", user.ID, "error", err)
+		}
+	}
+}
+
+// send queues the digest of user, nothing is sent when all groups are settled and quiet.
+func (s *Scheduler) send(user entity.User, now time.Time) error {
+	data, err := s.build(user, now.Add(-s.interval))
+	if err != nil {
+		return err
+	}
+	if len(data.Groups) > 0 {
+		msg, err := mail.Render("digest", []string{user.Email}, data)
+		if err != nil {
+			return fmt.Errorf("render mail: %w", err)
+		}
+		if err := s.outbox.Enqueue(msg); err != nil {
+			return fmt.Errorf("queue mail: %w", err)
+		}
+	}
+	return s.db.SetDigestSentAt(user.ID, now)
+}
+
+type digestData struct {
+	DisplayName string
+	Groups      []digestGroup
+}
+
+type digestGroup struct {
+	Name string
+	Link string
+	// Balance is the absolute amount, Owed and Owes tell its direction.
+	Balance   string
+	Owed      bool
+	Owes      bool
+	Transfers []digestTransfer
+	Activity  []string
+}
+
+type digestTransfer struct {
+	From   string
+	To     string
+	Amount string
+}
+
+func (s *Scheduler) build(user entity.User, since time.Time) (digestData, error) {
+	data := digestData{DisplayName: user.DisplayName}
+	currency, err := s.db.GetCurrency("TWD")
+	if err != nil {
+		return data, fmt.Errorf("get currency: %w", err)
+	}
+	places := int32(currency.DecimalDigits)
+
+	groups, err := s.db.GetGroups(user.ID)
+	if err != nil {
+		return data, fmt.Errorf("get groups: %w", err)
+	}
+	for _, group := range groups {
+		expenses, err := s.db.GetGroupExpenses(group.ID)
+		if err != nil {
+			return data, fmt.Errorf("get group expenses: %w", err)
+		}
+		members, err := s.db.GetGroupMembers(group.ID)
+		if err != nil {
+			return data, fmt.Errorf("get group members: %w", err)
+		}
+		names := lo.SliceToMap(members, func(member entity.User) (string, string) {
+			return member.ID, member.DisplayName
+		})
+		name := func(id string) string {
+			if id == user.ID {
+				return "您"
+			}
+			if name, ok := names[id]; ok {
+				return name
+			}
+			return "已離開的成員"
+		}
+
+		balances := calc.Balances(lo.Map(expenses, func(expense entity.ExpenseWithSplitUser, _ int) calc.Expense {
+			return expense.TWDExpense()
+		}))
+		balance := balances[user.ID].Round(places)
+		digest := digestGroup{
+			Name:    group.Name,
+			Link:    fmt.Sprintf("%s/group/%s", s.publicURL, group.ID),
+			Balance: balance.Abs().StringFixed(places),
+			Owed:    balance.Sign() > 0,
+			Owes:    balance.Sign() < 0,
+		}
+		for _, transfer := range calc.SettleUp(balances, places) {
+			if transfer.From != user.ID && transfer.To != user.ID {
+				continue
+			}
+			digest.Transfers = append(digest.Transfers, digestTransfer{
+				From:   name(transfer.From),
+				To:     name(transfer.To),
+				Amount: transfer.Amount.StringFixed(places),
+			})
+		}
+
+		recent := lo.Filter(expenses, func(expense entity.ExpenseWithSplitUser, _ int) bool {
+			return expense.CreateAt.After(since) || expense.UpdateAt.After(since)
+		})
+		sort.Slice(recent, func(i, j int) bool {
+			return lastChange(recent[i]).After(lastChange(recent[j]))
+		})
+		for i, expense := range recent {
+			if i == activityLimit {
+				digest.Activity = append(digest.Activity, fmt.Sprintf("還有 %d 筆", len(recent)-activityLimit))
+				break
+			}
+			verb := "修改了"
+			if expense.CreateAt.After(since) {
+				verb = "新增了"
+			}
+			amount, _ := decimal.NewFromString(expense.Amount)
+			digest.Activity = append(digest.Activity, fmt.Sprintf("%s %s「%s」%s %s",
+				name(expense.CreatedBy), verb, expense.Description, amount.String(), expense.CurrencyCode))
+		}
+
+		if balance.IsZero() && len(digest.Activity) == 0 {
+			continue
+		}
+		data.Groups = append(data.Groups, digest)
+	}
+	return data, nil
+}
+
+func lastChange(expense entity.ExpenseWithSplitUser) time.Time {
+	if expense.UpdateAt.After(expense.CreateAt) {
+		return expense.UpdateAt
+	}
+	return expense.CreateAt
+}
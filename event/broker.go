@@ -0,0 +1,86 @@
+package event
+
+import (
+	"context"
+	"sync"
+)
+
+// streamBuffer is how many events a slow subscriber may fall behind before events are dropped.
+const streamBuffer = 16
+
+// Stream receives the events of one group for one user.
+type Stream struct {
+	C       <-chan Event
+	c       chan Event
+	groupID string
+	userID  string
+	closed  bool
+}
+
+// Broker fans out events to the group members connected to this process, it is subscribed to the Bus
+// so events are only seen after they were written.
+type Broker struct {
+	mu      sync.Mutex
+	streams map[string]map[*Stream]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		streams: make(map[string]map[*Stream]struct{}),
+	}
+}
+
+// Subscribe opens a stream of groupID's events, the caller checks userID is a member.
+func (b *Broker) Subscribe(groupID string, userID string) *Stream {
+	c := make(chan Event, streamBuffer)
+	stream := &Stream{C: c, c: c, groupID: groupID, userID: userID}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.streams[groupID] == nil {
+		b.streams[groupID] = make(map[*Stream]struct{})
+	}
+	b.streams[groupID][stream] = struct{}{}
+	return stream
+}
+
+// Unsubscribe closes the stream, it is safe to call more than once.
+func (b *Broker) Unsubscribe(stream *Stream) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.close(stream)
+}
+
+func (b *Broker) close(stream *Stream) {
+	if stream.closed {
+		return
+	}
+	stream.closed = true
+	close(stream.c)
+	delete(b.streams[stream.groupID], stream)
+	if len(b.streams[stream.groupID]) == 0 {
+		delete(b.streams, stream.groupID)
+	}
+}
+
+// Handle is a Handler, a removed member's streams of the group, or all of a deleted group,
+// are closed after the event.
+func (b *Broker) Handle(_ context.Context, e Event) {
+	if e.Type == MemberReminded {
+		// reminders do not change the group and are only for the reminded member
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for stream := range b.streams[e.GroupID] {
+		select {
+		case stream.c <- e:
+		default:
+			// the client is not keeping up, it has to reload when it reconnects
+			b.close(stream)
+			continue
+		}
+		if e.Type == GroupDeleted || (e.Type == MemberRemoved && e.MemberID == stream.userID) {
+			b.close(stream)
+		}
+	}
+}
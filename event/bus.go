@@ -0,0 +1,117 @@
+package event
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type Type string
+
+const (
+	ExpenseCreated Type = "expense_created"
+	ExpenseUpdated Type = "expense_updated"
+	CommentAdded   Type = "comment_added"
+	CommentDeleted Type = "comment_deleted"
+	MemberInvited  Type = "member_invited"
+	MemberRemoved  Type = "member_removed"
+	MemberReminded Type = "member_reminded"
+	GroupDeleted   Type = "group_deleted"
+)
+
+// Event is something a user did in a group, published after it was written to the database.
+type Event struct {
+	Type    Type
+	GroupID string
+	// ActorID is the user who caused the event, empty for automatic reminders.
+	ActorID   string
+	ExpenseID string
+	CommentID string
+	// MemberID is the user affected by member events.
+	MemberID string
+	Time     time.Time
+}
+
+type Handler func(ctx context.Context, e Event)
+
+const queueSize = 100
+
+// Bus delivers published events to the subscribed handlers in the background.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers []subscriber
+	queue       chan Event
+}
+
+// subscriber has its own queue and goroutine, so a handler waiting on the network does not hold up the others.
+type subscriber struct {
+	handle Handler
+	queue  chan Event
+}
+
+func NewBus() *Bus {
+	return &Bus{
+		queue: make(chan Event, queueSize),
+	}
+}
+
+// Subscribe registers h to receive every event published afterwards, it must be called before Run.
+func (b *Bus) Subscribe(h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers = append(b.subscribers, subscriber{
+		handle: h,
+		queue:  make(chan Event, queueSize),
+	})
+}
+
+// Publish queues e without blocking, it is dropped when the queue is full.
+func (b *Bus) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	select {
+	case b.queue <- e:
+	default:
+		slog.Warn("event queue full, dropping event", "type", e.Type, "group", e.GroupID)
+	}
+}
+
+// Run delivers events to the handlers until ctx is done, each handler gets the events in order.
+func (b *Bus) Run(ctx context.Context) error {
+	b.mu.RLock()
+	subscribers := b.subscribers
+	b.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for _, s := range subscribers {
+		wg.Add(1)
+		go func(s subscriber) {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case e := <-s.queue:
+					s.handle(ctx, e)
+				}
+			}
+		}(s)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e := <-b.queue:
+			for _, s := range subscribers {
+				select {
+				case s.queue <- e:
+				default:
+					slog.Warn("event handler queue full, dropping event", "type", e.Type, "group", e.GroupID)
+				}
+			}
+		}
+	}
+}
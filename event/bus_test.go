@@ -0,0 +1,50 @@
+package event
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBusSlowHandlerDoesNotBlockOthers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bus := NewBus()
+	release := make(chan struct{})
+	bus.Subscribe(func(ctx context.Context, e Event) {
+		// like a push service that does not answer
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+	})
+	received := make(chan Event, 3)
+	bus.Subscribe(func(ctx context.Context, e Event) {
+		received <- e
+	})
+	done := make(chan error)
+	go func() { done <- bus.Run(ctx) }()
+
+	for _, id := range []string{"a", "b", "c"} {
+		bus.Publish(Event{Type: ExpenseCreated, GroupID: id})
+	}
+	for _, want := range []string{"a", "b", "c"} {
+		select {
+		case e := <-received:
+			if e.GroupID != want {
+				t.Errorf("received group %s, want %s", e.GroupID, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("event not delivered while another handler is blocked")
+		}
+	}
+	close(release)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after ctx was done")
+	}
+}
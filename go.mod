@@ -5,6 +5,7 @@ go 1.22.1
 require (
 	github.com/georgysavva/scany/v2 v2.1.3
 	github.com/gin-gonic/gin v1.9.1
+	github.com/go-pdf/fpdf v0.9.0
 	github.com/golang-jwt/jwt/v5 v5.2.1
 	github.com/h2non/filetype v1.1.3
 	github.com/mattn/go-sqlite3 v1.14.22
@@ -13,6 +14,7 @@ require (
 	github.com/samber/lo v1.39.0
 	github.com/shopspring/decimal v1.4.0
 	golang.org/x/crypto v0.23.0
+	golang.org/x/image v0.18.0
 	golang.org/x/oauth2 v0.25.0
 	golang.org/x/sync v0.7.0
 )
@@ -41,7 +43,7 @@ require (
 	golang.org/x/exp v0.0.0-20220303212507-bbda1eaf7a17 // indirect
 	golang.org/x/net v0.24.0 // indirect
 	golang.org/x/sys v0.20.0 // indirect
-	golang.org/x/text v0.15.0 // indirect
+	golang.org/x/text v0.16.0 // indirect
 	google.golang.org/protobuf v1.34.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
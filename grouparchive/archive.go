@@ -0,0 +1,501 @@
+package grouparchive
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/h2non/filetype"
+	"github.com/rs/xid"
+	"github.com/samber/lo"
+	"github.com/waylen888/tab-buddy/blobstore"
+	"github.com/waylen888/tab-buddy/calc"
+	"github.com/waylen888/tab-buddy/config"
+	"github.com/waylen888/tab-buddy/db"
+	"github.com/waylen888/tab-buddy/db/entity"
+)
+
+const maxManifestSize = 64 << 20
+
+var (
+	// ErrInvalid is wrapped by errors about the content of an archive.
+	ErrInvalid = errors.New("invalid archive")
+	// ErrTooLarge is returned when the attachments of an archive are over the group quota.
+	ErrTooLarge = errors.New("the attachments of the archive are over the group quota")
+)
+
+type Archiver struct {
+	db    db.Database
+	blobs blobstore.Store
+}
+
+func New(db db.Database, blobs blobstore.Store) *Archiver {
+	return &Archiver{
+		db:    db,
+		blobs: blobs,
+	}
+}
+
+// Export writes group with everything in it to w.
+func (a *Archiver) Export(ctx context.Context, group entity.Group, format Format, w io.Writer) error {
+	manifest, err := a.manifest(ctx, group)
+	if err != nil {
+		return err
+	}
+	aw, err := newArchiveWriter(format, w)
+	if err != nil {
+		return err
+	}
+	body, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	fw, err := aw.create(manifestName, int64(len(body)), manifest.ExportedAt)
+	if err != nil {
+		return err
+	}
+	if _, err := fw.Write(body); err != nil {
+		return err
+	}
+
+	for _, expense := range manifest.Expenses {
+		for _, attachment := range expense.Attachments {
+			if err := a.exportAttachment(ctx, aw, attachment); err != nil {
+				return fmt.Errorf("export attachment %s: %w", attachment.ID, err)
+			}
+		}
+	}
+	return aw.Close()
+}
+
+func (a *Archiver) exportAttachment(ctx context.Context, aw archiveWriter, attachment Attachment) error {
+	file, info, err := a.blobs.Get(ctx, attachment.ID)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	fw, err := aw.create(attachmentsPrefix+attachment.ID, info.Size, attachment.CreateAt)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fw, file)
+	return err
+}
+
+func (a *Archiver) manifest(ctx context.Context, group entity.Group) (Manifest, error) {
+	manifest := Manifest{
+		Version:    Version,
+		ExportedAt: time.Now(),
+		Group: Group{
+			ID:                   group.ID,
+			Name:                 group.Name,
+			ConvertToTwd:         group.ConvertToTwd.Bool(),
+			ReminderIntervalDays: group.ReminderIntervalDays,
+			CreateAt:             group.CreateAt,
+		},
+	}
+	members, err := a.db.GetGroupMembers(group.ID)
+	if err != nil {
+		return manifest, fmt.Errorf("get group members: %w", err)
+	}
+	for _, member := range members {
+		manifest.Users = append(manifest.Users, User{ID: member.ID, DisplayName: member.DisplayName, Email: member.Email, Member: true})
+	}
+	expenses, err := a.db.GetGroupExpenses(group.ID)
+	if err != nil {
+		return manifest, fmt.Errorf("get group expenses: %w", err)
+	}
+	sort.SliceStable(expenses, func(i, j int) bool {
+		return expenses[i].Date.Before(expenses[j].Date)
+	})
+
+	referenced := make(map[string]bool)
+	for _, expense := range expenses {
+		exported, err := a.exportExpense(ctx, expense)
+		if err != nil {
+			return manifest, fmt.Errorf("expense %s: %w", expense.ID, err)
+		}
+		referenced[exported.CreatedBy] = true
+		for _, split := range exported.Splits {
+			referenced[split.UserID] = true
+		}
+		for _, comment := range exported.Comments {
+			referenced[comment.CreateBy] = true
+		}
+		manifest.Expenses = append(manifest.Expenses, exported)
+	}
+
+	// people who left the group are still needed for what they created
+	for _, member := range members {
+		delete(referenced, member.ID)
+	}
+	for _, id := range lo.Keys(referenced) {
+		user, err := a.db.GetUser(id)
+		if err != nil {
+			return manifest, fmt.Errorf("get user %s: %w", id, err)
+		}
+		manifest.Users = append(manifest.Users, User{ID: user.ID, DisplayName: user.DisplayName, Email: user.Email})
+	}
+	return manifest, nil
+}
+
+func (a *Archiver) exportExpense(ctx context.Context, expense entity.ExpenseWithSplitUser) (Expense, error) {
+	exported := Expense{
+		ID:          expense.ID,
+		Amount:      expense.Amount,
+		Description: expense.Description,
+		Date:        expense.Date,
+		Currency:    expense.CurrencyCode,
+		Category:    expense.Category,
+		TWDRate:     expense.TWDRate,
+		Note:        expense.Note,
+		CreatedBy:   expense.CreatedBy,
+		CreateAt:    expense.CreateAt,
+		UpdateAt:    expense.UpdateAt,
+		Splits: lo.Map(expense.SplitUsers, func(su entity.SplitUser, _ int) Split {
+			return Split{UserID: su.ID, Paid: su.Paid, Owed: su.Owed}
+		}),
+		Comments:    []Comment{},
+		Attachments: []Attachment{},
+	}
+	comments, err := a.db.GetExpenseComments(expense.ID)
+	if err != nil {
+		return exported, fmt.Errorf("get comments: %w", err)
+	}
+	slices.Reverse(comments)
+	for _, comment := range comments {
+		exported.Comments = append(exported.Comments, Comment{
+			ID:       comment.ID,
+			Content:  comment.Content,
+			CreateBy: comment.CreateBy,
+			CreateAt: comment.CreateAt,
+			UpdateAt: comment.UpdateAt,
+		})
+	}
+
+	attachments, err := a.db.GetExpenseAttachments(expense.ID)
+	if err != nil {
+		return exported, fmt.Errorf("get attachments: %w", err)
+	}
+	commentAttachments, err := a.db.GetCommentAttachments(expense.ID)
+	if err != nil {
+		return exported, fmt.Errorf("get comment attachments: %w", err)
+	}
+	for _, attachment := range append(attachments, commentAttachments...) {
+		if _, err := a.blobs.Stat(ctx, attachment.ID); err != nil {
+			if errors.Is(err, blobstore.ErrNotExist) {
+				slog.Warn("export attachment without file", "id", attachment.ID)
+				continue
+			}
+			return exported, fmt.Errorf("stat attachment %s: %w", attachment.ID, err)
+		}
+		exported.Attachments = append(exported.Attachments, Attachment{
+			ID:        attachment.ID,
+			Filename:  attachment.Filename,
+			Size:      attachment.Size,
+			MIME:      attachment.MIME,
+			CommentID: lo.FromPtr(attachment.CommentID),
+			CreateAt:  attachment.CreateAt,
+			UpdateAt:  attachment.UpdateAt,
+		})
+	}
+	return exported, nil
+}
+
+// Restored is a group created from an archive.
+type Restored struct {
+	Group        entity.Group
+	Placeholders int
+}
+
+// Restore creates a new group owned by ownerID from the archive in r of size bytes. The owner is
+// matched by their verified email, placeholders are created for everyone else, who can be invited
+// afterwards. Attachment files are checked against settings like uploads, the ones refused are left out.
+func (a *Archiver) Restore(ctx context.Context, r io.ReaderAt, size int64, ownerID string, settings config.AttachmentSetting) (Restored, error) {
+	ar, err := newArchiveReader(r, size)
+	if err != nil {
+		return Restored{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	name, fr, err := ar.next()
+	if err != nil || name != manifestName {
+		return Restored{}, fmt.Errorf("%w: the first file must be %s", ErrInvalid, manifestName)
+	}
+	var manifest Manifest
+	if err := json.NewDecoder(io.LimitReader(fr, maxManifestSize)).Decode(&manifest); err != nil {
+		return Restored{}, fmt.Errorf("%w: read manifest: %v", ErrInvalid, err)
+	}
+	if manifest.Version < 1 || manifest.Version > Version {
+		return Restored{}, fmt.Errorf("%w: manifest version %d is not supported", ErrInvalid, manifest.Version)
+	}
+
+	restored, args, attachments, err := a.plan(manifest, ownerID)
+	if err != nil {
+		return restored, err
+	}
+	if total := lo.SumBy(lo.Values(attachments), func(attachment *entity.ExpenseAttachment) int64 {
+		return attachment.Size
+	}); settings.GroupQuota > 0 && total > settings.GroupQuota {
+		return restored, ErrTooLarge
+	}
+
+	// files are stored under the new IDs before the rows, and removed again when anything fails
+	var stored []string
+	defer func() {
+		if err == nil {
+			return
+		}
+		for _, key := range stored {
+			if err := a.blobs.Delete(context.Background(), key); err != nil {
+				slog.Error("delete restored attachment", "key", key, "error", err)
+			}
+		}
+	}()
+	for {
+		name, fr, err = ar.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return restored, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+		attachment, ok := attachments[strings.TrimPrefix(name, attachmentsPrefix)]
+		if !strings.HasPrefix(name, attachmentsPrefix) || !ok || slices.Contains(stored, attachment.ID) {
+			continue
+		}
+		if attachment.Size > settings.MaxFileSize {
+			slog.Warn("restore skips attachment over the max file size", "filename", attachment.Filename, "size", attachment.Size)
+			continue
+		}
+		file := bufio.NewReaderSize(&sizedReader{r: fr, left: attachment.Size}, 512)
+		var detected string
+		detected, err = detectMIME(file, settings.AllowedMIMETypes)
+		if err == nil && detected == "" {
+			slog.Warn("restore skips attachment of a type not allowed", "filename", attachment.Filename, "mime", attachment.MIME)
+			continue
+		}
+		if err == nil {
+			attachment.MIME = detected
+			err = a.blobs.Put(ctx, attachment.ID, file, attachment.Size, detected)
+		}
+		if err != nil {
+			if errors.Is(err, errSize) {
+				err = fmt.Errorf("%w: %s: %v", ErrInvalid, name, errSize)
+			}
+			return restored, err
+		}
+		stored = append(stored, attachment.ID)
+	}
+
+	// attachments without a file in the archive are left out
+	for i := range args.Expenses {
+		args.Expenses[i].Attachments = lo.Filter(args.Expenses[i].Attachments, func(attachment entity.ExpenseAttachment, _ int) bool {
+			return slices.Contains(stored, attachment.ID)
+		})
+	}
+	restored.Group, err = a.db.RestoreGroup(args)
+	return restored, err
+}
+
+// plan maps the manifest to new IDs and users of this server, attachments are returned by their ID in the archive.
+func (a *Archiver) plan(manifest Manifest, ownerID string) (Restored, entity.RestoreGroupArguments, map[string]*entity.ExpenseAttachment, error) {
+	now := time.Now()
+	restored := Restored{}
+	args := entity.RestoreGroupArguments{
+		Group: entity.Group{
+			ID:                   xid.New().String(),
+			Name:                 manifest.Group.Name,
+			ConvertToTwd:         calc.TWDBool(manifest.Group.ConvertToTwd),
+			ReminderIntervalDays: manifest.Group.ReminderIntervalDays,
+			CreateAt:             now,
+			UpdateAt:             now,
+		},
+		MemberIDs: []string{ownerID},
+	}
+	invalid := func(format string, a ...any) (Restored, entity.RestoreGroupArguments, map[string]*entity.ExpenseAttachment, error) {
+		return restored, args, nil, fmt.Errorf("%w: %s", ErrInvalid, fmt.Sprintf(format, a...))
+	}
+	if strings.TrimSpace(args.Group.Name) == "" {
+		return invalid("the group has no name")
+	}
+
+	owner, err := a.db.GetUser(ownerID)
+	if err != nil {
+		return restored, args, nil, fmt.Errorf("get owner: %w", err)
+	}
+	userIDs := make(map[string]string, len(manifest.Users))
+	ownerMatched := false
+	for _, user := range manifest.Users {
+		if _, ok := userIDs[user.ID]; ok {
+			return invalid("user %s is listed twice", user.ID)
+		}
+		// other users of this server are never added without their consent
+		if !ownerMatched && matchOwner(user, owner) {
+			userIDs[user.ID] = ownerID
+			ownerMatched = true
+			continue
+		}
+		id := xid.New().String()
+		args.Placeholders = append(args.Placeholders, entity.User{
+			ID:          id,
+			Username:    "placeholder_" + id,
+			DisplayName: lo.Ternary(user.DisplayName != "", user.DisplayName, "?"),
+		})
+		restored.Placeholders++
+		userIDs[user.ID] = id
+		if user.Member {
+			args.MemberIDs = append(args.MemberIDs, id)
+		}
+	}
+	user := func(id string) (string, bool) {
+		mapped, ok := userIDs[id]
+		return mapped, ok
+	}
+
+	currencies := make(map[string]bool)
+	attachments := make(map[string]*entity.ExpenseAttachment)
+	for _, expense := range manifest.Expenses {
+		if !currencies[expense.Currency] {
+			if _, err := a.db.GetCurrency(expense.Currency); err != nil {
+				return invalid("expense %s: unknown currency %q", expense.ID, expense.Currency)
+			}
+			currencies[expense.Currency] = true
+		}
+		createdBy, ok := user(expense.CreatedBy)
+		if !ok {
+			return invalid("expense %s: unknown creator %s", expense.ID, expense.CreatedBy)
+		}
+		restoredExpense := entity.RestoreExpense{
+			ExpenseWithSplitUser: entity.ExpenseWithSplitUser{
+				Expense: entity.Expense{
+					ID:           xid.New().String(),
+					Amount:       expense.Amount,
+					Description:  expense.Description,
+					Date:         expense.Date,
+					CurrencyCode: expense.Currency,
+					Category:     expense.Category,
+					TWDRate:      expense.TWDRate,
+					Note:         expense.Note,
+					CreateAt:     expense.CreateAt,
+					UpdateAt:     expense.UpdateAt,
+					CreatedBy:    createdBy,
+				},
+			},
+		}
+		for _, split := range expense.Splits {
+			id, ok := user(split.UserID)
+			if !ok {
+				return invalid("expense %s: unknown user %s", expense.ID, split.UserID)
+			}
+			restoredExpense.SplitUsers = append(restoredExpense.SplitUsers, entity.SplitUser{
+				User: entity.User{ID: id},
+				Paid: split.Paid,
+				Owed: split.Owed,
+			})
+		}
+		commentIDs := make(map[string]string, len(expense.Comments))
+		for _, comment := range expense.Comments {
+			createBy, ok := user(comment.CreateBy)
+			if !ok {
+				return invalid("comment %s: unknown user %s", comment.ID, comment.CreateBy)
+			}
+			commentIDs[comment.ID] = xid.New().String()
+			restoredExpense.Comments = append(restoredExpense.Comments, entity.Comment{
+				ID:       commentIDs[comment.ID],
+				Content:  comment.Content,
+				CreateBy: createBy,
+				CreateAt: comment.CreateAt,
+				UpdateAt: comment.UpdateAt,
+			})
+		}
+		for _, attachment := range expense.Attachments {
+			var commentID *string
+			if attachment.CommentID != "" {
+				id, ok := commentIDs[attachment.CommentID]
+				if !ok {
+					return invalid("attachment %s: unknown comment %s", attachment.ID, attachment.CommentID)
+				}
+				commentID = &id
+			}
+			if attachment.Size < 0 {
+				return invalid("attachment %s: negative size", attachment.ID)
+			}
+			restoredExpense.Attachments = append(restoredExpense.Attachments, entity.ExpenseAttachment{
+				ID:        xid.New().String(),
+				Filename:  attachment.Filename,
+				Size:      attachment.Size,
+				MIME:      attachment.MIME,
+				CommentID: commentID,
+				CreateAt:  attachment.CreateAt,
+				UpdateAt:  attachment.UpdateAt,
+			})
+		}
+		args.Expenses = append(args.Expenses, restoredExpense)
+	}
+	// pointers are taken once the slices stopped growing
+	for i, expense := range manifest.Expenses {
+		for j, attachment := range expense.Attachments {
+			attachments[attachment.ID] = &args.Expenses[i].Attachments[j]
+		}
+	}
+	return restored, args, attachments, nil
+}
+
+// matchOwner reports whether user of the archive is owner, by the email owner proved to own.
+func matchOwner(user User, owner entity.User) bool {
+	return user.Email != "" && owner.EmailVerifiedAt != nil && strings.EqualFold(user.Email, owner.Email)
+}
+
+// detectMIME returns the content type detected from the first bytes of r the way uploads are
+// checked, empty when it is not recognized or not allowed.
+func detectMIME(r *bufio.Reader, allowed []string) (string, error) {
+	// filetype only needs the first 261 bytes
+	head, err := r.Peek(261)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	ftype, err := filetype.Match(head)
+	if err != nil {
+		return "", err
+	}
+	if ftype == filetype.Unknown || !lo.Contains(allowed, ftype.MIME.Value) {
+		return "", nil
+	}
+	return ftype.MIME.Value, nil
+}
+
+var errSize = errors.New("the file does not have the size in the manifest")
+
+// sizedReader fails unless r has exactly left bytes.
+type sizedReader struct {
+	r    io.Reader
+	left int64
+}
+
+func (r *sizedReader) Read(p []byte) (int, error) {
+	if r.left == 0 {
+		if n, _ := r.r.Read(make([]byte, 1)); n > 0 {
+			return 0, errSize
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > r.left {
+		p = p[:r.left]
+	}
+	n, err := r.r.Read(p)
+	r.left -= int64(n)
+	if errors.Is(err, io.EOF) {
+		if r.left > 0 {
+			return n, errSize
+		}
+		err = nil
+	}
+	return n, err
+}
@@ -0,0 +1,381 @@
+package grouparchive
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/xid"
+	"github.com/samber/lo"
+	"github.com/waylen888/tab-buddy/blobstore"
+	"github.com/waylen888/tab-buddy/config"
+	"github.com/waylen888/tab-buddy/db"
+	"github.com/waylen888/tab-buddy/db/entity"
+	"github.com/waylen888/tab-buddy/db/sqlite"
+)
+
+var testSettings = config.AttachmentSetting{
+	MaxFileSize:      1 << 20,
+	AllowedMIMETypes: []string{"image/png", "application/pdf"},
+}
+
+type archiveTest struct {
+	dir      string
+	db       db.Database
+	blobs    *blobstore.Local
+	archiver *Archiver
+	group    entity.Group
+	// alice verified her email, bob did not
+	alice entity.User
+	bob   entity.User
+	// receipt is the attachment of the expense, photo the one of the comment of bob
+	receipt entity.ExpenseAttachment
+	photo   entity.ExpenseAttachment
+	png     []byte
+}
+
+// newArchiveTest returns a group of alice and bob with one expense, a comment and three
+// attachments, one of them html sent as a png.
+func newArchiveTest(t *testing.T) *archiveTest {
+	t.Helper()
+	test := &archiveTest{dir: t.TempDir()}
+	var err error
+	test.db, err = sqlite.New(context.Background(), filepath.Join(test.dir, "test.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.blobs, err = blobstore.NewLocal(filepath.Join(test.dir, "blobs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.archiver = New(test.db, test.blobs)
+
+	test.alice, err = test.db.CreateUser("alice", "Alice", "alice@example.com", "password", entity.UserCreateTypeDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := test.db.SetUserEmailVerified(test.alice.ID, test.alice.Email, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	test.bob, err = test.db.CreateUser("bob", "Bob", "bob@example.com", "password", entity.UserCreateTypeDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.group, err = test.db.CreateGroup("trip", test.alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := test.db.AddUserToGroupByUsername(test.group.ID, lo.ToPtr("bob"), nil); err != nil {
+		t.Fatal(err)
+	}
+	expense, err := test.db.CreateExpense(entity.CreateExpenseArguments{
+		GroupID:      test.group.ID,
+		Amount:       "300",
+		TWDRate:      "1",
+		Description:  "dinner",
+		Date:         time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		CurrencyCode: "TWD",
+		SplitUsers: []entity.SplitUser{
+			{User: entity.User{ID: test.alice.ID}, Paid: true, Owed: true},
+			{User: entity.User{ID: test.bob.ID}, Owed: true},
+		},
+		CreateByUserID: test.alice.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	comment, err := test.db.CreateComment(entity.CreateCommentArguments{ExpenseID: expense.ID, Content: "thanks", CreateBy: test.bob.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	test.png = buf.Bytes()
+	html := []byte("<html><script>alert(1)</script></html>")
+	attachment := func(filename string, data []byte) entity.ExpenseAttachment {
+		t.Helper()
+		now := time.Now()
+		attachment := entity.ExpenseAttachment{ID: xid.New().String(), Filename: filename, Size: int64(len(data)), MIME: "image/png", CreateAt: now, UpdateAt: now}
+		if err := test.blobs.Put(context.Background(), attachment.ID, bytes.NewReader(data), attachment.Size, attachment.MIME); err != nil {
+			t.Fatal(err)
+		}
+		return attachment
+	}
+	test.receipt = attachment("receipt.png", test.png)
+	if err := test.db.CreateExpenseAttachments(entity.CreateExpenseAttachmentsArgument{
+		ExpenseID:   expense.ID,
+		Attachments: []entity.ExpenseAttachment{test.receipt, attachment("page.png", html)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	test.photo = attachment("photo.png", test.png)
+	if err := test.db.CreateExpenseAttachments(entity.CreateExpenseAttachmentsArgument{
+		ExpenseID:   expense.ID,
+		CommentID:   &comment.ID,
+		Attachments: []entity.ExpenseAttachment{test.photo},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return test
+}
+
+func (test *archiveTest) export(t *testing.T, format Format) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := test.archiver.Export(context.Background(), test.group, format, &buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func (test *archiveTest) restore(archive []byte, ownerID string) (Restored, error) {
+	return test.archiver.Restore(context.Background(), bytes.NewReader(archive), int64(len(archive)), ownerID, testSettings)
+}
+
+func (test *archiveTest) blobKeys(t *testing.T) []string {
+	t.Helper()
+	var keys []string
+	if err := test.blobs.List(context.Background(), func(info blobstore.Info) error {
+		keys = append(keys, info.Key)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestExportRestore(t *testing.T) {
+	for _, format := range []Format{FormatZip, FormatTar} {
+		t.Run(string(format), func(t *testing.T) {
+			test := newArchiveTest(t)
+			restored, err := test.restore(test.export(t, format), test.alice.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if restored.Group.ID == test.group.ID || restored.Group.Name != "trip" {
+				t.Errorf("group = %+v", restored.Group)
+			}
+			// bob is a user here, but is not added to a group without his consent
+			if restored.Placeholders != 1 {
+				t.Errorf("placeholders = %d, want 1", restored.Placeholders)
+			}
+			members, err := test.db.GetGroupMembers(restored.Group.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(members) != 2 || !slices.ContainsFunc(members, func(u entity.User) bool { return u.ID == test.alice.ID }) {
+				t.Fatalf("members = %+v, want alice and a placeholder", members)
+			}
+			placeholder, _ := lo.Find(members, func(u entity.User) bool { return u.ID != test.alice.ID })
+			if placeholder.CreateType != entity.UserCreateTypePlaceholder || placeholder.DisplayName != "Bob" {
+				t.Errorf("placeholder = %+v", placeholder)
+			}
+
+			expenses, err := test.db.GetGroupExpenses(restored.Group.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(expenses) != 1 {
+				t.Fatalf("restored %d expenses, want 1", len(expenses))
+			}
+			expense := expenses[0]
+			if expense.Amount != "300" || expense.Description != "dinner" || expense.CreatedBy != test.alice.ID {
+				t.Errorf("expense = %+v", expense.Expense)
+			}
+			splitIDs := lo.Map(expense.SplitUsers, func(su entity.SplitUser, _ int) string { return su.ID })
+			sort.Strings(splitIDs)
+			want := []string{test.alice.ID, placeholder.ID}
+			sort.Strings(want)
+			if !slices.Equal(splitIDs, want) {
+				t.Errorf("split users = %v, want alice and the placeholder", splitIDs)
+			}
+			comments, err := test.db.GetExpenseComments(expense.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(comments) != 1 || comments[0].Content != "thanks" || comments[0].CreateBy != placeholder.ID {
+				t.Errorf("comments = %+v", comments)
+			}
+
+			// the html sent as a png is left out
+			attachments, err := test.db.GetExpenseAttachments(expense.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(attachments) != 1 || attachments[0].Filename != "receipt.png" || attachments[0].MIME != "image/png" || attachments[0].ID == test.receipt.ID {
+				t.Fatalf("attachments = %+v, want the receipt under a new ID", attachments)
+			}
+			commentAttachments, err := test.db.GetCommentAttachments(expense.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(commentAttachments) != 1 || commentAttachments[0].Filename != "photo.png" || lo.FromPtr(commentAttachments[0].CommentID) != comments[0].ID {
+				t.Errorf("comment attachments = %+v", commentAttachments)
+			}
+			for _, attachment := range append(attachments, commentAttachments...) {
+				file, _, err := test.blobs.Get(context.Background(), attachment.ID)
+				if err != nil {
+					t.Fatalf("get %s: %v", attachment.Filename, err)
+				}
+				data, err := io.ReadAll(file)
+				file.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(data, test.png) {
+					t.Errorf("%s restored with other content", attachment.Filename)
+				}
+			}
+			if keys := test.blobKeys(t); len(keys) != 5 {
+				t.Errorf("stored %v, want the three files and the two restored", keys)
+			}
+		})
+	}
+}
+
+func TestRestoreUnverifiedOwner(t *testing.T) {
+	test := newArchiveTest(t)
+	restored, err := test.restore(test.export(t, FormatZip), test.bob.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// bob did not prove to own his email, so he is not matched to himself either
+	if restored.Placeholders != 2 {
+		t.Errorf("placeholders = %d, want 2", restored.Placeholders)
+	}
+	members, err := test.db.GetGroupMembers(restored.Group.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 3 || slices.ContainsFunc(members, func(u entity.User) bool { return u.ID == test.alice.ID }) {
+		t.Errorf("members = %+v, want bob and two placeholders", members)
+	}
+}
+
+type zipEntry struct {
+	name string
+	body []byte
+}
+
+// rewriteZip copies the files of a zip archive through edit, which returns the body to write,
+// and appends extra.
+func rewriteZip(t *testing.T, archive []byte, edit func(name string, body []byte) []byte, extra ...zipEntry) []byte {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	write := func(name string, body []byte) {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range zr.File {
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		write(file.Name, edit(file.Name, body))
+	}
+	for _, entry := range extra {
+		write(entry.name, entry.body)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRestoreZipSlip(t *testing.T) {
+	test := newArchiveTest(t)
+	keep := func(name string, body []byte) []byte { return body }
+	archive := rewriteZip(t, test.export(t, FormatZip), keep,
+		zipEntry{"../evil.png", test.png},
+		zipEntry{attachmentsPrefix + "../../evil.png", test.png},
+		zipEntry{attachmentsPrefix + "../" + test.receipt.ID, test.png},
+		zipEntry{"/tmp/evil.png", test.png},
+	)
+	restored, err := test.restore(archive, test.alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := test.blobKeys(t); len(keys) != 5 {
+		t.Errorf("stored %v, want the three files and the two restored", keys)
+	}
+	if err := filepath.WalkDir(test.dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && strings.Contains(d.Name(), "evil") {
+			t.Errorf("%s written", path)
+		}
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat("/tmp/evil.png"); err == nil {
+		t.Error("/tmp/evil.png written")
+	}
+	expenses, err := test.db.GetGroupExpenses(restored.Group.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attachments, err := test.db.GetExpenseAttachments(expenses[0].ID); err != nil || len(attachments) != 1 {
+		t.Errorf("attachments = %+v, %v", attachments, err)
+	}
+}
+
+func TestRestoreSizeMismatch(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func([]byte) []byte
+	}{
+		{"shorter", func(body []byte) []byte { return body[:len(body)-5] }},
+		{"longer", func(body []byte) []byte { return append(body, "extra"...) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test := newArchiveTest(t)
+			archive := rewriteZip(t, test.export(t, FormatZip), func(name string, body []byte) []byte {
+				if name == attachmentsPrefix+test.receipt.ID {
+					return tt.edit(body)
+				}
+				return body
+			})
+			before := test.blobKeys(t)
+			if _, err := test.restore(archive, test.alice.ID); !errors.Is(err, ErrInvalid) {
+				t.Fatalf("restore = %v, want ErrInvalid", err)
+			}
+			// files stored before the mismatch was found are removed again
+			if keys := test.blobKeys(t); !slices.Equal(keys, before) {
+				t.Errorf("stored %v, want %v", keys, before)
+			}
+			if groups, err := test.db.GetGroups(test.alice.ID); err != nil || len(groups) != 1 {
+				t.Errorf("groups = %+v, %v, want only the original", groups, err)
+			}
+		})
+	}
+}
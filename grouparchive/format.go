@@ -0,0 +1,152 @@
+package grouparchive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+type Format string
+
+const (
+	FormatZip Format = "zip"
+	// FormatTar is a gzipped tar.
+	FormatTar Format = "tar"
+)
+
+// Extension is the file name extension of archives in format.
+func (f Format) Extension() string {
+	if f == FormatTar {
+		return "tar.gz"
+	}
+	return "zip"
+}
+
+func (f Format) ContentType() string {
+	if f == FormatTar {
+		return "application/gzip"
+	}
+	return "application/zip"
+}
+
+// archiveWriter writes files one after the other.
+type archiveWriter interface {
+	create(name string, size int64, modTime time.Time) (io.Writer, error)
+	Close() error
+}
+
+func newArchiveWriter(format Format, w io.Writer) (archiveWriter, error) {
+	switch format {
+	case FormatZip:
+		return zipWriter{zip.NewWriter(w)}, nil
+	case FormatTar:
+		gz := gzip.NewWriter(w)
+		return &tarWriter{gz: gz, tw: tar.NewWriter(gz)}, nil
+	}
+	return nil, fmt.Errorf("unknown archive format %q", format)
+}
+
+type zipWriter struct {
+	zw *zip.Writer
+}
+
+func (w zipWriter) create(name string, _ int64, modTime time.Time) (io.Writer, error) {
+	return w.zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modTime})
+}
+
+func (w zipWriter) Close() error {
+	return w.zw.Close()
+}
+
+type tarWriter struct {
+	gz *gzip.Writer
+	tw *tar.Writer
+}
+
+func (w *tarWriter) create(name string, size int64, modTime time.Time) (io.Writer, error) {
+	err := w.tw.WriteHeader(&tar.Header{Name: name, Size: size, Mode: 0644, ModTime: modTime, Typeflag: tar.TypeReg})
+	return w.tw, err
+}
+
+func (w *tarWriter) Close() error {
+	if err := w.tw.Close(); err != nil {
+		return err
+	}
+	return w.gz.Close()
+}
+
+// archiveReader returns the files of an archive in order, io.EOF after the last one.
+type archiveReader interface {
+	next() (name string, r io.Reader, err error)
+}
+
+// newArchiveReader detects the format of an archive of size bytes from its first bytes.
+func newArchiveReader(r io.ReaderAt, size int64) (archiveReader, error) {
+	magic := make([]byte, 4)
+	if _, err := r.ReadAt(magic, 0); err != nil {
+		return nil, fmt.Errorf("read archive: %w", err)
+	}
+	switch {
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")):
+		zr, err := zip.NewReader(r, size)
+		if err != nil {
+			return nil, err
+		}
+		return &zipReader{files: zr.File}, nil
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(bufio.NewReader(io.NewSectionReader(r, 0, size)))
+		if err != nil {
+			return nil, err
+		}
+		return tarReader{tar.NewReader(gz)}, nil
+	}
+	return nil, errors.New("not a zip or gzipped tar archive")
+}
+
+type zipReader struct {
+	files []*zip.File
+	open  io.Closer
+}
+
+func (r *zipReader) next() (string, io.Reader, error) {
+	if r.open != nil {
+		r.open.Close()
+		r.open = nil
+	}
+	for len(r.files) > 0 {
+		file := r.files[0]
+		r.files = r.files[1:]
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return "", nil, err
+		}
+		r.open = rc
+		return file.Name, rc, nil
+	}
+	return "", nil, io.EOF
+}
+
+type tarReader struct {
+	tr *tar.Reader
+}
+
+func (r tarReader) next() (string, io.Reader, error) {
+	for {
+		header, err := r.tr.Next()
+		if err != nil {
+			return "", nil, err
+		}
+		if header.Typeflag == tar.TypeReg {
+			return header.Name, r.tr, nil
+		}
+	}
+}
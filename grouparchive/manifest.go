@@ -0,0 +1,82 @@
+// Package grouparchive moves a whole group between servers as a zip or gzipped tar archive.
+//
+// The archive holds manifest.json, which must be its first file, and the files of the
+// attachments as attachments/<attachment id>.
+package grouparchive
+
+import "time"
+
+// Version is the version of the manifest written, newer manifests are refused.
+const Version = 1
+
+const (
+	manifestName      = "manifest.json"
+	attachmentsPrefix = "attachments/"
+)
+
+type Manifest struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exportedAt"`
+	Group      Group     `json:"group"`
+	Users      []User    `json:"users"`
+	Expenses   []Expense `json:"expenses"`
+}
+
+type Group struct {
+	ID                   string    `json:"id"`
+	Name                 string    `json:"name"`
+	ConvertToTwd         bool      `json:"convertToTwd"`
+	ReminderIntervalDays int       `json:"reminderIntervalDays"`
+	CreateAt             time.Time `json:"createAt"`
+}
+
+// User is everyone the group refers to, only the user restoring the archive is matched by email.
+type User struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+	Email       string `json:"email"`
+	// Member is false for users who left the group but still created expenses or comments.
+	Member bool `json:"member"`
+}
+
+type Expense struct {
+	ID          string       `json:"id"`
+	Amount      string       `json:"amount"`
+	Description string       `json:"description"`
+	Date        time.Time    `json:"date"`
+	Currency    string       `json:"currency"`
+	Category    string       `json:"category"`
+	TWDRate     string       `json:"twdRate"`
+	Note        string       `json:"note"`
+	CreatedBy   string       `json:"createdBy"`
+	CreateAt    time.Time    `json:"createAt"`
+	UpdateAt    time.Time    `json:"updateAt"`
+	Splits      []Split      `json:"splits"`
+	Comments    []Comment    `json:"comments"`
+	Attachments []Attachment `json:"attachments"`
+}
+
+type Split struct {
+	UserID string `json:"userId"`
+	Paid   bool   `json:"paid"`
+	Owed   bool   `json:"owed"`
+}
+
+type Comment struct {
+	ID       string    `json:"id"`
+	Content  string    `json:"content"`
+	CreateBy string    `json:"createBy"`
+	CreateAt time.Time `json:"createAt"`
+	UpdateAt time.Time `json:"updateAt"`
+}
+
+type Attachment struct {
+	ID       string `json:"id"`
+	Filename string `json:"filename"`
+	Size     int64  `json:"size"`
+	MIME     string `json:"mime"`
+	// CommentID is set for attachments posted in a comment.
+	CommentID string    `json:"commentId,omitempty"`
+	CreateAt  time.Time `json:"createAt"`
+	UpdateAt  time.Time `json:"updateAt"`
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/waylen888/tab-buddy/db"
+	"github.com/waylen888/tab-buddy/finmind"
+	"github.com/waylen888/tab-buddy/importer"
+)
+
+// memberFlags collects -member name=username flags.
+type memberFlags map[string]string
+
+func (m memberFlags) String() string { return fmt.Sprint(map[string]string(m)) }
+
+func (m memberFlags) Set(value string) error {
+	name, username, ok := strings.Cut(value, "=")
+	if !ok || name == "" || username == "" {
+		return fmt.Errorf("%q is not name=username", value)
+	}
+	m[name] = username
+	return nil
+}
+
+// importExpenses imports a Splitwise or generic CSV into a group as the given user. It only
+// previews the import unless asked to,
+// usage: tabbuddy import -group ID -user USERNAME [-format splitwise|generic] [-member name=username]... [-duplicates] [-apply] FILE
+func importExpenses(database db.Database, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	groupID := flags.String("group", "", "id of the group to import into")
+	username := flags.String("user", "", "username of the member importing, they become the creator of the expenses")
+	format := flags.String("format", string(importer.FormatSplitwise), "format of the file, splitwise or generic")
+	duplicates := flags.Bool("duplicates", false, "also import expenses that look like ones already in the group")
+	apply := flags.Bool("apply", false, "create the expenses instead of only previewing them")
+	members := memberFlags{}
+	flags.Var(members, "member", "map a name in the file to the username of a member, can be repeated")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *groupID == "" || *username == "" || flags.NArg() != 1 {
+		return fmt.Errorf("usage: tabbuddy import -group ID -user USERNAME [flags] FILE")
+	}
+
+	user, err := database.GetUserByUsername(*username)
+	if err != nil {
+		return fmt.Errorf("get user %s: %w", *username, err)
+	}
+	if _, err := database.GetGroup(*groupID, user.ID); err != nil {
+		return fmt.Errorf("get group %s of %s: %w", *groupID, *username, err)
+	}
+	opts := importer.Options{
+		GroupID:    *groupID,
+		UserID:     user.ID,
+		Members:    make(map[string]string, len(members)),
+		Duplicates: *duplicates,
+	}
+	for name, username := range members {
+		member, err := database.GetUserByUsername(username)
+		if err != nil {
+			return fmt.Errorf("get user %s: %w", username, err)
+		}
+		opts.Members[name] = member.ID
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	rows, err := importer.Parse(importer.Format(*format), file)
+	if err != nil {
+		return fmt.Errorf("parse %s: %w", flags.Arg(0), err)
+	}
+
+	imports := importer.New(database, finmind.NewClient())
+	var plan importer.Plan
+	if *apply {
+		plan, err = imports.Import(rows, opts)
+	} else {
+		plan, err = imports.Preview(rows, opts)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, person := range plan.People {
+		slog.Info("person", "name", person.Name, "member", person.DisplayName, "id", person.UserID, "placeholder", person.Placeholder)
+	}
+	counts := make(map[importer.Status]int)
+	for _, expense := range plan.Expenses {
+		counts[expense.Status]++
+		attrs := []any{"line", expense.Line, "status", expense.Status,
+			"date", expense.Date.Format("2006-01-02"), "description", expense.Description,
+			"amount", expense.Amount, "currency", expense.Currency, "paidBy", expense.PaidBy, "owedBy", expense.OwedBy}
+		switch {
+		case expense.Problem != "":
+			slog.Warn("expense", append(attrs, "problem", expense.Problem)...)
+		default:
+			slog.Info("expense", attrs...)
+		}
+	}
+	slog.Info("import",
+		"new", counts[importer.StatusNew],
+		"duplicate", counts[importer.StatusDuplicate],
+		"invalid", counts[importer.StatusInvalid],
+		"imported", counts[importer.StatusImported],
+		"applied", *apply,
+	)
+	return nil
+}
@@ -0,0 +1,248 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/rs/xid"
+	"github.com/samber/lo"
+	"github.com/shopspring/decimal"
+	"github.com/waylen888/tab-buddy/db"
+	"github.com/waylen888/tab-buddy/db/entity"
+	"github.com/waylen888/tab-buddy/finmind"
+)
+
+// ErrMember is wrapped by errors about matching the people of a file to members of the group.
+var ErrMember = errors.New("cannot match people to members")
+
+type Options struct {
+	GroupID string
+	// UserID is who imports, they become the creator of the expenses.
+	UserID string
+	// Members maps names used in the file to IDs of group members, other names are matched to
+	// members by display name, username or email and become placeholders when nobody matches.
+	Members map[string]string
+	// Duplicates imports expenses that look like ones already in the group or earlier in the file.
+	Duplicates bool
+}
+
+type Status string
+
+const (
+	StatusNew       Status = "new"
+	StatusDuplicate Status = "duplicate"
+	StatusInvalid   Status = "invalid"
+	StatusImported  Status = "imported"
+)
+
+// Person is someone named in the file and the member they are imported as.
+type Person struct {
+	Name        string
+	UserID      string
+	DisplayName string
+	// Placeholder is a member without an account created for the import, UserID is set once imported.
+	Placeholder bool
+}
+
+type Expense struct {
+	Row
+	Status Status
+}
+
+// Plan is what an import does, expenses are only imported when their status is new,
+// or duplicate when asked to.
+type Plan struct {
+	People   []Person
+	Expenses []Expense
+}
+
+type Importer struct {
+	db    db.Database
+	rates finmind.TaiwanExchangeRateGetter
+}
+
+func New(db db.Database, rates finmind.TaiwanExchangeRateGetter) *Importer {
+	return &Importer{
+		db:    db,
+		rates: rates,
+	}
+}
+
+// Preview plans the import of rows without changing anything.
+func (im *Importer) Preview(rows []Row, opts Options) (Plan, error) {
+	var plan Plan
+	existing, err := im.db.GetGroupExpenses(opts.GroupID)
+	if err != nil {
+		return plan, fmt.Errorf("get group expenses: %w", err)
+	}
+	seen := make(map[string]bool)
+	for _, expense := range existing {
+		amount, _ := decimal.NewFromString(expense.Amount)
+		seen[duplicateKey(expense.Date.Format("2006-01-02"), expense.CurrencyCode, amount, expense.Description)] = true
+	}
+
+	rates := map[string]decimal.Decimal{"TWD": decimal.NewFromInt(1)}
+	for _, row := range rows {
+		expense := Expense{Row: row, Status: StatusNew}
+		if expense.Problem == "" {
+			expense.Problem = im.checkCurrency(&expense.Row, rates)
+		}
+		if expense.Problem != "" {
+			expense.Status = StatusInvalid
+			plan.Expenses = append(plan.Expenses, expense)
+			continue
+		}
+		key := duplicateKey(row.Date.Format("2006-01-02"), row.Currency, row.Amount, row.Description)
+		if seen[key] {
+			expense.Status = StatusDuplicate
+		}
+		seen[key] = true
+		plan.Expenses = append(plan.Expenses, expense)
+	}
+
+	plan.People, err = im.matchPeople(plan.importing(opts), opts)
+	return plan, err
+}
+
+// Import creates the expenses of the plan of rows in one transaction, with placeholders
+// for people who are not members.
+func (im *Importer) Import(rows []Row, opts Options) (Plan, error) {
+	plan, err := im.Preview(rows, opts)
+	if err != nil {
+		return plan, err
+	}
+
+	var placeholders []entity.User
+	ids := make(map[string]string, len(plan.People))
+	for i := range plan.People {
+		person := &plan.People[i]
+		if person.Placeholder {
+			person.UserID = xid.New().String()
+			placeholders = append(placeholders, entity.User{
+				ID:          person.UserID,
+				Username:    "placeholder_" + person.UserID,
+				DisplayName: person.DisplayName,
+			})
+		}
+		ids[person.Name] = person.UserID
+	}
+
+	importing := plan.importing(opts)
+	args := make([]entity.CreateExpenseArguments, 0, len(importing))
+	for _, expense := range importing {
+		// several names may be mapped to the same member
+		var splitUsers []entity.SplitUser
+		split := func(name string, paid bool, owed bool) {
+			id := ids[name]
+			if i := lo.IndexOf(lo.Map(splitUsers, func(su entity.SplitUser, _ int) string { return su.ID }), id); i >= 0 {
+				splitUsers[i].Paid = splitUsers[i].Paid || paid
+				splitUsers[i].Owed = splitUsers[i].Owed || owed
+				return
+			}
+			splitUsers = append(splitUsers, entity.SplitUser{User: entity.User{ID: id}, Paid: paid, Owed: owed})
+		}
+		split(expense.PaidBy, true, false)
+		for _, name := range expense.OwedBy {
+			split(name, false, true)
+		}
+		args = append(args, entity.CreateExpenseArguments{
+			GroupID:        opts.GroupID,
+			Amount:         expense.Amount.String(),
+			TWDRate:        expense.TWDRate.String(),
+			Description:    expense.Description,
+			Date:           expense.Date,
+			CurrencyCode:   expense.Currency,
+			Category:       expense.Category,
+			Note:           expense.Note,
+			SplitUsers:     splitUsers,
+			CreateByUserID: opts.UserID,
+		})
+	}
+	if len(args) == 0 {
+		return plan, nil
+	}
+	if _, err := im.db.CreateExpenses(opts.GroupID, placeholders, args); err != nil {
+		return plan, err
+	}
+	for i := range plan.Expenses {
+		if plan.Expenses[i].imported(opts) {
+			plan.Expenses[i].Status = StatusImported
+		}
+	}
+	return plan, nil
+}
+
+func (e Expense) imported(opts Options) bool {
+	return e.Status == StatusNew || (e.Status == StatusDuplicate && opts.Duplicates)
+}
+
+func (plan Plan) importing(opts Options) []Expense {
+	return lo.Filter(plan.Expenses, func(expense Expense, _ int) bool {
+		return expense.imported(opts)
+	})
+}
+
+// checkCurrency fills in the TWD rate of the row, it returns the problem when there is none.
+func (im *Importer) checkCurrency(row *Row, rates map[string]decimal.Decimal) string {
+	if _, err := im.db.GetCurrency(row.Currency); err != nil {
+		return fmt.Sprintf("unknown currency %q", row.Currency)
+	}
+	if !row.TWDRate.IsZero() {
+		return ""
+	}
+	rate, ok := rates[row.Currency]
+	if !ok {
+		var err error
+		if rate, err = im.rates.GetExchangeRate(row.Currency); err != nil {
+			return fmt.Sprintf("get the exchange rate of %s: %v", row.Currency, err)
+		}
+		rates[row.Currency] = rate
+	}
+	row.TWDRate = rate
+	return ""
+}
+
+// matchPeople finds the member for everyone named in the expenses, in the order they are named.
+func (im *Importer) matchPeople(expenses []Expense, opts Options) ([]Person, error) {
+	members, err := im.db.GetGroupMembers(opts.GroupID)
+	if err != nil {
+		return nil, fmt.Errorf("get group members: %w", err)
+	}
+	byID := lo.KeyBy(members, func(member entity.User) string { return member.ID })
+
+	var names []string
+	for _, expense := range expenses {
+		names = append(names, expense.PaidBy)
+		names = append(names, expense.OwedBy...)
+	}
+	people := make([]Person, 0)
+	for _, name := range lo.Uniq(names) {
+		if id, ok := opts.Members[name]; ok {
+			member, ok := byID[id]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s is mapped to %s who is not a member", ErrMember, name, id)
+			}
+			people = append(people, Person{Name: name, UserID: member.ID, DisplayName: member.DisplayName})
+			continue
+		}
+		matches := lo.Filter(members, func(member entity.User, _ int) bool {
+			return strings.EqualFold(member.DisplayName, name) || strings.EqualFold(member.Username, name) ||
+				(member.Email != "" && strings.EqualFold(member.Email, name))
+		})
+		switch len(matches) {
+		case 0:
+			people = append(people, Person{Name: name, DisplayName: name, Placeholder: true})
+		case 1:
+			people = append(people, Person{Name: name, UserID: matches[0].ID, DisplayName: matches[0].DisplayName})
+		default:
+			return nil, fmt.Errorf("%w: %s matches more than one member, map it to one", ErrMember, name)
+		}
+	}
+	return people, nil
+}
+
+// duplicateKey is the same for expenses on the same day with the same amount and description.
+func duplicateKey(date string, currency string, amount decimal.Decimal, description string) string {
+	return strings.Join([]string{date, currency, amount.String(), strings.ToLower(strings.TrimSpace(description))}, "\x00")
+}
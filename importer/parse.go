@@ -0,0 +1,264 @@
+// Package importer reads expenses exported from other apps into a group.
+//
+// Two formats are read. Splitwise is the CSV of "Export as spreadsheet" in Splitwise:
+//
+//	Date,Description,Category,Cost,Currency,Alice,Bob
+//	2024-05-01,Dinner,Dining out,60.00,USD,30.00,-30.00
+//
+// where each person column is what the person is owed by the expense, negative when they owe.
+// Tab Buddy splits expenses equally, so rows with unequal shares cannot be imported.
+//
+// Generic is a CSV with a header row naming its columns, in any order:
+//
+//	date,description,amount,currency,paid_by,owed_by,category,note,twd_rate
+//	2024-05-01,Dinner,1200,TWD,Alice,Alice;Bob;Carol,food,,
+//
+// date (YYYY-MM-DD), description, amount, currency, paid_by and owed_by are required,
+// category, note and twd_rate are optional. paid_by is the one person who paid and owed_by the
+// people sharing the amount equally, separated by semicolons. The current exchange rate is used
+// when twd_rate is empty. People are named by display name, username or email.
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/shopspring/decimal"
+)
+
+type Format string
+
+const (
+	FormatSplitwise Format = "splitwise"
+	FormatGeneric   Format = "generic"
+)
+
+// Row is an expense read from a file, people are referred to by the names used in the file.
+type Row struct {
+	// Line is the line of the row in the file, for messages.
+	Line        int
+	Date        time.Time
+	Description string
+	Category    string
+	Note        string
+	Currency    string
+	Amount      decimal.Decimal
+	// TWDRate is zero when the file does not tell.
+	TWDRate decimal.Decimal
+	PaidBy  string
+	OwedBy  []string
+	// Problem is why the row cannot be imported.
+	Problem string
+}
+
+// Parse reads the rows of a file, rows that cannot be imported are returned with a Problem.
+func Parse(format Format, r io.Reader) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("the file is empty")
+		}
+		return nil, err
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+	header = lo.Map(header, func(name string, _ int) string { return strings.TrimSpace(name) })
+
+	// parse returns false for rows that are not expenses, e.g. totals
+	var parse func(record []string) (Row, bool)
+	switch format {
+	case FormatSplitwise:
+		parse, err = splitwiseParser(header)
+	case FormatGeneric:
+		parse, err = genericParser(header)
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []Row
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if lo.EveryBy(record, func(field string) bool { return strings.TrimSpace(field) == "" }) {
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		row, ok := parse(record)
+		if !ok {
+			continue
+		}
+		row.Line = line
+		rows = append(rows, row)
+	}
+}
+
+func splitwiseParser(header []string) (func(record []string) (Row, bool), error) {
+	const people = 5
+	if len(header) <= people || !slices.Equal(lo.Map(header[:people], func(name string, _ int) string {
+		return strings.ToLower(name)
+	}), []string{"date", "description", "category", "cost", "currency"}) {
+		return nil, fmt.Errorf("not a Splitwise export, the columns must start with Date,Description,Category,Cost,Currency and a column per person")
+	}
+	names := header[people:]
+	return func(record []string) (Row, bool) {
+		field := func(i int) string {
+			if i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		row := Row{
+			Description: field(1),
+			Category:    field(2),
+			Currency:    strings.ToUpper(field(4)),
+		}
+		if strings.EqualFold(row.Description, "Total balance") {
+			return row, false
+		}
+		date, err := parseDate(field(0))
+		if err != nil {
+			row.Problem = err.Error()
+			return row, true
+		}
+		row.Date = date
+		if row.Amount, err = parseAmount(field(3)); err != nil {
+			row.Problem = err.Error()
+			return row, true
+		}
+
+		// a payer is owed the amount less their own share, everyone else owes their share
+		var payers []string
+		shares := make(map[string]decimal.Decimal)
+		for i, name := range names {
+			value := field(people + i)
+			if value == "" {
+				continue
+			}
+			net, err := decimal.NewFromString(value)
+			if err != nil {
+				row.Problem = fmt.Sprintf("%s: %q is not a number", name, value)
+				return row, true
+			}
+			switch net.Sign() {
+			case 1:
+				payers = append(payers, name)
+				if share := row.Amount.Sub(net); share.Sign() > 0 {
+					shares[name] = share
+				}
+			case -1:
+				shares[name] = net.Neg()
+			}
+		}
+		switch {
+		case len(payers) == 0:
+			row.Problem = "nobody owes anything"
+			return row, true
+		case len(payers) > 1:
+			row.Problem = "paid by more than one person"
+			return row, true
+		}
+		row.PaidBy = payers[0]
+		for _, name := range names {
+			if _, ok := shares[name]; ok {
+				row.OwedBy = append(row.OwedBy, name)
+			}
+		}
+		equal := row.Amount.Div(decimal.NewFromInt(int64(len(row.OwedBy))))
+		for _, share := range shares {
+			if share.Sub(equal).Abs().GreaterThan(decimal.New(1, -2)) {
+				row.Problem = "the amount is not split equally, only equal splits can be imported"
+				return row, true
+			}
+		}
+		return row, true
+	}, nil
+}
+
+func genericParser(header []string) (func(record []string) (Row, bool), error) {
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(name)] = i
+	}
+	for _, required := range []string{"date", "description", "amount", "currency", "paid_by", "owed_by"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("the %s column is missing", required)
+		}
+	}
+	return func(record []string) (Row, bool) {
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		row := Row{
+			Description: field("description"),
+			Category:    field("category"),
+			Note:        field("note"),
+			Currency:    strings.ToUpper(field("currency")),
+			PaidBy:      field("paid_by"),
+			OwedBy: lo.Uniq(lo.Compact(lo.Map(strings.Split(field("owed_by"), ";"), func(name string, _ int) string {
+				return strings.TrimSpace(name)
+			}))),
+		}
+		date, err := parseDate(field("date"))
+		if err != nil {
+			row.Problem = err.Error()
+			return row, true
+		}
+		row.Date = date
+		if row.Amount, err = parseAmount(field("amount")); err != nil {
+			row.Problem = err.Error()
+			return row, true
+		}
+		if rate := field("twd_rate"); rate != "" {
+			if row.TWDRate, err = decimal.NewFromString(rate); err != nil || row.TWDRate.Sign() <= 0 {
+				row.Problem = fmt.Sprintf("twd_rate %q is not a positive number", rate)
+				return row, true
+			}
+		}
+		switch {
+		case row.Description == "":
+			row.Problem = "the description is empty"
+		case row.PaidBy == "":
+			row.Problem = "paid_by is empty"
+		case len(row.OwedBy) == 0:
+			row.Problem = "owed_by is empty"
+		}
+		return row, true
+	}, nil
+}
+
+func parseDate(value string) (time.Time, error) {
+	date, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("date %q is not YYYY-MM-DD", value)
+	}
+	return date, nil
+}
+
+func parseAmount(value string) (decimal.Decimal, error) {
+	amount, err := decimal.NewFromString(strings.ReplaceAll(value, ",", ""))
+	if err != nil || amount.Sign() <= 0 {
+		return decimal.Zero, fmt.Errorf("amount %q is not a positive number", value)
+	}
+	return amount, nil
+}
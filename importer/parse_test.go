@@ -0,0 +1,122 @@
+package importer
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestParseSplitwise(t *testing.T) {
+	// the header starts with a byte order mark like Excel writes
+	file := "\ufeffDate,Description,Category,Cost,Currency,Alice,Bob,Carol\n" +
+		"2024-05-01,Dinner,Dining out,90.00,twd,60.00,-30.00,-30.00\n" +
+		"2024-05-02,Taxi,Taxi,40.00,TWD,-40.00,40.00,0.00\n" +
+		"2024-05-03,Hotel,Hotel,1000.00,TWD,-700.00,1000.00,-300.00\n" +
+		"2024-05-04,Tickets,General,60.00,TWD,30.00,30.00,-60.00\n" +
+		"2024-05-05,Nothing,General,20.00,TWD,0.00,0.00,0.00\n" +
+		"05/06/2024,Lunch,General,30.00,TWD,20.00,-10.00,-10.00\n" +
+		"2024-05-07,Snacks,General,30.00,TWD,20.00,-10.00,ten\n" +
+		",,,,,,,\n" +
+		"2024-05-31,Total balance, , ,TWD,-620.00,1040.00,-420.00\n"
+	rows, err := Parse(FormatSplitwise, strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		line    int
+		paidBy  string
+		owedBy  []string
+		problem string
+	}{
+		{2, "Alice", []string{"Alice", "Bob", "Carol"}, ""},
+		{3, "Bob", []string{"Alice"}, ""},
+		{4, "Bob", []string{"Alice", "Carol"}, "the amount is not split equally, only equal splits can be imported"},
+		{5, "", nil, "paid by more than one person"},
+		{6, "", nil, "nobody owes anything"},
+		{7, "", nil, `date "05/06/2024" is not YYYY-MM-DD`},
+		{8, "", nil, `Carol: "ten" is not a number`},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("got %d rows, want %d: %+v", len(rows), len(want), rows)
+	}
+	for i, row := range rows {
+		if row.Line != want[i].line || row.PaidBy != want[i].paidBy || !slices.Equal(row.OwedBy, want[i].owedBy) || row.Problem != want[i].problem {
+			t.Errorf("row %d: line %d, paid by %q, owed by %q, problem %q, want %+v", i, row.Line, row.PaidBy, row.OwedBy, row.Problem, want[i])
+		}
+	}
+	dinner := rows[0]
+	if dinner.Description != "Dinner" || dinner.Category != "Dining out" || dinner.Currency != "TWD" ||
+		!dinner.Amount.Equal(decimal.RequireFromString("90")) || dinner.Date.Format("2006-01-02") != "2024-05-01" {
+		t.Errorf("dinner = %+v", dinner)
+	}
+}
+
+func TestParseSplitwiseHeader(t *testing.T) {
+	for _, header := range []string{
+		"Date,Description,Category,Cost,Currency\n",
+		"Date,Description,Cost,Currency,Alice,Bob\n",
+	} {
+		if _, err := Parse(FormatSplitwise, strings.NewReader(header)); err == nil {
+			t.Errorf("header %q accepted", header)
+		}
+	}
+}
+
+func TestParseGeneric(t *testing.T) {
+	file := "Amount,Date,Description,Currency,Paid_By,Owed_By,Category,Note,TWD_Rate\n" +
+		"\"1,200\",2024-05-01,Dinner,twd,Alice, Alice ; Bob;;Alice ,food,birthday,\n" +
+		"30,2024-05-02,Coffee,USD,Bob,Bob,,,32.5\n" +
+		"30,2024-05-03,Coffee,USD,Bob,Bob,,,-1\n" +
+		"0,2024-05-04,Free,TWD,Bob,Bob,,,\n" +
+		"10,2024-05-05,,TWD,Bob,Bob,,,\n" +
+		"10,2024-05-06,Gift,TWD,,Bob,,,\n" +
+		"10,2024-05-07,Gift,TWD,Bob,,,,\n"
+	rows, err := Parse(FormatGeneric, strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	problems := []string{
+		"",
+		"",
+		`twd_rate "-1" is not a positive number`,
+		`amount "0" is not a positive number`,
+		"the description is empty",
+		"paid_by is empty",
+		"owed_by is empty",
+	}
+	if len(rows) != len(problems) {
+		t.Fatalf("got %d rows, want %d: %+v", len(rows), len(problems), rows)
+	}
+	for i, row := range rows {
+		if row.Problem != problems[i] {
+			t.Errorf("row %d: problem %q, want %q", i, row.Problem, problems[i])
+		}
+	}
+
+	dinner := rows[0]
+	if !dinner.Amount.Equal(decimal.RequireFromString("1200")) || dinner.Currency != "TWD" || dinner.PaidBy != "Alice" ||
+		!slices.Equal(dinner.OwedBy, []string{"Alice", "Bob"}) || dinner.Category != "food" || dinner.Note != "birthday" || !dinner.TWDRate.IsZero() {
+		t.Errorf("dinner = %+v", dinner)
+	}
+	if coffee := rows[1]; !coffee.TWDRate.Equal(decimal.RequireFromString("32.5")) {
+		t.Errorf("coffee rate = %s, want 32.5", coffee.TWDRate)
+	}
+}
+
+func TestParseGenericMissingColumn(t *testing.T) {
+	_, err := Parse(FormatGeneric, strings.NewReader("date,description,amount,currency,paid_by\n"))
+	if err == nil || !strings.Contains(err.Error(), "owed_by") {
+		t.Errorf("err = %v, want the owed_by column missing", err)
+	}
+}
+
+func TestParseEmpty(t *testing.T) {
+	if _, err := Parse(FormatGeneric, strings.NewReader("")); err == nil {
+		t.Error("empty file accepted")
+	}
+	if _, err := Parse("ods", strings.NewReader("a,b\n")); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
@@ -0,0 +1,59 @@
+package mail
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestMessageBuild(t *testing.T) {
+	date := time.Date(2024, 5, 1, 12, 30, 0, 0, time.FixedZone("CST", 8*60*60))
+	tests := []struct {
+		golden string
+		msg    Message
+	}{
+		{
+			golden: "plain.eml",
+			msg: Message{
+				To:      []string{"alice@example.com"},
+				Subject: "Login notification",
+				Text:    "Someone logged in as alice from 203.0.113.7.\n",
+			},
+		},
+		{
+			golden: "multipart.eml",
+			msg: Message{
+				To:      []string{"alice@example.com", "bob@example.com"},
+				Subject: "重設 Tab Buddy 密碼",
+				Text:    "請在一小時內開啟連結重設密碼：\nhttps://tab.example.com/reset-password?token=c2VjcmV0LXRva2VuLXRoYXQtaXMtbG9uZ2VyLXRoYW4tc2V2ZW50eS1zaXgtY2hhcmFjdGVycw\n",
+				HTML:    `<p>請在一小時內開啟<a href="https://tab.example.com/reset-password?token=c2VjcmV0">連結</a>重設密碼。</p>`,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.golden, func(t *testing.T) {
+			got, err := test.msg.build(`"Tab Buddy" <noreply@example.com>`, date, "0123456789abcdef@example.com", "b0undary")
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join("testdata", test.golden)
+			if *update {
+				if err := os.WriteFile(path, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("build differs from %s, rerun with -update after checking it\ngot:\n%s", path, got)
+			}
+		})
+	}
+}
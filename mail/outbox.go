@@ -0,0 +1,137 @@
+package mail
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/waylen888/tab-buddy/config"
+	"github.com/waylen888/tab-buddy/db"
+	"github.com/waylen888/tab-buddy/db/entity"
+)
+
+const (
+	// pollInterval is how often the outbox is checked for mails due to be retried.
+	pollInterval = time.Second * 15
+	batchSize    = 20
+)
+
+// Outbox stores mails in the database and sends them in the background, failed
+// mails are retried with exponential backoff until MaxAttempts is reached.
+type Outbox struct {
+	db          db.Database
+	sender      *Sender
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	wake        chan struct{}
+}
+
+func NewOutbox(db db.Database, sender *Sender, cfg config.OutboxSetting) *Outbox {
+	return &Outbox{
+		db:          db,
+		sender:      sender,
+		maxAttempts: cfg.MaxAttempts,
+		baseDelay:   time.Duration(cfg.BaseDelay),
+		maxDelay:    time.Duration(cfg.MaxDelay),
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Enqueue stores msg to be sent by Run.
+func (o *Outbox) Enqueue(msg Message) error {
+	_, err := o.db.CreateOutboxMail(entity.CreateOutboxMailArguments{
+		Recipients: msg.To,
+		Subject:    msg.Subject,
+		Text:       msg.Text,
+		HTML:       msg.HTML,
+	})
+	if err != nil {
+		return err
+	}
+	o.Wake()
+	return nil
+}
+
+// Wake makes Run look for due mails now instead of at the next poll.
+func (o *Outbox) Wake() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run sends queued mails until ctx is done.
+func (o *Outbox) Run(ctx context.Context) error {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		o.flush(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-o.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (o *Outbox) flush(ctx context.Context) {
+	for ctx.Err() == nil {
+		mails, err := o.db.GetDueOutboxMails(time.Now(), batchSize)
+		if err != nil {
+			slog.Error("get due outbox mails", "error", err)
+			return
+		}
+		for _, mail := range mails {
+			if ctx.Err() != nil {
+				return
+			}
+			o.send(mail)
+		}
+		if len(mails) < batchSize {
+			return
+		}
+	}
+}
+
+func (o *Outbox) send(mail entity.OutboxMail) {
+	err := o.sender.Send(Message{
+		To:      strings.Split(mail.Recipients, ","),
+		Subject: mail.Subject,
+		Text:    mail.Text,
+		HTML:    mail.HTML,
+	})
+	now := time.Now()
+	if err == nil {
+		if err := o.db.MarkOutboxMailSent(mail.ID, now); err != nil {
+			slog.Error("mark outbox mail sent", "id", mail.ID, "error", err)
+		}
+		return
+	}
+
+	attempts := mail.Attempts + 1
+	status := entity.OutboxMailStatusQueued
+	if attempts >= o.maxAttempts {
+		status = entity.OutboxMailStatusDead
+		slog.Error("send mail, giving up", "id", mail.ID, "attempts", attempts, "error", err)
+	} else {
+		slog.Warn("send mail", "id", mail.ID, "attempts", attempts, "error", err)
+	}
+	if err := o.db.MarkOutboxMailFailed(mail.ID, status, err.Error(), now.Add(o.backoff(attempts))); err != nil {
+		slog.Error("mark outbox mail failed", "id", mail.ID, "error", err)
+	}
+}
+
+// backoff is the wait after the given number of failed attempts.
+func (o *Outbox) backoff(attempts int) time.Duration {
+	delay := o.baseDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= o.maxDelay {
+			return o.maxDelay
+		}
+	}
+	return delay
+}
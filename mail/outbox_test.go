@@ -0,0 +1,35 @@
+package mail
+
+import (
+	"testing"
+	"time"
+
+	"github.com/waylen888/tab-buddy/config"
+)
+
+func TestOutboxBackoff(t *testing.T) {
+	outbox := NewOutbox(nil, nil, config.OutboxSetting{
+		MaxAttempts: 8,
+		BaseDelay:   config.Duration(time.Minute),
+		MaxDelay:    config.Duration(time.Hour),
+	})
+	want := []time.Duration{
+		1: time.Minute,
+		2: 2 * time.Minute,
+		3: 4 * time.Minute,
+		4: 8 * time.Minute,
+		5: 16 * time.Minute,
+		6: 32 * time.Minute,
+		7: time.Hour,
+		8: time.Hour,
+	}
+	for attempts := 1; attempts < len(want); attempts++ {
+		if got := outbox.backoff(attempts); got != want[attempts] {
+			t.Errorf("backoff(%d) = %s, want %s", attempts, got, want[attempts])
+		}
+	}
+	// doubling must not overflow after many attempts
+	if got := outbox.backoff(100); got != time.Hour {
+		t.Errorf("backoff(100) = %s, want %s", got, time.Hour)
+	}
+}
@@ -1,20 +1,32 @@
 package mail
 
 import (
-	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"mime/quotedprintable"
 	"net"
+	netmail "net/mail"
 	"net/smtp"
 	"strings"
+	"time"
 
 	"github.com/waylen888/tab-buddy/config"
 )
 
+const (
+	TLSStartTLS = "starttls"
+	TLSImplicit = "tls"
+	TLSNone     = "none"
+)
+
 type Sender struct {
 	hostport string
 	username string
 	password string
+	from     string
+	tlsMode  string
 }
 
 func NewSender(cfg config.SMTPSetting) *Sender {
@@ -22,64 +34,107 @@ func NewSender(cfg config.SMTPSetting) *Sender {
 		hostport: cfg.Host,
 		username: cfg.Username,
 		password: cfg.Password,
+		from:     cfg.From,
+		tlsMode:  cfg.TLS,
 	}
 }
 
-func (sender Sender) SendMail(dest []string, subject, bodyMessage string) error {
-	msg := "From: " + sender.username + "\n" +
-		"To: " + strings.Join(dest, ",") + "\n" +
-		"Subject: " + subject + "\n" + bodyMessage
+// Send delivers msg through the configured smtp server.
+func (sender *Sender) Send(msg Message) error {
+	from, err := netmail.ParseAddress(sender.from)
+	if err != nil {
+		return fmt.Errorf("parse from address: %w", err)
+	}
 	host, _, err := net.SplitHostPort(sender.hostport)
 	if err != nil {
 		return fmt.Errorf("split host port: %w", err)
 	}
-	return smtp.SendMail(
-		sender.hostport,
-		smtp.PlainAuth("", sender.username, sender.password, host),
-		sender.username,
-		dest,
-		[]byte(msg),
-	)
-}
-
-func (sender Sender) WriteEmail(dest []string, contentType, subject, bodyMessage string) string {
-	header := make(map[string]string)
-	header["From"] = sender.username
-
-	receipient := ""
-
-	for _, user := range dest {
-		receipient = receipient + user
+	data, err := msg.build(from.String(), time.Now(), newMessageID(from.Address), newBoundary())
+	if err != nil {
+		return fmt.Errorf("build message: %w", err)
 	}
 
-	header["To"] = receipient
-	header["Subject"] = subject
-	header["MIME-Version"] = "1.0"
-	header["Content-Type"] = fmt.Sprintf("%s; charset=\"utf-8\"", contentType)
-	header["Content-Transfer-Encoding"] = "quoted-printable"
-	header["Content-Disposition"] = "inline"
-
-	message := ""
-
-	for key, value := range header {
-		message += fmt.Sprintf("%s: %s\r\n", key, value)
+	client, err := sender.dial(host)
+	if err != nil {
+		return err
 	}
+	defer client.Close()
 
-	var encodedMessage bytes.Buffer
-
-	finalMessage := quotedprintable.NewWriter(&encodedMessage)
-	finalMessage.Write([]byte(bodyMessage))
-	finalMessage.Close()
+	if sender.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", sender.username, sender.password, host)); err != nil {
+			return fmt.Errorf("auth: %w", err)
+		}
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return fmt.Errorf("mail from: %w", err)
+	}
+	for _, to := range msg.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("rcpt to %s: %w", to, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("data: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("close message: %w", err)
+	}
+	return client.Quit()
+}
 
-	message += "\r\n" + encodedMessage.String()
+func (sender *Sender) dial(host string) (*smtp.Client, error) {
+	tlsConfig := &tls.Config{ServerName: host}
+	switch sender.tlsMode {
+	case TLSImplicit:
+		conn, err := tls.DialWithDialer(&net.Dialer{Timeout: time.Second * 30}, "tcp", sender.hostport, tlsConfig)
+		if err != nil {
+			return nil, fmt.Errorf("dial: %w", err)
+		}
+		return smtp.NewClient(conn, host)
+	case TLSStartTLS, TLSNone:
+		conn, err := net.DialTimeout("tcp", sender.hostport, time.Second*30)
+		if err != nil {
+			return nil, fmt.Errorf("dial: %w", err)
+		}
+		client, err := smtp.NewClient(conn, host)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if sender.tlsMode == TLSNone {
+			return client, nil
+		}
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, errors.New("server does not support STARTTLS")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("starttls: %w", err)
+		}
+		return client, nil
+	}
+	return nil, fmt.Errorf("unknown tls mode %q", sender.tlsMode)
+}
 
-	return message
+func newMessageID(from string) string {
+	domain := "localhost"
+	if i := strings.LastIndex(from, "@"); i >= 0 {
+		domain = from[i+1:]
+	}
+	return randomHex(12) + "@" + domain
 }
 
-func (sender *Sender) WriteHTMLEmail(dest []string, subject, bodyMessage string) string {
-	return sender.WriteEmail(dest, "text/html", subject, bodyMessage)
+func newBoundary() string {
+	return randomHex(15)
 }
 
-func (sender *Sender) WritePlainEmail(dest []string, subject, bodyMessage string) string {
-	return sender.WriteEmail(dest, "text/plain", subject, bodyMessage)
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
 }
@@ -0,0 +1,71 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+// template is a message kind, <name>.txt defines the "subject" and the text body,
+// <name>.html the "content" of the html body inside layout.html.
+type template struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+var templates = mustParseTemplates()
+
+func mustParseTemplates() map[string]template {
+	names, err := fs.Glob(templateFS, "templates/*.txt")
+	if err != nil {
+		panic(err)
+	}
+	parsed := make(map[string]template)
+	for _, name := range names {
+		base := strings.TrimSuffix(path.Base(name), ".txt")
+		t := template{
+			text: texttemplate.Must(texttemplate.ParseFS(templateFS, name)),
+		}
+		if _, err := fs.Stat(templateFS, "templates/"+base+".html"); err == nil {
+			t.html = htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/layout.html", "templates/"+base+".html"))
+		}
+		parsed[base] = t
+	}
+	return parsed
+}
+
+// Render builds the message name for the recipients from its templates.
+func Render(name string, to []string, data any) (Message, error) {
+	t, ok := templates[name]
+	if !ok {
+		return Message{}, fmt.Errorf("unknown mail template %q", name)
+	}
+	var subject, text bytes.Buffer
+	if err := t.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, fmt.Errorf("render subject: %w", err)
+	}
+	if err := t.text.Execute(&text, data); err != nil {
+		return Message{}, fmt.Errorf("render text: %w", err)
+	}
+	msg := Message{
+		To:      to,
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimLeft(text.String(), "\n"),
+	}
+	if t.html != nil {
+		var html bytes.Buffer
+		if err := t.html.ExecuteTemplate(&html, "layout.html", data); err != nil {
+			return Message{}, fmt.Errorf("render html: %w", err)
+		}
+		msg.HTML = html.String()
+	}
+	return msg, nil
+}
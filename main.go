@@ -3,7 +3,6 @@ package main
 import (
 	"context"
 	"flag"
-	"fmt"
 	"log/slog"
 	"os"
 
@@ -21,6 +20,14 @@ var (
 func main() {
 	flag.Parse()
 
+	if flag.Arg(0) == "vapid-keys" {
+		if err := printVAPIDKeys(); err != nil {
+			slog.Error("generate vapid keys", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	slog.Info("start tabbuddy")
 
 	slog.Info("load config", "path", *cfgPath)
@@ -36,6 +43,15 @@ func main() {
 		os.Exit(1)
 	}
 
+	switch flag.Arg(0) {
+	case "restore":
+		if err := restoreBackup(context.Background(), cfg, *databasePath, flag.Args()[1:]); err != nil {
+			slog.Error("restore", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	slog.Info("open sqlite", "path", *databasePath)
 	db, err := sqlite.New(context.TODO(), *databasePath)
 	if err != nil {
@@ -44,15 +60,67 @@ func main() {
 	}
 	defer db.Close()
 
+	if err := moveLocalAttachments(cfg, db); err != nil {
+		slog.Error("move local attachments", "error", err)
+		os.Exit(1)
+	}
+
+	switch flag.Arg(0) {
+	case "migrate-storage":
+		if err := migrateStorage(context.Background(), cfg, db, flag.Args()[1:]); err != nil {
+			slog.Error("migrate storage", "error", err)
+			os.Exit(1)
+		}
+		return
+	case "gc-attachments":
+		if err := gcAttachments(context.Background(), cfg, db, flag.Args()[1:]); err != nil {
+			slog.Error("gc attachments", "error", err)
+			os.Exit(1)
+		}
+		return
+	case "import":
+		if err := importExpenses(db, flag.Args()[1:]); err != nil {
+			slog.Error("import", "error", err)
+			os.Exit(1)
+		}
+		return
+	case "backup":
+		if err := createBackup(context.Background(), cfg, db, flag.Args()[1:]); err != nil {
+			slog.Error("backup", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	server, err := server.New(db, cfg)
+	if err != nil {
+		slog.Error("new server", "error", err)
+		os.Exit(1)
+	}
+
 	g, ctx := errgroup.WithContext(context.Background())
 
 	g.Go(func() error {
-		server, err := server.New(db, cfg)
-		if err != nil {
-			return fmt.Errorf("new server: %w", err)
-		}
 		return server.Run(ctx, cfg.HTTPSetting)
 	})
+	g.Go(func() error {
+		return server.RunReceiptProcessor(ctx)
+	})
+	g.Go(func() error {
+		return server.RunOutbox(ctx)
+	})
+	g.Go(func() error {
+		return server.RunEvents(ctx)
+	})
+	g.Go(func() error {
+		return server.RunDigests(ctx)
+	})
+	g.Go(func() error {
+		return server.RunReminders(ctx)
+	})
+	g.Go(func() error {
+		return server.RunBackups(ctx)
+	})
 
 	if err := g.Wait(); err != nil {
 		slog.Error("run server", "error", err)
@@ -0,0 +1,67 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/waylen888/tab-buddy/db"
+	"github.com/waylen888/tab-buddy/db/entity"
+	"github.com/waylen888/tab-buddy/mail"
+)
+
+// Inbox stores notifications to be read in the app.
+type Inbox struct {
+	db db.Database
+}
+
+func NewInbox(db db.Database) *Inbox {
+	return &Inbox{db: db}
+}
+
+func (*Inbox) Name() string { return ChannelInbox }
+
+func (inbox *Inbox) Send(_ context.Context, recipient entity.User, n Notification) error {
+	_, err := inbox.db.CreateNotification(entity.CreateNotificationArguments{
+		UserID:    recipient.ID,
+		Event:     string(n.Event),
+		GroupID:   n.GroupID,
+		ExpenseID: n.ExpenseID,
+		ActorID:   n.ActorID,
+		Title:     n.Title,
+		Body:      n.Body,
+		Link:      n.Link,
+	})
+	return err
+}
+
+// Email queues notifications in the mail outbox, users without an email are skipped.
+type Email struct {
+	outbox    *mail.Outbox
+	publicURL string
+}
+
+func NewEmail(outbox *mail.Outbox, publicURL string) *Email {
+	return &Email{
+		outbox:    outbox,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+	}
+}
+
+func (*Email) Name() string { return ChannelEmail }
+
+func (email *Email) Send(_ context.Context, recipient entity.User, n Notification) error {
+	if recipient.Email == "" {
+		return nil
+	}
+	msg, err := mail.Render("notification", []string{recipient.Email}, map[string]string{
+		"DisplayName": recipient.DisplayName,
+		"Title":       n.Title,
+		"Body":        n.Body,
+		"Link":        email.publicURL + n.Link,
+	})
+	if err != nil {
+		return fmt.Errorf("render mail: %w", err)
+	}
+	return email.outbox.Enqueue(msg)
+}
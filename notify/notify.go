@@ -0,0 +1,234 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/samber/lo"
+	"github.com/waylen888/tab-buddy/calc"
+	"github.com/waylen888/tab-buddy/db"
+	"github.com/waylen888/tab-buddy/db/entity"
+	"github.com/waylen888/tab-buddy/event"
+	"github.com/waylen888/tab-buddy/reminder"
+)
+
+const (
+	ChannelInbox = "inbox"
+	ChannelEmail = "email"
+	ChannelPush  = "push"
+)
+
+// Channels are all the channels users can set preferences for.
+var Channels = []string{ChannelInbox, ChannelEmail, ChannelPush}
+
+// Events are the event types users are notified about, in the order they are shown to users.
+var Events = []event.Type{event.ExpenseCreated, event.ExpenseUpdated, event.CommentAdded, event.MemberInvited, event.MemberReminded}
+
+// Notification is what a recipient is told about an event.
+type Notification struct {
+	Event     event.Type
+	GroupID   string
+	ExpenseID string
+	ActorID   string
+	Title     string
+	Body      string
+	// Link is the path of the page in the app, e.g. /group/:id/expense/:expense_id.
+	Link string
+}
+
+// Channel delivers notifications to a user.
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, recipient entity.User, n Notification) error
+}
+
+// DefaultEnabled reports whether channel is used for event when the user has not chosen.
+// Emails are only sent for invitations and reminders, push still needs the push notification setting.
+func DefaultEnabled(e event.Type, channel string) bool {
+	if channel == ChannelEmail {
+		return e == event.MemberInvited || e == event.MemberReminded
+	}
+	return true
+}
+
+// Dispatcher turns events into notifications and sends them to the involved users
+// through the channels they enabled.
+type Dispatcher struct {
+	db       db.Database
+	channels []Channel
+}
+
+func NewDispatcher(db db.Database, channels ...Channel) *Dispatcher {
+	return &Dispatcher{
+		db:       db,
+		channels: channels,
+	}
+}
+
+// Handle is an event.Handler, events not in Events are ignored.
+func (d *Dispatcher) Handle(ctx context.Context, e event.Event) {
+	if !slices.Contains(Events, e.Type) {
+		return
+	}
+	n, recipients, err := d.build(e)
+	if err != nil {
+		slog.Error("build notification", "type", e.Type, "group", e.GroupID, "error", err)
+		return
+	}
+	for _, recipient := range recipients {
+		enabled, err := d.enabledChannels(recipient.ID, e.Type)
+		if err != nil {
+			slog.Error("get notification preferences", "user", recipient.ID, "error", err)
+			continue
+		}
+		for _, channel := range d.channels {
+			if !enabled[channel.Name()] {
+				continue
+			}
+			if err := channel.Send(ctx, recipient, n); err != nil {
+				slog.Error("send notification", "channel", channel.Name(), "user", recipient.ID, "error", err)
+			}
+		}
+	}
+}
+
+func (d *Dispatcher) enabledChannels(userID string, e event.Type) (map[string]bool, error) {
+	enabled := make(map[string]bool, len(Channels))
+	for _, channel := range Channels {
+		enabled[channel] = DefaultEnabled(e, channel)
+	}
+	preferences, err := d.db.GetNotificationPreferences(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, preference := range preferences {
+		if preference.Event == string(e) {
+			enabled[preference.Channel] = preference.Enabled
+		}
+	}
+
+	setting, err := d.db.GetUserSetting(userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if !setting.PushNotification {
+		enabled[ChannelPush] = false
+	}
+	return enabled, nil
+}
+
+// build returns the notification for e and who receives it, the actor is never notified.
+func (d *Dispatcher) build(e event.Event) (Notification, []entity.User, error) {
+	n := Notification{
+		Event:     e.Type,
+		GroupID:   e.GroupID,
+		ExpenseID: e.ExpenseID,
+		ActorID:   e.ActorID,
+		Link:      fmt.Sprintf("/group/%s", e.GroupID),
+	}
+	// automatic reminders have no actor
+	actor := entity.User{DisplayName: "Tab Buddy"}
+	memberID := e.MemberID
+	if e.ActorID != "" {
+		var err error
+		actor, err = d.db.GetUser(e.ActorID)
+		if err != nil {
+			return n, nil, fmt.Errorf("get actor: %w", err)
+		}
+		memberID = e.ActorID
+	}
+	group, err := d.db.GetGroup(e.GroupID, memberID)
+	if err != nil {
+		return n, nil, fmt.Errorf("get group: %w", err)
+	}
+	n.Title = group.Name
+
+	var recipientIDs []string
+	switch e.Type {
+	case event.ExpenseCreated, event.ExpenseUpdated, event.CommentAdded:
+		expense, err := d.db.GetExpense(e.ExpenseID)
+		if err != nil {
+			return n, nil, fmt.Errorf("get expense: %w", err)
+		}
+		n.Link = fmt.Sprintf("/group/%s/expense/%s", e.GroupID, e.ExpenseID)
+		for _, user := range expense.SplitUsers {
+			if user.Paid || user.Owed {
+				recipientIDs = append(recipientIDs, user.ID)
+			}
+		}
+		switch e.Type {
+		case event.ExpenseCreated:
+			n.Body = fmt.Sprintf("%s 新增了「%s」%s %s", actor.DisplayName, expense.Description, expense.Amount, expense.CurrencyCode)
+		case event.ExpenseUpdated:
+			n.Body = fmt.Sprintf("%s 修改了「%s」%s %s", actor.DisplayName, expense.Description, expense.Amount, expense.CurrencyCode)
+		case event.CommentAdded:
+			comment, err := d.db.GetComment(e.CommentID)
+			if err != nil {
+				return n, nil, fmt.Errorf("get comment: %w", err)
+			}
+			// the creator follows the discussion even when not part of the split
+			recipientIDs = append(recipientIDs, expense.CreatedBy)
+			n.Body = fmt.Sprintf("%s 在「%s」留言：%s", actor.DisplayName, expense.Description, truncate(comment.Content, 100))
+		}
+	case event.MemberInvited:
+		recipientIDs = []string{e.MemberID}
+		n.Body = fmt.Sprintf("%s 邀請您加入「%s」", actor.DisplayName, group.Name)
+	case event.MemberReminded:
+		recipientIDs = []string{e.MemberID}
+		body, err := d.reminderBody(e, actor, group)
+		if err != nil {
+			return n, nil, err
+		}
+		n.Body = body
+	default:
+		return n, nil, fmt.Errorf("unknown event type %q", e.Type)
+	}
+
+	recipients := make([]entity.User, 0, len(recipientIDs))
+	for _, id := range lo.Uniq(recipientIDs) {
+		if id == e.ActorID {
+			continue
+		}
+		user, err := d.db.GetUser(id)
+		if err != nil {
+			return n, nil, fmt.Errorf("get recipient %s: %w", id, err)
+		}
+		recipients = append(recipients, user)
+	}
+	return n, recipients, nil
+}
+
+// reminderBody tells the member what they owe and to whom, from the balances at the time of the reminder.
+func (d *Dispatcher) reminderBody(e event.Event, actor entity.User, group entity.Group) (string, error) {
+	debt, err := reminder.GetDebt(d.db, e.GroupID, e.MemberID)
+	if err != nil {
+		return "", err
+	}
+	members, err := d.db.GetGroupMembers(e.GroupID)
+	if err != nil {
+		return "", fmt.Errorf("get group members: %w", err)
+	}
+	names := lo.SliceToMap(members, func(member entity.User) (string, string) {
+		return member.ID, member.DisplayName
+	})
+	body := fmt.Sprintf("%s 提醒您在「%s」尚欠 %s TWD", actor.DisplayName, group.Name, debt.Amount.StringFixed(debt.Places))
+	if len(debt.Transfers) > 0 {
+		body += "，建議" + strings.Join(lo.Map(debt.Transfers, func(transfer calc.Transfer, _ int) string {
+			return fmt.Sprintf("轉給 %s %s TWD", names[transfer.To], transfer.Amount.StringFixed(debt.Places))
+		}), "、")
+	}
+	return body, nil
+}
+
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n]) + "…"
+}
@@ -0,0 +1,77 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/waylen888/tab-buddy/db"
+	"github.com/waylen888/tab-buddy/db/entity"
+	"github.com/waylen888/tab-buddy/webpush"
+)
+
+// pushTTL is how long push services hold a notification for an offline browser.
+const pushTTL = time.Hour * 24
+
+// PushPayload is the json the service worker receives.
+type PushPayload struct {
+	Event string `json:"event"`
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	Link  string `json:"link"`
+}
+
+// Push sends notifications to every browser the recipient subscribed with.
+type Push struct {
+	db     db.Database
+	sender *webpush.Sender
+}
+
+func NewPush(db db.Database, sender *webpush.Sender) *Push {
+	return &Push{
+		db:     db,
+		sender: sender,
+	}
+}
+
+func (*Push) Name() string { return ChannelPush }
+
+func (push *Push) Send(ctx context.Context, recipient entity.User, n Notification) error {
+	subscriptions, err := push.db.GetUserPushSubscriptions(recipient.ID)
+	if err != nil {
+		return err
+	}
+	if len(subscriptions) == 0 {
+		return nil
+	}
+	payload, err := json.Marshal(PushPayload{
+		Event: string(n.Event),
+		Title: n.Title,
+		Body:  n.Body,
+		Link:  n.Link,
+	})
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, subscription := range subscriptions {
+		err := push.sender.Send(ctx, webpush.Subscription{
+			Endpoint: subscription.Endpoint,
+			P256dh:   subscription.P256dh,
+			Auth:     subscription.Auth,
+		}, payload, webpush.Options{TTL: pushTTL})
+		if errors.Is(err, webpush.ErrGone) {
+			slog.Info("remove expired push subscription", "user", recipient.ID, "id", subscription.ID)
+			if err := push.db.DeletePushSubscription("", subscription.Endpoint); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,243 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/waylen888/tab-buddy/config"
+	"golang.org/x/oauth2"
+)
+
+// Provider is an OpenID Connect identity provider, configured by discovery.
+type Provider struct {
+	Name string
+
+	cfg        config.OIDCProvider
+	httpClient *http.Client
+
+	mu       sync.Mutex
+	metadata *metadata
+	keys     map[string]any
+}
+
+type metadata struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	UserinfoEndpoint      string   `json:"userinfo_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	SigningAlgs           []string `json:"id_token_signing_alg_values_supported"`
+}
+
+// Identity is the verified user of an id token.
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+func NewProvider(cfg config.OIDCProvider) *Provider {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "profile", "email"}
+	}
+	return &Provider{
+		Name: cfg.Name,
+		cfg:  cfg,
+		httpClient: &http.Client{
+			Timeout: time.Second * 10,
+		},
+	}
+}
+
+// discover fetches the provider metadata once, it is retried on the next call if it failed.
+func (p *Provider) discover(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	var m metadata
+	wellKnown := strings.TrimSuffix(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(ctx, wellKnown, "", &m); err != nil {
+		return nil, fmt.Errorf("discovery: %w", err)
+	}
+	if m.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("discovery: issuer %q does not match %q", m.Issuer, p.cfg.Issuer)
+	}
+	p.metadata = &m
+	return p.metadata, nil
+}
+
+func (p *Provider) oauth2Config(ctx context.Context) (*oauth2.Config, error) {
+	m, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		RedirectURL:  p.cfg.RedirectURL,
+		Scopes:       p.cfg.Scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  m.AuthorizationEndpoint,
+			TokenURL: m.TokenEndpoint,
+		},
+	}, nil
+}
+
+// AuthCodeURL returns the url to redirect the user to, using PKCE with verifier.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	cfg, err := p.oauth2Config(ctx)
+	if err != nil {
+		return "", err
+	}
+	return cfg.AuthCodeURL(state,
+		oauth2.S256ChallengeOption(verifier),
+		oauth2.SetAuthURLParam("nonce", nonce),
+	), nil
+}
+
+// Exchange trades the authorization code for tokens and verifies the returned id token.
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (Identity, error) {
+	cfg, err := p.oauth2Config(ctx)
+	if err != nil {
+		return Identity{}, err
+	}
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.httpClient)
+	token, err := cfg.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return Identity{}, fmt.Errorf("exchange: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return Identity{}, errors.New("no id_token in token response")
+	}
+	identity, err := p.verifyIDToken(ctx, rawIDToken, nonce)
+	if err != nil {
+		return Identity{}, fmt.Errorf("verify id token: %w", err)
+	}
+
+	if identity.Email == "" || identity.Name == "" {
+		// some providers only put the profile in userinfo
+		if err := p.fillFromUserinfo(ctx, token.AccessToken, &identity); err != nil {
+			return Identity{}, fmt.Errorf("userinfo: %w", err)
+		}
+	}
+	return identity, nil
+}
+
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce         string `json:"nonce"`
+	Email         string `json:"email"`
+	EmailVerified any    `json:"email_verified"`
+	Name          string `json:"name"`
+}
+
+func (p *Provider) verifyIDToken(ctx context.Context, rawIDToken, nonce string) (Identity, error) {
+	m, err := p.discover(ctx)
+	if err != nil {
+		return Identity{}, err
+	}
+	algs := m.SigningAlgs
+	if len(algs) == 0 {
+		algs = []string{"RS256"}
+	}
+
+	var claims idTokenClaims
+	_, err = jwt.ParseWithClaims(rawIDToken, &claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, kid)
+	},
+		jwt.WithValidMethods(algs),
+		jwt.WithIssuer(m.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return Identity{}, err
+	}
+	if claims.Nonce != nonce {
+		return Identity{}, errors.New("nonce mismatch")
+	}
+	if claims.Subject == "" {
+		return Identity{}, errors.New("empty subject")
+	}
+	return Identity{
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: isTrue(claims.EmailVerified),
+		Name:          claims.Name,
+	}, nil
+}
+
+func (p *Provider) fillFromUserinfo(ctx context.Context, accessToken string, identity *Identity) error {
+	m, err := p.discover(ctx)
+	if err != nil {
+		return err
+	}
+	if m.UserinfoEndpoint == "" {
+		return nil
+	}
+	var info struct {
+		Subject       string `json:"sub"`
+		Email         string `json:"email"`
+		EmailVerified any    `json:"email_verified"`
+		Name          string `json:"name"`
+	}
+	if err := p.getJSON(ctx, m.UserinfoEndpoint, accessToken, &info); err != nil {
+		return err
+	}
+	if info.Subject != identity.Subject {
+		return errors.New("userinfo subject mismatch")
+	}
+	if identity.Email == "" {
+		identity.Email = info.Email
+		identity.EmailVerified = isTrue(info.EmailVerified)
+	}
+	if identity.Name == "" {
+		identity.Name = info.Name
+	}
+	return nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, accessToken string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("new request: %w", err)
+	}
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	res, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("do request: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, res.Body)
+		return fmt.Errorf("receive status: %s", res.Status)
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// isTrue accepts both boolean and string email_verified, some providers send "true".
+func isTrue(v any) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
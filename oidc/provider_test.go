@@ -0,0 +1,198 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/waylen888/tab-buddy/config"
+)
+
+// testIssuer serves discovery and a key set with one RSA and one EC key.
+type testIssuer struct {
+	server *httptest.Server
+	rsaKid string
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+	// jwksRequests counts fetches of the key set.
+	jwksRequests int
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := &testIssuer{rsaKid: "rsa", rsaKey: rsaKey, ecKey: ecKey}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(metadata{
+			Issuer:      issuer.server.URL,
+			JWKSURI:     issuer.server.URL + "/jwks",
+			SigningAlgs: []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		issuer.jwksRequests++
+		encode := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+		json.NewEncoder(w).Encode(map[string]any{"keys": []jsonWebKey{
+			{
+				Kid: issuer.rsaKid, Kty: "RSA", Use: "sig",
+				N: encode(issuer.rsaKey.N.Bytes()),
+				E: encode(big.NewInt(int64(issuer.rsaKey.E)).Bytes()),
+			},
+			{
+				Kid: "ec", Kty: "EC", Use: "sig", Crv: "P-256",
+				X: encode(ecKey.X.Bytes()),
+				Y: encode(ecKey.Y.Bytes()),
+			},
+			{Kid: "enc", Kty: "oct", Use: "enc"},
+		}})
+	})
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+func (i *testIssuer) provider() *Provider {
+	return NewProvider(config.OIDCProvider{
+		Name:     "test",
+		Issuer:   i.server.URL,
+		ClientID: "client",
+	})
+}
+
+// claims are valid for the provider and the nonce "nonce".
+func (i *testIssuer) claims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            i.server.URL,
+		"aud":            "client",
+		"sub":            "subject",
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          "nonce",
+		"email":          "alice@example.com",
+		"email_verified": "true",
+		"name":           "Alice",
+	}
+}
+
+func (i *testIssuer) sign(t *testing.T, method jwt.SigningMethod, kid string, key any, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	issuer := newTestIssuer(t)
+	provider := issuer.provider()
+
+	identity, err := provider.verifyIDToken(context.Background(), issuer.sign(t, jwt.SigningMethodRS256, "rsa", issuer.rsaKey, issuer.claims()), "nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Identity{Subject: "subject", Email: "alice@example.com", EmailVerified: true, Name: "Alice"}
+	if identity != want {
+		t.Errorf("identity = %+v, want %+v", identity, want)
+	}
+
+	// the key set is cached once fetched
+	if _, err := provider.verifyIDToken(context.Background(), issuer.sign(t, jwt.SigningMethodRS256, "rsa", issuer.rsaKey, issuer.claims()), "nonce"); err != nil {
+		t.Fatal(err)
+	}
+	if issuer.jwksRequests != 1 {
+		t.Errorf("key set fetched %d times, want 1", issuer.jwksRequests)
+	}
+}
+
+func TestVerifyIDTokenRejects(t *testing.T) {
+	issuer := newTestIssuer(t)
+	with := func(key string, value any) jwt.MapClaims {
+		claims := issuer.claims()
+		if value == nil {
+			delete(claims, key)
+		} else {
+			claims[key] = value
+		}
+		return claims
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		nonce string
+	}{
+		{"nonce mismatch", issuer.sign(t, jwt.SigningMethodRS256, "rsa", issuer.rsaKey, issuer.claims()), "other nonce"},
+		{"missing nonce", issuer.sign(t, jwt.SigningMethodRS256, "rsa", issuer.rsaKey, with("nonce", nil)), "nonce"},
+		{"wrong audience", issuer.sign(t, jwt.SigningMethodRS256, "rsa", issuer.rsaKey, with("aud", "other client")), "nonce"},
+		{"wrong issuer", issuer.sign(t, jwt.SigningMethodRS256, "rsa", issuer.rsaKey, with("iss", "https://evil.example.com")), "nonce"},
+		{"expired", issuer.sign(t, jwt.SigningMethodRS256, "rsa", issuer.rsaKey, with("exp", time.Now().Add(-time.Minute).Unix())), "nonce"},
+		{"no expiry", issuer.sign(t, jwt.SigningMethodRS256, "rsa", issuer.rsaKey, with("exp", nil)), "nonce"},
+		{"empty subject", issuer.sign(t, jwt.SigningMethodRS256, "rsa", issuer.rsaKey, with("sub", "")), "nonce"},
+		{"unknown key", issuer.sign(t, jwt.SigningMethodRS256, "other", otherKey, issuer.claims()), "nonce"},
+		{"signed by another key", issuer.sign(t, jwt.SigningMethodRS256, "rsa", otherKey, issuer.claims()), "nonce"},
+		// the provider only advertises RS256
+		{"unadvertised alg", issuer.sign(t, jwt.SigningMethodES256, "ec", issuer.ecKey, issuer.claims()), "nonce"},
+		{"alg none", issuer.sign(t, jwt.SigningMethodNone, "rsa", jwt.UnsafeAllowNoneSignatureType, issuer.claims()), "nonce"},
+		// the public key used as an HMAC secret
+		{"alg HS256", issuer.sign(t, jwt.SigningMethodHS256, "rsa", issuer.rsaKey.N.Bytes(), issuer.claims()), "nonce"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := issuer.provider().verifyIDToken(context.Background(), test.token, test.nonce); err == nil {
+				t.Error("token accepted")
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenRotatedKey(t *testing.T) {
+	issuer := newTestIssuer(t)
+	provider := issuer.provider()
+	retired := issuer.rsaKey
+	if _, err := provider.verifyIDToken(context.Background(), issuer.sign(t, jwt.SigningMethodRS256, "rsa", retired, issuer.claims()), "nonce"); err != nil {
+		t.Fatal(err)
+	}
+
+	// a key id missing from the cached set fetches the set again
+	rotated, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer.rsaKid, issuer.rsaKey = "rsa2", rotated
+	if _, err := provider.verifyIDToken(context.Background(), issuer.sign(t, jwt.SigningMethodRS256, "rsa2", rotated, issuer.claims()), "nonce"); err != nil {
+		t.Errorf("verify with the rotated key: %v", err)
+	}
+	if issuer.jwksRequests != 2 {
+		t.Errorf("key set fetched %d times, want 2", issuer.jwksRequests)
+	}
+	// the retired key is gone with the refetched set
+	if _, err := provider.verifyIDToken(context.Background(), issuer.sign(t, jwt.SigningMethodRS256, "rsa", retired, issuer.claims()), "nonce"); err == nil {
+		t.Error("token of the retired key id accepted")
+	}
+}
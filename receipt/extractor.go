@@ -0,0 +1,79 @@
+package receipt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os/exec"
+	"strings"
+
+	"github.com/waylen888/tab-buddy/config"
+)
+
+// ErrUnsupported is returned by an extractor for files it can not read.
+var ErrUnsupported = errors.New("unsupported file type")
+
+// Extractor reads the text of an attachment.
+type Extractor interface {
+	Extract(ctx context.Context, r io.Reader, mime string) (string, error)
+}
+
+// NewExtractor returns the extractor selected in cfg, "auto" uses tesseract when it is installed.
+func NewExtractor(cfg config.ReceiptSetting) (Extractor, error) {
+	switch cfg.Extractor {
+	case "none":
+		return Nop{}, nil
+	case "auto":
+		path, err := exec.LookPath(cfg.TesseractPath)
+		if err != nil {
+			slog.Info("tesseract not found, receipt text extraction disabled", "path", cfg.TesseractPath)
+			return Nop{}, nil
+		}
+		return Tesseract{Path: path, Languages: cfg.Languages}, nil
+	case "tesseract":
+		path, err := exec.LookPath(cfg.TesseractPath)
+		if err != nil {
+			return nil, fmt.Errorf("find tesseract: %w", err)
+		}
+		return Tesseract{Path: path, Languages: cfg.Languages}, nil
+	}
+	return nil, fmt.Errorf("unknown extractor %q", cfg.Extractor)
+}
+
+// Nop extracts nothing, every file is reported as unsupported.
+type Nop struct{}
+
+func (Nop) Extract(ctx context.Context, r io.Reader, mime string) (string, error) {
+	return "", ErrUnsupported
+}
+
+// Tesseract runs the tesseract OCR binary on images.
+type Tesseract struct {
+	Path string
+	// Languages are the trained data to use, e.g. "eng+chi_tra".
+	Languages string
+}
+
+func (t Tesseract) Extract(ctx context.Context, r io.Reader, mime string) (string, error) {
+	switch mime {
+	case "image/jpeg", "image/png", "image/webp", "image/gif":
+	default:
+		return "", ErrUnsupported
+	}
+	args := []string{"stdin", "stdout"}
+	if t.Languages != "" {
+		args = append(args, "-l", t.Languages)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, t.Path, args...)
+	cmd.Stdin = r
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("run tesseract: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
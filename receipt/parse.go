@@ -0,0 +1,176 @@
+package receipt
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/shopspring/decimal"
+)
+
+// Suggestion is what could be read off a receipt, fields are empty when not found.
+type Suggestion struct {
+	Total    string
+	Date     *time.Time
+	Merchant string
+}
+
+var (
+	amountPattern = regexp.MustCompile(`\d{1,3}(?:,\d{3})+(?:\.\d{1,2})?|\d+(?:\.\d{1,2})?`)
+	// 2024-05-01, 2024/5/1, 2024.05.01
+	isoDatePattern = regexp.MustCompile(`(\d{4})\s*[-/.年]\s*(\d{1,2})\s*[-/.月]\s*(\d{1,2})`)
+	// taiwan receipts print the ROC year, 113-05-01
+	rocDatePattern = regexp.MustCompile(`(?:^|[^\d])(1\d{2})\s*[-/.年]\s*(\d{1,2})\s*[-/.月]\s*(\d{1,2})`)
+	// 05/01/2024
+	usDatePattern = regexp.MustCompile(`(\d{1,2})/(\d{1,2})/(\d{4})`)
+)
+
+// totalKeywords are matched case insensitively in order, the first match of a line decides
+// its rank and lines with a lower rank win.
+var totalKeywords = []struct {
+	keyword string
+	rank    int
+}{
+	{"amount due", 0}, {"grand total", 0}, {"應付", 0},
+	{"總計", 1}, {"合計", 1}, {"總金額", 1},
+	{"subtotal", 3}, {"小計", 3},
+	{"total", 2},
+}
+
+// merchantSkipKeywords are lines at the top of a receipt that are not the merchant name.
+var merchantSkipKeywords = []string{
+	"receipt", "invoice", "統一發票", "電子發票", "發票", "證明聯", "tel", "電話", "www.", "http",
+}
+
+// Parse guesses the total, date and merchant of a receipt from its text.
+func Parse(text string, now time.Time) Suggestion {
+	lines := strings.Split(strings.ReplaceAll(text, "\r", ""), "\n")
+	return Suggestion{
+		Total:    parseTotal(lines),
+		Date:     parseDate(text, now),
+		Merchant: parseMerchant(lines),
+	}
+}
+
+func parseTotal(lines []string) string {
+	bestRank := -1
+	var best string
+	for i, line := range lines {
+		lower := strings.ToLower(line)
+		for _, keyword := range totalKeywords {
+			if !strings.Contains(lower, keyword.keyword) {
+				continue
+			}
+			// the last total of a rank wins, it is the one after discounts
+			if bestRank != -1 && keyword.rank > bestRank {
+				break
+			}
+			amount := lastAmount(line)
+			// the amount is often printed on the line after the label
+			if amount == "" && i+1 < len(lines) {
+				amount = lastAmount(lines[i+1])
+			}
+			if amount != "" {
+				bestRank, best = keyword.rank, amount
+			}
+			break
+		}
+	}
+	if best != "" {
+		return best
+	}
+
+	// no labelled total, the largest amount with decimals is the best guess
+	var largest decimal.Decimal
+	for _, line := range lines {
+		for _, match := range amountPattern.FindAllString(line, -1) {
+			if !strings.Contains(match, ".") {
+				continue
+			}
+			if amount, err := decimal.NewFromString(strings.ReplaceAll(match, ",", "")); err == nil && amount.GreaterThan(largest) {
+				largest = amount
+			}
+		}
+	}
+	if largest.IsPositive() {
+		return largest.String()
+	}
+	return ""
+}
+
+func lastAmount(line string) string {
+	matches := amountPattern.FindAllString(line, -1)
+	if len(matches) == 0 {
+		return ""
+	}
+	amount, err := decimal.NewFromString(strings.ReplaceAll(matches[len(matches)-1], ",", ""))
+	if err != nil || !amount.IsPositive() {
+		return ""
+	}
+	return amount.String()
+}
+
+func parseDate(text string, now time.Time) *time.Time {
+	valid := func(year, month, day int) *time.Time {
+		date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, now.Location())
+		// reject overflowed dates like 02-31 and anything not plausibly a recent receipt
+		if date.Month() != time.Month(month) || date.Day() != day || year < 2000 || date.After(now.AddDate(0, 0, 1)) {
+			return nil
+		}
+		return &date
+	}
+	for _, match := range isoDatePattern.FindAllStringSubmatch(text, -1) {
+		if date := valid(atoi(match[1]), atoi(match[2]), atoi(match[3])); date != nil {
+			return date
+		}
+	}
+	for _, match := range rocDatePattern.FindAllStringSubmatch(text, -1) {
+		if date := valid(atoi(match[1])+1911, atoi(match[2]), atoi(match[3])); date != nil {
+			return date
+		}
+	}
+	for _, match := range usDatePattern.FindAllStringSubmatch(text, -1) {
+		if date := valid(atoi(match[3]), atoi(match[1]), atoi(match[2])); date != nil {
+			return date
+		}
+	}
+	return nil
+}
+
+func parseMerchant(lines []string) string {
+	// the merchant is printed at the top, only look at the first few lines
+	for i, line := range lines {
+		if i >= 8 {
+			break
+		}
+		line = strings.TrimSpace(line)
+		lower := strings.ToLower(line)
+		skip := false
+		for _, keyword := range merchantSkipKeywords {
+			if strings.Contains(lower, keyword) {
+				skip = true
+				break
+			}
+		}
+		if skip {
+			continue
+		}
+		letters := 0
+		for _, r := range line {
+			if unicode.IsLetter(r) {
+				letters++
+			}
+		}
+		if letters >= 2 && letters*2 >= len([]rune(line)) {
+			return line
+		}
+	}
+	return ""
+}
+
+func atoi(s string) int {
+	i, _ := strconv.Atoi(s)
+	return i
+}
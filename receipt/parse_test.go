@@ -0,0 +1,83 @@
+package receipt
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		text     string
+		total    string
+		date     string
+		merchant string
+	}{
+		{
+			name: "english receipt, the total after the discount wins",
+			text: "RECEIPT\nBlue Bottle Coffee\nTel 02-1234-5678\n2024-05-01 09:30\n" +
+				"Latte            5.50\nCroissant        4.25\nSubtotal         9.75\n" +
+				"Total           10.73\nDiscount        -1.00\nTotal            9.73\n",
+			total:    "9.73",
+			date:     "2024-05-01",
+			merchant: "Blue Bottle Coffee",
+		},
+		{
+			name:     "grand total outranks total",
+			text:     "Corner Deli\nGrand Total 1,234.50\nTotal paid 2,000\nChange 765.50\n",
+			total:    "1234.5",
+			merchant: "Corner Deli",
+		},
+		{
+			name: "taiwan invoice with an roc date and the amount on the next line",
+			text: "電子發票證明聯\n全聯福利中心\n113-05-02 18:20:11\n" +
+				"牛奶 95\n麵包 45\n總計\n$140\n",
+			total:    "140",
+			date:     "2024-05-02",
+			merchant: "全聯福利中心",
+		},
+		{
+			name:     "us date",
+			text:     "Joe's Pizza\n05/03/2024\nTotal: $23.10\n",
+			total:    "23.1",
+			date:     "2024-05-03",
+			merchant: "Joe's Pizza",
+		},
+		{
+			name:     "no label, the largest amount with decimals",
+			text:     "Night Market\n3.50\n18.20\n7.00\n",
+			total:    "18.2",
+			merchant: "Night Market",
+		},
+		{
+			name:     "impossible and future dates are ignored",
+			text:     "Kiosk\n2024-02-31\n2024-06-01\n2024-05-09\nTotal 5\n",
+			total:    "5",
+			date:     "2024-05-09",
+			merchant: "Kiosk",
+		},
+		{
+			name: "nothing to read",
+			text: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse(tt.text, now)
+			if got.Total != tt.total {
+				t.Errorf("total = %q, want %q", got.Total, tt.total)
+			}
+			var date string
+			if got.Date != nil {
+				date = got.Date.Format("2006-01-02")
+			}
+			if date != tt.date {
+				t.Errorf("date = %q, want %q", date, tt.date)
+			}
+			if got.Merchant != tt.merchant {
+				t.Errorf("merchant = %q, want %q", got.Merchant, tt.merchant)
+			}
+		})
+	}
+}
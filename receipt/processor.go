@@ -0,0 +1,159 @@
+package receipt
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/waylen888/tab-buddy/blobstore"
+	"github.com/waylen888/tab-buddy/db"
+	"github.com/waylen888/tab-buddy/db/entity"
+)
+
+const (
+	// scanInterval is how often attachments missed by the queue are looked for.
+	scanInterval = time.Minute * 5
+	// extractTimeout bounds the extraction of a single attachment.
+	extractTimeout = time.Minute
+	queueSize      = 100
+	// maxScans bounds the receipts scanned for a new expense at the same time, they are
+	// extracted while the client waits.
+	maxScans = 2
+)
+
+// Processor extracts the text of uploaded attachments in the background.
+type Processor struct {
+	db        db.Database
+	blobs     blobstore.Store
+	extractor Extractor
+	queue     chan string
+	scans     chan struct{}
+}
+
+func NewProcessor(db db.Database, blobs blobstore.Store, extractor Extractor) *Processor {
+	return &Processor{
+		db:        db,
+		blobs:     blobs,
+		extractor: extractor,
+		queue:     make(chan string, queueSize),
+		scans:     make(chan struct{}, maxScans),
+	}
+}
+
+// Enqueue schedules an attachment for processing, when the queue is full it is
+// picked up by the next scan instead.
+func (p *Processor) Enqueue(attachmentID string) {
+	select {
+	case p.queue <- attachmentID:
+	default:
+	}
+}
+
+// Run processes attachments until ctx is done, including those uploaded while it was not running.
+func (p *Processor) Run(ctx context.Context) error {
+	ticker := time.NewTicker(scanInterval)
+	defer ticker.Stop()
+	p.scan(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case attachmentID := <-p.queue:
+			attachment, err := p.db.GetExpenseAttachment(attachmentID)
+			if err != nil {
+				if !errors.Is(err, sql.ErrNoRows) {
+					slog.Error("get attachment", "id", attachmentID, "error", err)
+				}
+				continue
+			}
+			if err := p.process(ctx, attachment); err != nil {
+				slog.Error("process attachment", "id", attachmentID, "error", err)
+			}
+		case <-ticker.C:
+			p.scan(ctx)
+		}
+	}
+}
+
+func (p *Processor) scan(ctx context.Context) {
+	for ctx.Err() == nil {
+		attachments, err := p.db.GetUnprocessedAttachments(50)
+		if err != nil {
+			slog.Error("get unprocessed attachments", "error", err)
+			return
+		}
+		if len(attachments) == 0 {
+			return
+		}
+		for _, attachment := range attachments {
+			// an attachment left unprocessed would be returned again, stop until the next scan
+			if err := p.process(ctx, attachment); err != nil {
+				slog.Error("process attachment", "id", attachment.ID, "error", err)
+				return
+			}
+		}
+	}
+}
+
+// process extracts the text of attachment and saves it, it fails when nothing was saved.
+func (p *Processor) process(ctx context.Context, attachment entity.ExpenseAttachment) error {
+	text := entity.AttachmentText{AttachmentID: attachment.ID}
+	extracted, err := p.extract(ctx, attachment)
+	switch {
+	case errors.Is(err, ErrUnsupported):
+		text.Status = entity.AttachmentTextStatusSkipped
+	case err != nil:
+		if ctx.Err() != nil {
+			// shutting down, try again on the next run
+			return ctx.Err()
+		}
+		slog.Warn("extract attachment text", "id", attachment.ID, "error", err)
+		text.Status = entity.AttachmentTextStatusFailed
+		text.Error = err.Error()
+	default:
+		suggestion := Parse(extracted, time.Now())
+		text.Status = entity.AttachmentTextStatusDone
+		text.Text = extracted
+		text.Total = suggestion.Total
+		text.Date = suggestion.Date
+		text.Merchant = suggestion.Merchant
+	}
+	text.CreateAt = time.Now()
+	if err := p.db.SaveAttachmentText(text); err != nil {
+		return fmt.Errorf("save attachment text: %w", err)
+	}
+	return nil
+}
+
+// Scan extracts the text of a receipt that is not stored as an attachment and parses it,
+// for suggesting the fields of a new expense.
+func (p *Processor) Scan(ctx context.Context, r io.Reader, mime string) (string, Suggestion, error) {
+	select {
+	case p.scans <- struct{}{}:
+		defer func() { <-p.scans }()
+	case <-ctx.Done():
+		return "", Suggestion{}, ctx.Err()
+	}
+	ctx, cancel := context.WithTimeout(ctx, extractTimeout)
+	defer cancel()
+	text, err := p.extractor.Extract(ctx, r, mime)
+	if err != nil {
+		return "", Suggestion{}, err
+	}
+	return text, Parse(text, time.Now()), nil
+}
+
+func (p *Processor) extract(ctx context.Context, attachment entity.ExpenseAttachment) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, extractTimeout)
+	defer cancel()
+	r, _, err := p.blobs.Get(ctx, attachment.ID)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	return p.extractor.Extract(ctx, r, attachment.MIME)
+}
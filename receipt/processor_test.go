@@ -0,0 +1,70 @@
+package receipt
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/waylen888/tab-buddy/blobstore"
+	"github.com/waylen888/tab-buddy/db"
+	"github.com/waylen888/tab-buddy/db/entity"
+)
+
+// failingDB returns the same unprocessed attachments until their text is saved, which always fails.
+type failingDB struct {
+	db.Database
+	gets  int
+	saves int
+}
+
+func (f *failingDB) GetUnprocessedAttachments(limit int) ([]entity.ExpenseAttachment, error) {
+	f.gets++
+	if f.gets > 3 {
+		// the scan should have stopped long ago, end it instead of hanging the test
+		return nil, nil
+	}
+	return []entity.ExpenseAttachment{{ID: "a", MIME: "image/png"}, {ID: "b", MIME: "image/png"}}, nil
+}
+
+func (f *failingDB) SaveAttachmentText(text entity.AttachmentText) error {
+	f.saves++
+	return errors.New("database is locked")
+}
+
+type textExtractor string
+
+func (t textExtractor) Extract(ctx context.Context, r io.Reader, mime string) (string, error) {
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		return "", err
+	}
+	return string(t), nil
+}
+
+func TestScanStopsWhenSaveFails(t *testing.T) {
+	blobs, err := blobstore.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	database := &failingDB{}
+	NewProcessor(database, blobs, Nop{}).scan(context.Background())
+	if database.gets != 1 || database.saves != 1 {
+		t.Errorf("scan got unprocessed attachments %d times and saved %d texts, want 1 and 1", database.gets, database.saves)
+	}
+}
+
+func TestProcessorScan(t *testing.T) {
+	processor := NewProcessor(nil, nil, textExtractor("Corner Deli\nTotal 12.50\n"))
+	text, suggestion, err := processor.Scan(context.Background(), strings.NewReader("image"), "image/png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "Corner Deli\nTotal 12.50\n" || suggestion.Total != "12.5" || suggestion.Merchant != "Corner Deli" {
+		t.Errorf("Scan() = %q, %+v", text, suggestion)
+	}
+
+	if _, _, err := NewProcessor(nil, nil, Nop{}).Scan(context.Background(), strings.NewReader("image"), "image/png"); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Scan() with no extractor: %v, want %v", err, ErrUnsupported)
+	}
+}
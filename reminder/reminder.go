@@ -0,0 +1,186 @@
+package reminder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/shopspring/decimal"
+	"github.com/waylen888/tab-buddy/calc"
+	"github.com/waylen888/tab-buddy/db"
+	"github.com/waylen888/tab-buddy/db/entity"
+	"github.com/waylen888/tab-buddy/event"
+)
+
+const (
+	// Throttle is the least time between two reminders to the same member of a group.
+	Throttle = 24 * time.Hour
+	// checkInterval is how often groups are checked for automatic reminders.
+	checkInterval = time.Hour
+)
+
+var ErrNothingOwed = errors.New("member does not owe anything")
+
+// ThrottledError is returned when the member was reminded less than Throttle ago.
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("member was already reminded, retry after %s", e.RetryAfter.Round(time.Second))
+}
+
+// Debt is what a member owes in a group, converted to TWD.
+type Debt struct {
+	Amount    decimal.Decimal
+	Places    int32
+	Transfers []calc.Transfer
+}
+
+// GetDebt returns what userID owes in groupID and the transfers suggested to settle it,
+// Amount is not positive when nothing is owed.
+func GetDebt(db db.Database, groupID string, userID string) (Debt, error) {
+	currency, err := db.GetCurrency("TWD")
+	if err != nil {
+		return Debt{}, fmt.Errorf("get currency: %w", err)
+	}
+	expenses, err := db.GetGroupExpenses(groupID)
+	if err != nil {
+		return Debt{}, fmt.Errorf("get group expenses: %w", err)
+	}
+	return debtOf(balances(expenses), userID, int32(currency.DecimalDigits)), nil
+}
+
+func balances(expenses []entity.ExpenseWithSplitUser) map[string]decimal.Decimal {
+	return calc.Balances(lo.Map(expenses, func(expense entity.ExpenseWithSplitUser, _ int) calc.Expense {
+		return expense.TWDExpense()
+	}))
+}
+
+func debtOf(balances map[string]decimal.Decimal, userID string, places int32) Debt {
+	return Debt{
+		Amount: balances[userID].Round(places).Neg(),
+		Places: places,
+		Transfers: lo.Filter(calc.SettleUp(balances, places), func(transfer calc.Transfer, _ int) bool {
+			return transfer.From == userID
+		}),
+	}
+}
+
+// Scheduler reminds debtors of what they owe, on request of a member or on the schedule of the group.
+type Scheduler struct {
+	db     db.Database
+	events *event.Bus
+}
+
+func NewScheduler(db db.Database, events *event.Bus) *Scheduler {
+	return &Scheduler{
+		db:     db,
+		events: events,
+	}
+}
+
+// Remind records a reminder to userID of what they owe in groupID and publishes it,
+// sentBy is empty for automatic reminders.
+func (s *Scheduler) Remind(groupID string, userID string, sentBy string) (entity.Reminder, error) {
+	debt, err := GetDebt(s.db, groupID, userID)
+	if err != nil {
+		return entity.Reminder{}, err
+	}
+	return s.remind(groupID, userID, sentBy, debt, time.Now(), Throttle)
+}
+
+// remind records the reminder unless userID was already reminded in groupID within wait before now.
+func (s *Scheduler) remind(groupID string, userID string, sentBy string, debt Debt, now time.Time, wait time.Duration) (entity.Reminder, error) {
+	if debt.Amount.Sign() <= 0 {
+		return entity.Reminder{}, ErrNothingOwed
+	}
+	reminder, err := s.db.CreateReminder(entity.CreateReminderArguments{
+		GroupID:  groupID,
+		UserID:   userID,
+		SentBy:   sentBy,
+		Amount:   debt.Amount.StringFixed(debt.Places),
+		CreateAt: now,
+		Since:    now.Add(-wait),
+	})
+	if errors.Is(err, db.ErrAlreadyReminded) {
+		retryAfter := wait
+		if last, err := s.db.GetLastReminder(groupID, userID); err == nil {
+			retryAfter = last.CreateAt.Add(wait).Sub(now)
+		}
+		return entity.Reminder{}, &ThrottledError{RetryAfter: retryAfter}
+	}
+	if err != nil {
+		return entity.Reminder{}, fmt.Errorf("create reminder: %w", err)
+	}
+	s.events.Publish(event.Event{
+		Type:     event.MemberReminded,
+		GroupID:  groupID,
+		ActorID:  sentBy,
+		MemberID: userID,
+	})
+	return reminder, nil
+}
+
+// Run sends the automatic reminders of groups with a reminder interval until ctx is done.
+func (s *Scheduler) Run(ctx context.Context) error {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for {
+		s.sendDue(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) sendDue(ctx context.Context, now time.Time) {
+	groups, err := s.db.GetGroupsWithReminders()
+	if err != nil {
+		slog.Error("get groups with reminders", "error", err)
+		return
+	}
+	for _, group := range groups {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := s.sendGroup(group, now); err != nil {
+			slog.Error("send reminders", "group", group.ID, "error", err)
+		}
+	}
+}
+
+// sendGroup reminds every debtor of group who was not reminded within its interval.
+func (s *Scheduler) sendGroup(group entity.Group, now time.Time) error {
+	currency, err := s.db.GetCurrency("TWD")
+	if err != nil {
+		return fmt.Errorf("get currency: %w", err)
+	}
+	expenses, err := s.db.GetGroupExpenses(group.ID)
+	if err != nil {
+		return fmt.Errorf("get group expenses: %w", err)
+	}
+	members, err := s.db.GetGroupMembers(group.ID)
+	if err != nil {
+		return fmt.Errorf("get group members: %w", err)
+	}
+	// the interval never undercuts the throttle of manual reminders
+	wait := max(time.Duration(group.ReminderIntervalDays)*24*time.Hour, Throttle)
+	balances := balances(expenses)
+	for _, member := range members {
+		debt := debtOf(balances, member.ID, int32(currency.DecimalDigits))
+		if debt.Amount.Sign() <= 0 {
+			continue
+		}
+		var throttled *ThrottledError
+		if _, err := s.remind(group.ID, member.ID, "", debt, now, wait); err != nil && !errors.As(err, &throttled) {
+			return err
+		}
+	}
+	return nil
+}
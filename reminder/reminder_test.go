@@ -0,0 +1,149 @@
+package reminder
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/waylen888/tab-buddy/db"
+	"github.com/waylen888/tab-buddy/db/entity"
+	"github.com/waylen888/tab-buddy/db/sqlite"
+	"github.com/waylen888/tab-buddy/event"
+)
+
+// newReminderTest returns a group where bob owes alice 50 TWD.
+func newReminderTest(t *testing.T) (*Scheduler, db.Database, entity.Group, entity.User, entity.User) {
+	t.Helper()
+	database, err := sqlite.New(context.Background(), filepath.Join(t.TempDir(), "test.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice, err := database.CreateUser("alice", "Alice", "alice@example.com", "password", entity.UserCreateTypeDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := database.CreateUser("bob", "Bob", "bob@example.com", "password", entity.UserCreateTypeDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	group, err := database.CreateGroup("trip", alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.AddUserToGroupByUsername(group.ID, lo.ToPtr("bob"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.CreateExpense(entity.CreateExpenseArguments{
+		GroupID:      group.ID,
+		Amount:       "100",
+		TWDRate:      "1",
+		Description:  "dinner",
+		Date:         time.Now(),
+		CurrencyCode: "TWD",
+		SplitUsers: []entity.SplitUser{
+			{User: entity.User{ID: alice.ID}, Paid: true, Owed: true},
+			{User: entity.User{ID: bob.ID}, Owed: true},
+		},
+		CreateByUserID: alice.ID,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return NewScheduler(database, event.NewBus()), database, group, alice, bob
+}
+
+func TestRemindThrottle(t *testing.T) {
+	scheduler, database, group, alice, bob := newReminderTest(t)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	debt, err := GetDebt(database, group.ID, bob.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reminder, err := scheduler.remind(group.ID, bob.ID, alice.ID, debt, now, Throttle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reminder.Amount != "50.00" || reminder.SentBy != alice.ID || !reminder.CreateAt.Equal(now) {
+		t.Errorf("reminder = %+v", reminder)
+	}
+
+	_, err = scheduler.remind(group.ID, bob.ID, alice.ID, debt, now.Add(time.Hour), Throttle)
+	var throttled *ThrottledError
+	if !errors.As(err, &throttled) {
+		t.Fatalf("remind within the throttle = %v, want ThrottledError", err)
+	}
+	if throttled.RetryAfter != Throttle-time.Hour {
+		t.Errorf("RetryAfter = %s, want %s", throttled.RetryAfter, Throttle-time.Hour)
+	}
+
+	if _, err := scheduler.remind(group.ID, bob.ID, alice.ID, debt, now.Add(Throttle), Throttle); err != nil {
+		t.Errorf("remind after the throttle: %v", err)
+	}
+
+	debt, err = GetDebt(database, group.ID, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := scheduler.remind(group.ID, alice.ID, bob.ID, debt, now, Throttle); !errors.Is(err, ErrNothingOwed) {
+		t.Errorf("remind creditor = %v, want ErrNothingOwed", err)
+	}
+}
+
+func TestSendGroupInterval(t *testing.T) {
+	scheduler, database, group, alice, bob := newReminderTest(t)
+	group.ReminderIntervalDays = 3
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	lastReminder := func(userID string) time.Time {
+		t.Helper()
+		last, err := database.GetLastReminder(group.ID, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return last.CreateAt
+	}
+
+	if err := scheduler.sendGroup(group, now); err != nil {
+		t.Fatal(err)
+	}
+	if got := lastReminder(bob.ID); !got.Equal(now) {
+		t.Errorf("bob reminded at %s, want %s", got, now)
+	}
+	if _, err := database.GetLastReminder(group.ID, alice.ID); err == nil {
+		t.Error("creditor reminded")
+	}
+
+	if err := scheduler.sendGroup(group, now.Add(2*24*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if got := lastReminder(bob.ID); !got.Equal(now) {
+		t.Errorf("bob reminded again at %s within the interval", got)
+	}
+
+	if err := scheduler.sendGroup(group, now.Add(3*24*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := lastReminder(bob.ID), now.Add(3*24*time.Hour); !got.Equal(want) {
+		t.Errorf("bob reminded at %s, want %s", got, want)
+	}
+
+	// a manual reminder also holds the automatic one back for the throttle
+	group.ReminderIntervalDays = 0
+	debt, err := GetDebt(database, group.ID, bob.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manual := now.Add(4 * 24 * time.Hour)
+	if _, err := scheduler.remind(group.ID, bob.ID, alice.ID, debt, manual, Throttle); err != nil {
+		t.Fatal(err)
+	}
+	if err := scheduler.sendGroup(group, manual.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if got := lastReminder(bob.ID); !got.Equal(manual) {
+		t.Errorf("bob reminded at %s within the throttle", got)
+	}
+}
@@ -0,0 +1,200 @@
+package report
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/samber/lo"
+	"github.com/waylen888/tab-buddy/config"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+var htmlTemplate = template.Must(template.ParseFS(templateFS, "templates/report.html"))
+
+const (
+	fontFamily = "report"
+	// page margin, width of amount columns and height of table rows, in millimeters on A4
+	margin      = 15.0
+	amountWidth = 30.0
+	rowHeight   = 7.0
+)
+
+// Renderer writes reports as HTML or PDF.
+type Renderer struct {
+	font []byte
+}
+
+// NewRenderer loads the font of PDF reports, the Go font unless cfg names another.
+func NewRenderer(cfg config.ReportSetting) (*Renderer, error) {
+	font := goregular.TTF
+	if cfg.FontFile != "" {
+		var err error
+		if font, err = os.ReadFile(cfg.FontFile); err != nil {
+			return nil, fmt.Errorf("read report font: %w", err)
+		}
+	}
+	return &Renderer{font: font}, nil
+}
+
+// HTML writes report as a printable HTML page.
+func (r *Renderer) HTML(w io.Writer, report Report) error {
+	return htmlTemplate.Execute(w, report)
+}
+
+// PDF writes report as an A4 PDF with the same sections as the HTML page.
+func (r *Renderer) PDF(w io.Writer, report Report) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(margin, margin, margin)
+	pdf.SetAutoPageBreak(true, margin)
+	pdf.SetTitle(report.GroupName+" settlement report", true)
+	pdf.SetCreator("Tab Buddy", true)
+	pdf.SetCreationDate(report.GeneratedAt)
+	pdf.AddUTF8FontFromBytes(fontFamily, "", r.font)
+	pdf.AddPage()
+	width, _ := pdf.GetPageSize()
+	width -= 2 * margin
+
+	pdf.SetFont(fontFamily, "", 20)
+	pdf.MultiCell(width, 10, report.GroupName, "", "L", false)
+	pdf.SetFont(fontFamily, "", 10)
+	pdf.SetTextColor(117, 117, 117)
+	pdf.CellFormat(width, 6, "Settlement report, generated "+report.GeneratedAt.Format("2006-01-02 15:04 MST"), "", 1, "L", false, 0, "")
+	period := "No expenses"
+	if report.ExpenseCount > 0 {
+		period = fmt.Sprintf("%d expenses from %s to %s", report.ExpenseCount, report.From.Format("2006-01-02"), report.To.Format("2006-01-02"))
+	}
+	pdf.CellFormat(width, 6, period, "", 1, "L", false, 0, "")
+	pdf.SetTextColor(33, 33, 33)
+	pdf.Ln(4)
+	pdf.SetFont(fontFamily, "", 14)
+	pdf.CellFormat(width, 8, fmt.Sprintf("Total spent %s %s", report.Total, report.Currency), "", 1, "L", false, 0, "")
+
+	if len(report.Currencies) > 0 {
+		rows := make([][]string, 0, len(report.Currencies))
+		for _, currency := range report.Currencies {
+			rows = append(rows, []string{currency.Code, currency.Amount, currency.Converted})
+		}
+		section(pdf, "By currency")
+		table(pdf, width, []column{textColumn("Currency"), amountColumn("Amount"), amountColumn(report.Currency)}, rows)
+	}
+	if len(report.Categories) > 0 {
+		rows := make([][]string, 0, len(report.Categories))
+		for _, category := range report.Categories {
+			rows = append(rows, []string{category.Name, category.Amount, category.Percent})
+		}
+		section(pdf, "By category")
+		table(pdf, width, []column{textColumn("Category"), amountColumn(report.Currency), amountColumn("%")}, rows)
+	}
+
+	rows := make([][]string, 0, len(report.Members))
+	for _, member := range report.Members {
+		balance := "settled"
+		if member.Owed {
+			balance = "gets back " + member.Balance
+		} else if member.Owes {
+			balance = "owes " + member.Balance
+		}
+		rows = append(rows, []string{member.Name, member.Paid, member.Spent, member.Percent, balance})
+	}
+	section(pdf, "By person")
+	// the balance says who owes, it takes some room from the name
+	balance := amountColumn("Balance")
+	balance.width += amountWidth / 2
+	table(pdf, width, []column{textColumn("Member"), amountColumn("Paid"), amountColumn("Spent"), amountColumn("%"), balance}, rows)
+
+	section(pdf, "Transfers to settle up")
+	if len(report.Transfers) > 0 {
+		rows := make([][]string, 0, len(report.Transfers))
+		for _, transfer := range report.Transfers {
+			rows = append(rows, []string{transfer.From, transfer.To, transfer.Amount})
+		}
+		table(pdf, width, []column{textColumn("From"), textColumn("To"), amountColumn(report.Currency)}, rows)
+	} else {
+		pdf.SetFont(fontFamily, "", 10)
+		pdf.CellFormat(width, rowHeight, "Everyone is settled up.", "", 1, "L", false, 0, "")
+	}
+
+	if err := pdf.Error(); err != nil {
+		return err
+	}
+	return pdf.Output(w)
+}
+
+func section(pdf *fpdf.Fpdf, title string) {
+	pdf.Ln(6)
+	pdf.SetFont(fontFamily, "", 13)
+	pdf.CellFormat(0, 9, title, "", 1, "L", false, 0, "")
+}
+
+// column of a PDF table, text columns without a width share what the others leave.
+type column struct {
+	label  string
+	width  float64
+	amount bool
+}
+
+func textColumn(label string) column {
+	return column{label: label}
+}
+
+func amountColumn(label string) column {
+	return column{label: label, width: amountWidth, amount: true}
+}
+
+// table draws rows under a shaded header, starting a new page when a row does not fit.
+func table(pdf *fpdf.Fpdf, width float64, columns []column, rows [][]string) {
+	widths := make([]float64, len(columns))
+	rest, shared := width, 0
+	for _, column := range columns {
+		rest -= column.width
+		if column.width == 0 {
+			shared++
+		}
+	}
+	for i, column := range columns {
+		widths[i] = column.width
+		if column.width == 0 {
+			widths[i] = rest / float64(shared)
+		}
+	}
+	row := func(cells []string, fill bool) {
+		_, pageHeight := pdf.GetPageSize()
+		if pdf.GetY()+rowHeight > pageHeight-margin {
+			pdf.AddPage()
+		}
+		for i, cell := range cells {
+			align := "L"
+			if columns[i].amount {
+				align = "R"
+			}
+			pdf.CellFormat(widths[i], rowHeight, fit(pdf, cell, widths[i]-2), "B", 0, align, fill, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+	pdf.SetFont(fontFamily, "", 10)
+	pdf.SetDrawColor(224, 224, 224)
+	pdf.SetFillColor(245, 245, 245)
+	row(lo.Map(columns, func(column column, _ int) string { return column.label }), true)
+	for _, cells := range rows {
+		row(cells, false)
+	}
+}
+
+// fit shortens text with an ellipsis until it fits in width.
+func fit(pdf *fpdf.Fpdf, text string, width float64) string {
+	if pdf.GetStringWidth(text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && pdf.GetStringWidth(string(runes)+"…") > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
@@ -0,0 +1,227 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/shopspring/decimal"
+	"github.com/waylen888/tab-buddy/calc"
+	"github.com/waylen888/tab-buddy/db"
+	"github.com/waylen888/tab-buddy/db/entity"
+)
+
+// Currency is the currency of the group totals, expenses in other currencies are converted at their rate.
+const Currency = "TWD"
+
+// Report is the settlement summary of a group, amounts are formatted in the decimal digits of their currency.
+type Report struct {
+	GroupName   string
+	Currency    string
+	GeneratedAt time.Time
+	// From and To are the dates of the first and last expense, zero without expenses.
+	From         time.Time
+	To           time.Time
+	ExpenseCount int
+	Total        string
+	Currencies   []CurrencyTotal
+	Categories   []Share
+	Members      []Member
+	Transfers    []Transfer
+}
+
+// CurrencyTotal is what was spent in one currency and its converted amount.
+type CurrencyTotal struct {
+	Code      string
+	Amount    string
+	Converted string
+}
+
+// Share is the part of the total spent on a category.
+type Share struct {
+	Name    string
+	Amount  string
+	Percent string
+}
+
+// Member is what a member paid, what their share of the expenses is and the difference.
+type Member struct {
+	Name    string
+	Paid    string
+	Spent   string
+	Percent string
+	Balance string
+	Owed    bool
+	Owes    bool
+}
+
+// Transfer is a payment suggested to settle the group.
+type Transfer struct {
+	From   string
+	To     string
+	Amount string
+}
+
+// Build sums the expenses of group into its report.
+func Build(db db.Database, group entity.Group, now time.Time) (Report, error) {
+	currency, err := db.GetCurrency(Currency)
+	if err != nil {
+		return Report{}, fmt.Errorf("get currency: %w", err)
+	}
+	places := int32(currency.DecimalDigits)
+	expenses, err := db.GetGroupExpenses(group.ID)
+	if err != nil {
+		return Report{}, fmt.Errorf("get group expenses: %w", err)
+	}
+	members, err := db.GetGroupMembers(group.ID)
+	if err != nil {
+		return Report{}, fmt.Errorf("get group members: %w", err)
+	}
+
+	// members first in group order, then those who left but still appear in expenses
+	ids := lo.Map(members, func(member entity.User, _ int) string { return member.ID })
+	names := lo.SliceToMap(members, func(member entity.User) (string, string) {
+		return member.ID, member.DisplayName
+	})
+	var total decimal.Decimal
+	byCurrency := make(map[string]decimal.Decimal)
+	convertedByCurrency := make(map[string]decimal.Decimal)
+	byCategory := make(map[string]decimal.Decimal)
+	paid := make(map[string]decimal.Decimal)
+	spent := make(map[string]decimal.Decimal)
+	report := Report{
+		GroupName:    group.Name,
+		Currency:     Currency,
+		GeneratedAt:  now,
+		ExpenseCount: len(expenses),
+	}
+	for _, expense := range expenses {
+		if report.From.IsZero() || expense.Date.Before(report.From) {
+			report.From = expense.Date
+		}
+		if expense.Date.After(report.To) {
+			report.To = expense.Date
+		}
+		amount, _ := decimal.NewFromString(expense.Amount)
+		converted, _ := decimal.NewFromString(expense.TWDExpense().Amount)
+		total = total.Add(converted)
+		byCurrency[expense.CurrencyCode] = byCurrency[expense.CurrencyCode].Add(amount)
+		convertedByCurrency[expense.CurrencyCode] = convertedByCurrency[expense.CurrencyCode].Add(converted)
+		byCategory[expense.Category] = byCategory[expense.Category].Add(converted)
+
+		owedCount := lo.CountBy(expense.SplitUsers, func(su entity.SplitUser) bool { return su.Owed })
+		for _, su := range expense.SplitUsers {
+			if _, ok := names[su.ID]; !ok {
+				ids = append(ids, su.ID)
+				names[su.ID] = su.DisplayName
+			}
+			if su.Paid {
+				paid[su.ID] = paid[su.ID].Add(converted)
+			}
+			if su.Owed {
+				spent[su.ID] = spent[su.ID].Add(converted.Div(decimal.NewFromInt(int64(owedCount))))
+			}
+		}
+	}
+	report.Total = total.StringFixed(places)
+
+	codes := lo.Keys(byCurrency)
+	sort.Strings(codes)
+	for _, code := range codes {
+		currency, err := db.GetCurrency(code)
+		if err != nil {
+			return Report{}, fmt.Errorf("get currency %s: %w", code, err)
+		}
+		report.Currencies = append(report.Currencies, CurrencyTotal{
+			Code:      code,
+			Amount:    byCurrency[code].StringFixed(int32(currency.DecimalDigits)),
+			Converted: convertedByCurrency[code].StringFixed(places),
+		})
+	}
+
+	categories := lo.Keys(byCategory)
+	sort.Slice(categories, func(i, j int) bool {
+		if !byCategory[categories[i]].Equal(byCategory[categories[j]]) {
+			return byCategory[categories[i]].GreaterThan(byCategory[categories[j]])
+		}
+		return categories[i] < categories[j]
+	})
+	for _, category := range categories {
+		report.Categories = append(report.Categories, Share{
+			Name:    CategoryName(category),
+			Amount:  byCategory[category].StringFixed(places),
+			Percent: percent(byCategory[category], total),
+		})
+	}
+
+	balances := calc.Balances(lo.Map(expenses, func(expense entity.ExpenseWithSplitUser, _ int) calc.Expense {
+		return expense.TWDExpense()
+	}))
+	for _, id := range ids {
+		balance := balances[id].Round(places)
+		report.Members = append(report.Members, Member{
+			Name:    names[id],
+			Paid:    paid[id].StringFixed(places),
+			Spent:   spent[id].StringFixed(places),
+			Percent: percent(spent[id], total),
+			Balance: balance.Abs().StringFixed(places),
+			Owed:    balance.Sign() > 0,
+			Owes:    balance.Sign() < 0,
+		})
+	}
+	for _, transfer := range calc.SettleUp(balances, places) {
+		report.Transfers = append(report.Transfers, Transfer{
+			From:   names[transfer.From],
+			To:     names[transfer.To],
+			Amount: transfer.Amount.StringFixed(places),
+		})
+	}
+	return report, nil
+}
+
+func percent(part decimal.Decimal, total decimal.Decimal) string {
+	if total.IsZero() {
+		return "0.0"
+	}
+	return part.Mul(decimal.NewFromInt(100)).Div(total).StringFixed(1)
+}
+
+// categoryNames are the names the app shows for category keys, see app/src/components/CategoryIcon.tsx.
+var categoryNames = map[string]string{
+	"":                     "General",
+	"dining_out":           "Dining out",
+	"groceries":            "Groceries",
+	"liquor":               "Liquor",
+	"food_drink_other":     "Food and drink, other",
+	"bicycle":              "Bicycle",
+	"bus_train":            "Bus/Train",
+	"car":                  "Car",
+	"gas_fuel":             "Gas/fuel",
+	"hotel":                "Hotel",
+	"parking":              "Parking",
+	"plane":                "Plane",
+	"taxi":                 "Taxi",
+	"transportation_other": "Transportation, other",
+	"games":                "Games",
+	"movies":               "Movies",
+	"music":                "Music",
+	"sports":               "Sports",
+	"entertainment_other":  "Entertainment, other",
+	"childcare":            "Childcare",
+	"clothing":             "Clothing",
+	"education":            "Education",
+	"gifts":                "Gifts",
+	"insurance":            "Insurance",
+	"medical_expenses":     "Medical expenses",
+	"taxes":                "Taxes",
+	"life_other":           "Life, other",
+}
+
+// CategoryName returns the display name of a category key, unknown keys are returned as they are.
+func CategoryName(key string) string {
+	if name, ok := categoryNames[key]; ok {
+		return name
+	}
+	return key
+}
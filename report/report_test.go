@@ -0,0 +1,187 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/waylen888/tab-buddy/config"
+	"github.com/waylen888/tab-buddy/db"
+	"github.com/waylen888/tab-buddy/db/entity"
+	"github.com/waylen888/tab-buddy/db/sqlite"
+)
+
+type reportTest struct {
+	db    db.Database
+	group entity.Group
+	users map[string]entity.User
+}
+
+// newReportTest returns a group of alice, bob and carol without expenses.
+func newReportTest(t *testing.T, name string) *reportTest {
+	t.Helper()
+	database, err := sqlite.New(context.Background(), filepath.Join(t.TempDir(), "test.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	test := &reportTest{db: database, users: map[string]entity.User{}}
+	for _, username := range []string{"alice", "bob", "carol"} {
+		user, err := database.CreateUser(username, strings.ToUpper(username[:1])+username[1:], username+"@example.com", "password", entity.UserCreateTypeDefault)
+		if err != nil {
+			t.Fatal(err)
+		}
+		test.users[username] = user
+	}
+	test.group, err = database.CreateGroup(name, test.users["alice"].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, username := range []string{"bob", "carol"} {
+		if _, err := database.AddUserToGroupByUsername(test.group.ID, lo.ToPtr(username), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return test
+}
+
+func (test *reportTest) addExpense(t *testing.T, amount string, currency string, rate string, category string, date time.Time, paidBy string, owedBy ...string) {
+	t.Helper()
+	splits := make(map[string]*entity.SplitUser)
+	for _, username := range append([]string{paidBy}, owedBy...) {
+		if splits[username] == nil {
+			splits[username] = &entity.SplitUser{User: entity.User{ID: test.users[username].ID}}
+		}
+	}
+	splits[paidBy].Paid = true
+	for _, username := range owedBy {
+		splits[username].Owed = true
+	}
+	if _, err := test.db.CreateExpense(entity.CreateExpenseArguments{
+		GroupID:        test.group.ID,
+		Amount:         amount,
+		TWDRate:        rate,
+		Description:    category,
+		Date:           date,
+		CurrencyCode:   currency,
+		Category:       category,
+		SplitUsers:     lo.Map(lo.Values(splits), func(su *entity.SplitUser, _ int) entity.SplitUser { return *su }),
+		CreateByUserID: test.users[paidBy].ID,
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	test := newReportTest(t, "trip")
+	first := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	last := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	test.addExpense(t, "300", "TWD", "1", "dining_out", last, "alice", "alice", "bob", "carol")
+	test.addExpense(t, "10", "USD", "30", "hotel", first, "bob", "alice", "bob")
+
+	now := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	report, err := Build(test.db, test.group, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.GroupName != "trip" || report.Currency != "TWD" || !report.GeneratedAt.Equal(now) || report.ExpenseCount != 2 {
+		t.Errorf("report = %+v", report)
+	}
+	if !report.From.Equal(first) || !report.To.Equal(last) {
+		t.Errorf("period = %s to %s, want %s to %s", report.From, report.To, first, last)
+	}
+	if report.Total != "600.00" {
+		t.Errorf("total = %s, want 600.00", report.Total)
+	}
+	wantCurrencies := []CurrencyTotal{
+		{Code: "TWD", Amount: "300.00", Converted: "300.00"},
+		{Code: "USD", Amount: "10.00", Converted: "300.00"},
+	}
+	if !reflect.DeepEqual(report.Currencies, wantCurrencies) {
+		t.Errorf("currencies = %+v, want %+v", report.Currencies, wantCurrencies)
+	}
+	// equal amounts are ordered by key
+	wantCategories := []Share{
+		{Name: "Dining out", Amount: "300.00", Percent: "50.0"},
+		{Name: "Hotel", Amount: "300.00", Percent: "50.0"},
+	}
+	if !reflect.DeepEqual(report.Categories, wantCategories) {
+		t.Errorf("categories = %+v, want %+v", report.Categories, wantCategories)
+	}
+
+	wantMembers := map[string]Member{
+		"Alice": {Name: "Alice", Paid: "300.00", Spent: "250.00", Percent: "41.7", Balance: "50.00", Owed: true},
+		"Bob":   {Name: "Bob", Paid: "300.00", Spent: "250.00", Percent: "41.7", Balance: "50.00", Owed: true},
+		"Carol": {Name: "Carol", Paid: "0.00", Spent: "100.00", Percent: "16.7", Balance: "100.00", Owes: true},
+	}
+	if len(report.Members) != len(wantMembers) {
+		t.Fatalf("members = %+v", report.Members)
+	}
+	for _, member := range report.Members {
+		if member != wantMembers[member.Name] {
+			t.Errorf("member = %+v, want %+v", member, wantMembers[member.Name])
+		}
+	}
+	transfers := lo.SliceToMap(report.Transfers, func(transfer Transfer) (string, Transfer) { return transfer.To, transfer })
+	if len(report.Transfers) != 2 ||
+		transfers["Alice"] != (Transfer{From: "Carol", To: "Alice", Amount: "50.00"}) ||
+		transfers["Bob"] != (Transfer{From: "Carol", To: "Bob", Amount: "50.00"}) {
+		t.Errorf("transfers = %+v, want carol to pay alice and bob 50.00", report.Transfers)
+	}
+}
+
+func TestBuildWithoutExpenses(t *testing.T) {
+	test := newReportTest(t, "trip")
+	report, err := Build(test.db, test.group, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.ExpenseCount != 0 || report.Total != "0.00" || !report.From.IsZero() || len(report.Transfers) != 0 {
+		t.Errorf("report = %+v", report)
+	}
+	for _, member := range report.Members {
+		if member.Percent != "0.0" || member.Owed || member.Owes {
+			t.Errorf("member = %+v, want settled", member)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	test := newReportTest(t, "<script>trip</script>")
+	test.addExpense(t, "300", "TWD", "1", "dining_out", time.Now(), "alice", "alice", "bob")
+	report, err := Build(test.db, test.group, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	renderer, err := NewRenderer(config.ReportSetting{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var html bytes.Buffer
+	if err := renderer.HTML(&html, report); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(html.String(), "<script>") || !strings.Contains(html.String(), "&lt;script&gt;trip&lt;/script&gt;") {
+		t.Error("group name not escaped in the HTML report")
+	}
+	if !strings.Contains(html.String(), "gets back 150.00") || !strings.Contains(html.String(), "owes 150.00") {
+		t.Error("balances missing from the HTML report")
+	}
+
+	var pdf bytes.Buffer
+	if err := renderer.PDF(&pdf, report); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(pdf.Bytes(), []byte("%PDF-")) {
+		t.Errorf("PDF report starts with %q", pdf.Bytes()[:min(pdf.Len(), 8)])
+	}
+
+	if _, err := NewRenderer(config.ReportSetting{FontFile: filepath.Join(t.TempDir(), "missing.ttf")}); err == nil {
+		t.Error("NewRenderer accepted a missing font file")
+	}
+}
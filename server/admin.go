@@ -0,0 +1,80 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+	"github.com/waylen888/tab-buddy/db/entity"
+	"github.com/waylen888/tab-buddy/server/model"
+)
+
+var outboxMailStatus = map[entity.OutboxMailStatus]string{
+	entity.OutboxMailStatusQueued: "queued",
+	entity.OutboxMailStatusSent:   "sent",
+	entity.OutboxMailStatusDead:   "dead",
+}
+
+// adminCheck allows only the configured admins, it must run after jwtTokenCheck.
+func adminCheck(admins []string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !slices.Contains(admins, GetUser(ctx).Username) {
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// getOutbox lists the mails with the given status, queued by default.
+func (h *APIHandler) getOutbox(ctx *gin.Context) {
+	status, ok := lo.FindKey(outboxMailStatus, ctx.DefaultQuery("status", "queued"))
+	if !ok {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "status must be queued, sent or dead"})
+		return
+	}
+	limit, err := strconv.Atoi(ctx.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+		return
+	}
+
+	mails, err := h.db.GetOutboxMails(status, limit)
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, lo.Map(mails, func(mail entity.OutboxMail, _ int) model.OutboxMail {
+		return model.OutboxMail{
+			ID:            mail.ID,
+			Recipients:    strings.Split(mail.Recipients, ","),
+			Subject:       mail.Subject,
+			Status:        outboxMailStatus[mail.Status],
+			Attempts:      mail.Attempts,
+			LastError:     mail.LastError,
+			NextAttemptAt: mail.NextAttemptAt,
+			SentAt:        mail.SentAt,
+			CreateAt:      mail.CreateAt,
+			UpdateAt:      mail.UpdateAt,
+		}
+	}))
+}
+
+// retryOutboxMail queues a dead mail again.
+func (h *APIHandler) retryOutboxMail(ctx *gin.Context) {
+	if err := h.db.RetryOutboxMail(ctx.Param("id")); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	h.outbox.Wake()
+	ctx.Status(http.StatusOK)
+}
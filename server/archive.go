@@ -0,0 +1,85 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/waylen888/tab-buddy/grouparchive"
+	"github.com/waylen888/tab-buddy/server/model"
+)
+
+const archiveMaxSize = 1 << 30
+
+// getGroupArchive streams the whole group with its attachment files, for restoreGroupArchive on another server.
+func (h *APIHandler) getGroupArchive(ctx *gin.Context) {
+	format := grouparchive.Format(ctx.DefaultQuery("format", string(grouparchive.FormatZip)))
+	if format != grouparchive.FormatZip && format != grouparchive.FormatTar {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "format must be zip or tar"})
+		return
+	}
+	group, err := h.db.GetGroup(ctx.Param("id"), GetUser(ctx).ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.AbortWithStatus(http.StatusNotFound)
+		} else {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	filename := fmt.Sprintf("%s-%s.%s", group.Name, time.Now().Format("20060102"), format.Extension())
+	ctx.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	ctx.Header("Content-Type", format.ContentType())
+	ctx.Status(http.StatusOK)
+	if err := grouparchive.New(h.db, h.blobs).Export(ctx, group, format, ctx.Writer); err != nil {
+		// the response has started, all that can be done is to stop it short
+		ctx.Error(err)
+		ctx.Abort()
+	}
+}
+
+// restoreGroupArchive creates a new group owned by the user from an archive sent as the file form field.
+func (h *APIHandler) restoreGroupArchive(ctx *gin.Context) {
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, archiveMaxSize)
+	fileHeader, err := ctx.FormFile("file")
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	defer file.Close()
+
+	user := GetUser(ctx)
+	restored, err := grouparchive.New(h.db, h.blobs).Restore(ctx, file, fileHeader.Size, user.ID, h.attachments)
+	if err != nil {
+		switch {
+		case errors.Is(err, grouparchive.ErrInvalid):
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, grouparchive.ErrTooLarge):
+			ctx.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		default:
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+		}
+		return
+	}
+	ctx.JSON(http.StatusOK, model.RestoredGroup{
+		Group: model.Group{
+			ID:                   restored.Group.ID,
+			Name:                 restored.Group.Name,
+			ConvertToTwd:         restored.Group.ConvertToTwd.Bool(),
+			ReminderIntervalDays: restored.Group.ReminderIntervalDays,
+			CreateAt:             restored.Group.CreateAt,
+			UpdateAt:             restored.Group.UpdateAt,
+		},
+		Placeholders: restored.Placeholders,
+	})
+}
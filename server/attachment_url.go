@@ -0,0 +1,89 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/waylen888/tab-buddy/db"
+)
+
+// AttachmentURL returns a url of the attachment that can be fetched without a bearer token
+// until it expires, e.g. from an <img> tag.
+func (t *TokenIssuer) AttachmentURL(attachmentID string, thumbnail bool) string {
+	// round the expiry so the url stays the same for a while and browsers can cache the image
+	expires := time.Now().Truncate(t.attachmentTTL).Add(t.attachmentTTL * 2).Unix()
+	query := url.Values{
+		"kid":       {t.activeKeyID},
+		"expires":   {strconv.FormatInt(expires, 10)},
+		"signature": {t.attachmentSignature(t.keys[t.activeKeyID], attachmentID, expires)},
+	}
+	if thumbnail {
+		query.Set("thumbnail", "1")
+	}
+	return "/static/photo/" + url.PathEscape(attachmentID) + "?" + query.Encode()
+}
+
+// VerifyAttachmentURL checks the signature and expiry of a url made by AttachmentURL.
+func (t *TokenIssuer) VerifyAttachmentURL(attachmentID string, query url.Values) error {
+	key, ok := t.keys[query.Get("kid")]
+	if !ok {
+		return fmt.Errorf("unknown key id %q", query.Get("kid"))
+	}
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid expires: %w", err)
+	}
+	want := t.attachmentSignature(key, attachmentID, expires)
+	if !hmac.Equal([]byte(want), []byte(query.Get("signature"))) {
+		return errors.New("invalid signature")
+	}
+	if time.Now().Unix() > expires {
+		return errors.New("url expired")
+	}
+	return nil
+}
+
+func (t *TokenIssuer) attachmentSignature(key []byte, attachmentID string, expires int64) string {
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "attachment\n%s\n%d", attachmentID, expires)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// attachmentAccessCheck lets a request through with either a signed url or the bearer token
+// of a member of the group the attachment belongs to.
+func attachmentAccessCheck(db db.Database, issuer *TokenIssuer) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		attachmentID := ctx.Param("id")
+		if ctx.Query("signature") != "" {
+			if err := issuer.VerifyAttachmentURL(attachmentID, ctx.Request.URL.Query()); err != nil {
+				ctx.AbortWithError(http.StatusForbidden, err)
+				return
+			}
+			ctx.Next()
+			return
+		}
+
+		if !authenticate(ctx, db, issuer) {
+			return
+		}
+		if err := db.AttachmentAccessPermissions(GetUser(ctx).ID, attachmentID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				// same as a missing attachment, ids of other groups are not confirmed
+				ctx.AbortWithStatus(http.StatusNotFound)
+				return
+			}
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		ctx.Next()
+	}
+}
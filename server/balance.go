@@ -0,0 +1,34 @@
+package server
+
+import (
+	"github.com/samber/lo"
+	"github.com/shopspring/decimal"
+	"github.com/waylen888/tab-buddy/calc"
+	"github.com/waylen888/tab-buddy/db/entity"
+)
+
+// balanceByCurrency sums what userID is owed (positive) or owes (negative) in each currency.
+func balanceByCurrency(expenses []entity.ExpenseWithSplitUser, userID string) map[string]decimal.Decimal {
+	balances := make(map[string]decimal.Decimal)
+	for _, expense := range expenses {
+		value := calc.SplitValue(expense.Amount, lo.Map(expense.SplitUsers, func(su entity.SplitUser, _ int) calc.SplitUser {
+			return calc.SplitUser{
+				ID:   su.ID,
+				Paid: su.Paid,
+				Owed: su.Owed,
+			}
+		}), userID)
+		balances[expense.CurrencyCode] = balances[expense.CurrencyCode].Add(value)
+	}
+	return balances
+}
+
+// hasOutstandingBalance reports whether userID still owes or is owed anything.
+func hasOutstandingBalance(expenses []entity.ExpenseWithSplitUser, userID string) bool {
+	for _, balance := range balanceByCurrency(expenses, userID) {
+		if !balance.Round(2).IsZero() {
+			return true
+		}
+	}
+	return false
+}
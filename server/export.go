@@ -0,0 +1,224 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+	"github.com/shopspring/decimal"
+	"github.com/waylen888/tab-buddy/calc"
+	"github.com/waylen888/tab-buddy/db/entity"
+	"github.com/waylen888/tab-buddy/server/model"
+)
+
+var exportCSVHeader = []string{
+	"expense_id", "date", "description", "category", "note", "currency", "amount", "twd_rate", "twd_amount",
+	"created_by", "user_id", "display_name", "email", "paid", "owed", "share", "twd_share", "balance", "twd_balance",
+}
+
+// exportGroup streams the expenses of a group oldest first, as CSV with one row per
+// member of each expense, or as JSON including comments and attachment references.
+func (h *APIHandler) exportGroup(ctx *gin.Context) {
+	format := ctx.DefaultQuery("format", "csv")
+	if format != "csv" && format != "json" {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "format must be csv or json"})
+		return
+	}
+	group, err := h.db.GetGroup(ctx.Param("id"), GetUser(ctx).ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.AbortWithStatus(http.StatusNotFound)
+		} else {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+		}
+		return
+	}
+	expenses, err := h.db.GetGroupExpenses(group.ID)
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	sort.SliceStable(expenses, func(i, j int) bool {
+		return expenses[i].Date.Before(expenses[j].Date)
+	})
+
+	filename := fmt.Sprintf("%s-%s.%s", group.Name, time.Now().Format("20060102"), format)
+	ctx.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	if format == "csv" {
+		err = h.writeExportCSV(ctx, expenses)
+	} else {
+		err = h.writeExportJSON(ctx, group, expenses)
+	}
+	if err != nil {
+		// the response has started, all that can be done is to stop it short
+		ctx.Error(err)
+		ctx.Abort()
+	}
+}
+
+func (h *APIHandler) writeExportCSV(ctx *gin.Context, expenses []entity.ExpenseWithSplitUser) error {
+	ctx.Header("Content-Type", "text/csv; charset=utf-8")
+	ctx.Status(http.StatusOK)
+	// a byte order mark makes spreadsheets read the file as UTF-8
+	if _, err := ctx.Writer.WriteString("\ufeff"); err != nil {
+		return err
+	}
+	w := csv.NewWriter(ctx.Writer)
+	if err := w.Write(exportCSVHeader); err != nil {
+		return err
+	}
+	for _, expense := range expenses {
+		exported, err := h.toExportExpense(expense)
+		if err != nil {
+			return err
+		}
+		for _, split := range exported.Splits {
+			err := w.Write([]string{
+				exported.ID, exported.Date.Format(time.DateOnly), exported.Description, exported.Category, exported.Note,
+				exported.Currency, exported.Amount, exported.TWDRate, exported.TWDAmount, exported.CreatedBy,
+				split.UserID, split.DisplayName, split.Email, strconv.FormatBool(split.Paid), strconv.FormatBool(split.Owed),
+				split.Share, split.TWDShare, split.Balance, split.TWDBalance,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return err
+		}
+		ctx.Writer.Flush()
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func (h *APIHandler) writeExportJSON(ctx *gin.Context, group entity.Group, expenses []entity.ExpenseWithSplitUser) error {
+	ctx.Header("Content-Type", "application/json; charset=utf-8")
+	ctx.Status(http.StatusOK)
+	header, err := json.Marshal(model.ExportGroup{ID: group.ID, Name: group.Name})
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(ctx.Writer, `{"group":%s,"expenses":[`, header); err != nil {
+		return err
+	}
+	for i, expense := range expenses {
+		exported, err := h.toExportExpense(expense)
+		if err != nil {
+			return err
+		}
+		if err := h.addExportDiscussion(&exported); err != nil {
+			return err
+		}
+		body, err := json.Marshal(exported)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			body = append([]byte(","), body...)
+		}
+		if _, err := ctx.Writer.Write(body); err != nil {
+			return err
+		}
+		ctx.Writer.Flush()
+	}
+	_, err = ctx.Writer.WriteString("]}")
+	return err
+}
+
+func (h *APIHandler) toExportExpense(expense entity.ExpenseWithSplitUser) (model.ExportExpense, error) {
+	currency, err := h.db.GetCurrency(expense.CurrencyCode)
+	if err != nil {
+		return model.ExportExpense{}, fmt.Errorf("get currency %s: %w", expense.CurrencyCode, err)
+	}
+	twd, err := h.db.GetCurrency("TWD")
+	if err != nil {
+		return model.ExportExpense{}, fmt.Errorf("get currency TWD: %w", err)
+	}
+	places, twdPlaces := int32(currency.DecimalDigits), int32(twd.DecimalDigits)
+	amount, _ := decimal.NewFromString(expense.Amount)
+	rate, _ := decimal.NewFromString(expense.TWDRate)
+	splitUsers := lo.Map(expense.SplitUsers, func(su entity.SplitUser, _ int) calc.SplitUser {
+		return calc.SplitUser{ID: su.ID, Paid: su.Paid, Owed: su.Owed}
+	})
+	owedCount := lo.CountBy(expense.SplitUsers, func(su entity.SplitUser) bool { return su.Owed })
+
+	return model.ExportExpense{
+		ID:          expense.ID,
+		Date:        expense.Date,
+		Description: expense.Description,
+		Category:    expense.Category,
+		Note:        expense.Note,
+		Currency:    expense.CurrencyCode,
+		Amount:      amount.StringFixed(places),
+		TWDRate:     expense.TWDRate,
+		TWDAmount:   amount.Mul(rate).StringFixed(twdPlaces),
+		CreatedBy:   expense.CreatedBy,
+		Splits: lo.Map(expense.SplitUsers, func(su entity.SplitUser, _ int) model.ExportSplit {
+			share := decimal.Zero
+			if su.Owed {
+				share = amount.Div(decimal.NewFromInt(int64(owedCount)))
+			}
+			balance := calc.SplitValue(expense.Amount, splitUsers, su.ID)
+			return model.ExportSplit{
+				UserID:      su.ID,
+				DisplayName: su.DisplayName,
+				Email:       su.Email,
+				Paid:        su.Paid,
+				Owed:        su.Owed,
+				Share:       share.StringFixed(places),
+				TWDShare:    share.Mul(rate).StringFixed(twdPlaces),
+				Balance:     balance.StringFixed(places),
+				TWDBalance:  balance.Mul(rate).StringFixed(twdPlaces),
+			}
+		}),
+		CreateAt: expense.CreateAt,
+		UpdateAt: expense.UpdateAt,
+	}, nil
+}
+
+// addExportDiscussion adds the attachments and the comments of the expense.
+func (h *APIHandler) addExportDiscussion(exported *model.ExportExpense) error {
+	attachments, err := h.db.GetExpenseAttachments(exported.ID)
+	if err != nil {
+		return fmt.Errorf("get attachments: %w", err)
+	}
+	exported.Attachments = lo.Map(attachments, func(attachment entity.ExpenseAttachment, _ int) model.ExpenseAttachment {
+		return h.toAttachmentModel(attachment)
+	})
+	commentAttachments, err := h.db.GetCommentAttachments(exported.ID)
+	if err != nil {
+		return fmt.Errorf("get comment attachments: %w", err)
+	}
+	comments, err := h.db.GetExpenseComments(exported.ID)
+	if err != nil {
+		return fmt.Errorf("get comments: %w", err)
+	}
+	attachmentsByComment := lo.GroupBy(commentAttachments, func(attachment entity.ExpenseAttachment) string {
+		return *attachment.CommentID
+	})
+	exported.Comments = lo.Map(comments, func(comment entity.Comment, _ int) model.Comment {
+		return model.Comment{
+			ID:          comment.ID,
+			Content:     comment.Content,
+			CreateBy:    comment.CreateBy,
+			DisplayName: comment.DisplayName,
+			Attachments: lo.Map(attachmentsByComment[comment.ID], func(attachment entity.ExpenseAttachment, _ int) model.ExpenseAttachment {
+				return h.toAttachmentModel(attachment)
+			}),
+			CreateAt: comment.CreateAt,
+			UpdateAt: comment.UpdateAt,
+		}
+	})
+	return nil
+}
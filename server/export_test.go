@@ -0,0 +1,211 @@
+package server
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/xid"
+	"github.com/samber/lo"
+	"github.com/waylen888/tab-buddy/config"
+	"github.com/waylen888/tab-buddy/db"
+	"github.com/waylen888/tab-buddy/db/entity"
+	"github.com/waylen888/tab-buddy/db/sqlite"
+	"github.com/waylen888/tab-buddy/server/model"
+)
+
+// newExportTest serves the export of a group of alice and bob, who share a dinner in TWD and a
+// hotel in USD the day before, carol is not a member. Requests with an X-Username header are made as that user.
+func newExportTest(t *testing.T) (*gin.Engine, db.Database, entity.Group) {
+	gin.SetMode(gin.TestMode)
+	database, err := sqlite.New(context.Background(), filepath.Join(t.TempDir(), "test.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	users := make(map[string]entity.User)
+	for _, username := range []string{"alice", "bob", "carol"} {
+		users[username], err = database.CreateUser(username, strings.ToUpper(username[:1])+username[1:], username+"@example.com", "password", entity.UserCreateTypeDefault)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	group, err := database.CreateGroup("trip", users["alice"].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.AddUserToGroupByUsername(group.ID, lo.ToPtr("bob"), nil); err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)
+	dinner, err := database.CreateExpense(entity.CreateExpenseArguments{
+		GroupID:      group.ID,
+		Amount:       "300",
+		TWDRate:      "1",
+		Description:  `dinner, "late"`,
+		Date:         day,
+		CurrencyCode: "TWD",
+		Category:     "dining_out",
+		Note:         "first line\nsecond line",
+		SplitUsers: []entity.SplitUser{
+			{User: entity.User{ID: users["bob"].ID}, Paid: true, Owed: true},
+			{User: entity.User{ID: users["alice"].ID}, Owed: true},
+		},
+		CreateByUserID: users["bob"].ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.CreateExpense(entity.CreateExpenseArguments{
+		GroupID:      group.ID,
+		Amount:       "10",
+		TWDRate:      "30",
+		Description:  "hotel",
+		Date:         day.AddDate(0, 0, -1),
+		CurrencyCode: "USD",
+		Category:     "hotel",
+		SplitUsers: []entity.SplitUser{
+			{User: entity.User{ID: users["alice"].ID}, Paid: true, Owed: true},
+			{User: entity.User{ID: users["bob"].ID}, Owed: true},
+		},
+		CreateByUserID: users["alice"].ID,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	comment, err := database.CreateComment(entity.CreateCommentArguments{ExpenseID: dinner.ID, Content: "thanks", CreateBy: users["alice"].ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if err := database.CreateExpenseAttachments(entity.CreateExpenseAttachmentsArgument{
+		ExpenseID: dinner.ID,
+		CommentID: &comment.ID,
+		Attachments: []entity.ExpenseAttachment{
+			{ID: xid.New().String(), Filename: "receipt.png", Size: 7, MIME: "image/png", CreateAt: now, UpdateAt: now},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	key := config.TokenKey{ID: "key", Secret: "secret"}
+	handler, err := NewAPIHandler(database, nil, nil, config.AttachmentSetting{}, nil, nil, nil, nil, nil, newTestTokenIssuer(t, key.ID, key), testPublicURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := gin.New()
+	engine.GET("/api/group/:id/export", func(ctx *gin.Context) {
+		user, err := database.GetUserByUsername(ctx.GetHeader("X-Username"))
+		if err != nil {
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		ctx.Set("user", user)
+	}, handler.exportGroup)
+	return engine, database, group
+}
+
+func get(engine *gin.Engine, path string, username string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("X-Username", username)
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func TestExportGroupCSV(t *testing.T) {
+	engine, _, group := newExportTest(t)
+	w := get(engine, "/api/group/"+group.ID+"/export", "alice")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "text/csv; charset=utf-8" {
+		t.Errorf("Content-Type = %s", contentType)
+	}
+	if disposition := w.Header().Get("Content-Disposition"); !strings.HasPrefix(disposition, `attachment; filename=trip-`) || !strings.HasSuffix(disposition, `.csv`) {
+		t.Errorf("Content-Disposition = %s", disposition)
+	}
+	body, ok := strings.CutPrefix(w.Body.String(), "\ufeff")
+	if !ok {
+		t.Error("no byte order mark")
+	}
+	records, err := csv.NewReader(strings.NewReader(body)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 5 || strings.Join(records[0], ",") != strings.Join(exportCSVHeader, ",") {
+		t.Fatalf("records = %q, want the header and a row per member of both expenses", records)
+	}
+	row := func(record []string) map[string]string {
+		return lo.SliceToMap(exportCSVHeader, func(column string) (string, string) {
+			return column, record[lo.IndexOf(exportCSVHeader, column)]
+		})
+	}
+
+	// oldest first
+	for i, want := range []map[string]string{
+		{"date": "2024-03-01", "description": "hotel", "currency": "USD", "amount": "10.00", "twd_amount": "300.00",
+			"display_name": "Alice", "paid": "true", "owed": "true", "share": "5.00", "twd_share": "150.00", "balance": "5.00", "twd_balance": "150.00"},
+		{"date": "2024-03-01", "description": "hotel", "display_name": "Bob", "email": "bob@example.com",
+			"paid": "false", "owed": "true", "share": "5.00", "twd_share": "150.00", "balance": "-5.00", "twd_balance": "-150.00"},
+		{"date": "2024-03-02", "description": `dinner, "late"`, "category": "dining_out", "note": "first line\nsecond line",
+			"currency": "TWD", "amount": "300.00", "twd_rate": "1", "twd_amount": "300.00", "display_name": "Bob", "balance": "150.00"},
+		{"date": "2024-03-02", "display_name": "Alice", "paid": "false", "share": "150.00", "balance": "-150.00"},
+	} {
+		got := row(records[i+1])
+		for column, value := range want {
+			if got[column] != value {
+				t.Errorf("row %d %s = %q, want %q", i+1, column, got[column], value)
+			}
+		}
+	}
+}
+
+func TestExportGroupJSON(t *testing.T) {
+	engine, _, group := newExportTest(t)
+	w := get(engine, "/api/group/"+group.ID+"/export?format=json", "bob")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+	var export struct {
+		Group    model.ExportGroup     `json:"group"`
+		Expenses []model.ExportExpense `json:"expenses"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &export); err != nil {
+		t.Fatalf("body is not JSON: %v\n%s", err, w.Body)
+	}
+	if export.Group.ID != group.ID || export.Group.Name != "trip" {
+		t.Errorf("group = %+v", export.Group)
+	}
+	if len(export.Expenses) != 2 || export.Expenses[0].Description != "hotel" {
+		t.Fatalf("expenses = %+v, want the hotel first", export.Expenses)
+	}
+	hotel, dinner := export.Expenses[0], export.Expenses[1]
+	if hotel.TWDAmount != "300.00" || len(hotel.Splits) != 2 || len(hotel.Comments) != 0 || len(hotel.Attachments) != 0 {
+		t.Errorf("hotel = %+v", hotel)
+	}
+	if len(dinner.Comments) != 1 || dinner.Comments[0].Content != "thanks" || dinner.Comments[0].DisplayName != "Alice" {
+		t.Fatalf("comments = %+v", dinner.Comments)
+	}
+	attachments := dinner.Comments[0].Attachments
+	if len(attachments) != 1 || attachments[0].Filename != "receipt.png" || !strings.HasPrefix(attachments[0].URL, "/static/photo/"+attachments[0].ID+"?") {
+		t.Errorf("comment attachments = %+v", attachments)
+	}
+}
+
+func TestExportGroupRejects(t *testing.T) {
+	engine, _, group := newExportTest(t)
+	if w := get(engine, "/api/group/"+group.ID+"/export?format=xml", "alice"); w.Code != http.StatusBadRequest {
+		t.Errorf("format xml: status = %d, want 400", w.Code)
+	}
+	if w := get(engine, "/api/group/"+group.ID+"/export", "carol"); w.Code != http.StatusNotFound {
+		t.Errorf("not a member: status = %d, want 404", w.Code)
+	}
+}
@@ -1,31 +1,29 @@
 package server
 
 import (
-	"database/sql"
 	"encoding/json"
-	"errors"
 	"log/slog"
 	"net/http"
-	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/waylen888/tab-buddy/config"
 	"github.com/waylen888/tab-buddy/db"
-	"github.com/waylen888/tab-buddy/db/entity"
+	"github.com/waylen888/tab-buddy/oidc"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 )
 
 type GoogleHandler struct {
-	db  db.Database
-	cfg *oauth2.Config
+	db          db.Database
+	cfg         *oauth2.Config
+	tokenIssuer *TokenIssuer
 }
 
-func NewGoogleHandler(db db.Database, oauthCfg config.GoogleOAuth) *GoogleHandler {
+func NewGoogleHandler(db db.Database, oauthCfg config.GoogleOAuth, tokenIssuer *TokenIssuer) *GoogleHandler {
 	return &GoogleHandler{
-		db:  db,
-		cfg: NewGoogleOAuthConfig(oauthCfg),
+		db:          db,
+		cfg:         NewGoogleOAuthConfig(oauthCfg),
+		tokenIssuer: tokenIssuer,
 	}
 }
 
@@ -44,16 +42,26 @@ func NewGoogleOAuthConfig(oauthCfg config.GoogleOAuth) *oauth2.Config {
 }
 
 func (h *GoogleHandler) Login(ctx *gin.Context) {
-	const authCode = "I$#!lk(D_d1)"
-	slog.Info("google login", "url", h.cfg.AuthCodeURL(authCode))
-	ctx.Redirect(http.StatusFound, h.cfg.AuthCodeURL(authCode))
+	state, err := newOAuthState(h.db, ctx, googleProviderName, "")
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	authURL := h.cfg.AuthCodeURL(state.State, oauth2.S256ChallengeOption(state.CodeVerifier))
+	slog.Info("google login", "url", authURL)
+	ctx.Redirect(http.StatusFound, authURL)
 	ctx.Abort()
 }
 
 func (h *GoogleHandler) Callback(ctx *gin.Context) {
+	state, err := takeOAuthState(h.db, ctx, googleProviderName)
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
 	code := ctx.Query("code")
 	slog.Info("google callback", "code", code)
-	token, err := h.cfg.Exchange(ctx.Request.Context(), code)
+	token, err := h.cfg.Exchange(ctx.Request.Context(), code, oauth2.VerifierOption(state.CodeVerifier))
 	if err != nil {
 		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -81,31 +89,12 @@ func (h *GoogleHandler) Callback(ctx *gin.Context) {
 		return
 	}
 
-	// try login
-	user, err := h.db.GetUserByUsername(resp.GetID())
-	if err != nil && errors.Is(err, sql.ErrNoRows) {
-		// create user
-		user, err = h.db.CreateUser(resp.GetID(), resp.GetDisplayName(), resp.GetEmail(), "", entity.UserCreateTypeGoogle)
-		if err != nil {
-			ctx.AbortWithError(http.StatusInternalServerError, err)
-			return
-		}
-	} else if err != nil {
-		ctx.AbortWithError(http.StatusForbidden, err)
-		return
-	}
-
-	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"id":       user.ID,
-		"username": user.Username,
-		"exp":      time.Now().Add(time.Hour * 24 * 30).Unix(),
-	})
-	tokenStr, err := jwtToken.SignedString([]byte(TOKEN_SECRET))
+	user, err := userForIdentity(h.db, googleProviderName, resp.Identity())
 	if err != nil {
-		ctx.AbortWithError(http.StatusInternalServerError, err)
+		ctx.AbortWithError(http.StatusForbidden, err)
 		return
 	}
-	ctx.Redirect(http.StatusFound, "/set-token?access_token="+tokenStr)
+	redirectWithNewSession(ctx, h.db, h.tokenIssuer, user)
 }
 
 type PeopleResponse struct {
@@ -128,6 +117,15 @@ func (r *PeopleResponse) GetDisplayName() string {
 	return ""
 }
 
+// Identity converts the people of the signed in google account to an oidc identity.
+func (r *PeopleResponse) Identity() oidc.Identity {
+	return oidc.Identity{
+		Subject: r.GetID(),
+		Email:   r.GetEmail(),
+		Name:    r.GetDisplayName(),
+	}
+}
+
 func (r *PeopleResponse) GetID() string {
 	if len(r.Metadata.Sources) > 0 {
 		return r.Metadata.Sources[0].ID
@@ -9,51 +9,103 @@ import (
 	"fmt"
 	"log/slog"
 	"mime"
-	"mime/multipart"
 	"net/http"
-	"os"
 	"path"
-	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/h2non/filetype"
 	"github.com/rs/xid"
 	"github.com/samber/lo"
 	"github.com/shopspring/decimal"
 	"github.com/waylen888/tab-buddy/app"
+	"github.com/waylen888/tab-buddy/blobstore"
 	"github.com/waylen888/tab-buddy/calc"
+	"github.com/waylen888/tab-buddy/config"
 	"github.com/waylen888/tab-buddy/db"
 	"github.com/waylen888/tab-buddy/db/entity"
+	"github.com/waylen888/tab-buddy/event"
 	"github.com/waylen888/tab-buddy/finmind"
 	"github.com/waylen888/tab-buddy/mail"
+	"github.com/waylen888/tab-buddy/receipt"
+	"github.com/waylen888/tab-buddy/reminder"
+	"github.com/waylen888/tab-buddy/report"
 	"github.com/waylen888/tab-buddy/server/model"
+	"github.com/waylen888/tab-buddy/thumbnail"
 )
 
 type APIHandler struct {
-	db         db.Database
-	rateGetter finmind.TaiwanExchangeRateGetter
-	dataDir    string
-	mailSender *mail.Sender
+	db          db.Database
+	rateGetter  finmind.TaiwanExchangeRateGetter
+	blobs       blobstore.Store
+	attachments config.AttachmentSetting
+	receipts    *receipt.Processor
+	outbox      *mail.Outbox
+	events      *event.Bus
+	reminders   *reminder.Scheduler
+	reports     *report.Renderer
+	tokenIssuer *TokenIssuer
+	publicURL   string
 }
 
 func NewAPIHandler(
 	db db.Database,
 	rateGetter finmind.TaiwanExchangeRateGetter,
-	dataDir string,
-	mailSender *mail.Sender,
+	blobs blobstore.Store,
+	attachments config.AttachmentSetting,
+	receipts *receipt.Processor,
+	outbox *mail.Outbox,
+	events *event.Bus,
+	reminders *reminder.Scheduler,
+	reports *report.Renderer,
+	tokenIssuer *TokenIssuer,
+	publicURL string,
 ) (*APIHandler, error) {
 	return &APIHandler{
-		db:         db,
-		rateGetter: rateGetter,
-		dataDir:    dataDir,
-		mailSender: mailSender,
+		db:          db,
+		rateGetter:  rateGetter,
+		blobs:       blobs,
+		attachments: attachments,
+		receipts:    receipts,
+		outbox:      outbox,
+		events:      events,
+		reminders:   reminders,
+		reports:     reports,
+		tokenIssuer: tokenIssuer,
+		publicURL:   strings.TrimSuffix(publicURL, "/"),
 	}, nil
 }
 
-const TOKEN_SECRET = "Kia9012)f^#$$"
+// respondWithNewSession starts a new session for user and responds with its tokens.
+func (h *APIHandler) respondWithNewSession(ctx *gin.Context, user entity.User) {
+	session, err := newSession(h.db, ctx, user)
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	h.respondWithTokens(ctx, user, session.ID)
+}
+
+// respondWithTokens issues a new access token and refresh token for the user session.
+func (h *APIHandler) respondWithTokens(ctx *gin.Context, user entity.User, sessionID string) {
+	pair, err := issueTokenPair(h.db, h.tokenIssuer, user, sessionID)
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"token":        pair.AccessToken,
+		"refreshToken": pair.RefreshToken,
+		"user": model.User{
+			ID:          user.ID,
+			Username:    user.Username,
+			DisplayName: user.DisplayName,
+			Email:       user.Email,
+			CreateAt:    user.CreateAt,
+			UpdateAt:    user.UpdateAt,
+		},
+	})
+}
 
 func (h *APIHandler) login(ctx *gin.Context) {
 	// returning token and refresh_token
@@ -75,38 +127,18 @@ func (h *APIHandler) login(ctx *gin.Context) {
 		return
 	}
 
-	go func() {
-		err := h.mailSender.SendMail(
-			[]string{user.Email},
-			"用戶登入通知",
-			fmt.Sprintf("您的帳號%s被登入，IP: %s", user.Username, ctx.ClientIP()),
-		)
+	// without an address the mail could only be retried until it is dead-lettered
+	if user.Email != "" {
+		err = h.sendMail("login_notify", []string{user.Email}, map[string]string{
+			"Username": user.Username,
+			"IP":       ctx.ClientIP(),
+		})
 		if err != nil {
 			slog.Error("send login notify", "error", err)
 		}
-	}()
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"id":       user.ID,
-		"username": user.Username,
-		"exp":      time.Now().Add(time.Hour * 24 * 30).Unix(),
-	})
-	tokenStr, err := token.SignedString([]byte(TOKEN_SECRET))
-	if err != nil {
-		ctx.AbortWithError(http.StatusInternalServerError, err)
-		return
 	}
-	ctx.JSON(http.StatusOK, gin.H{
-		"token": tokenStr,
-		"user": model.User{
-			ID:          user.ID,
-			Username:    user.Username,
-			DisplayName: user.DisplayName,
-			Email:       user.Email,
-			CreateAt:    user.CreateAt,
-			UpdateAt:    user.UpdateAt,
-		},
-	})
+
+	h.respondWithNewSession(ctx, user)
 }
 
 func getGooglePeople(ctx context.Context, accessToken string) (PeopleResponse, error) {
@@ -149,77 +181,95 @@ func (h *APIHandler) loginByGoogleToken(ctx *gin.Context) {
 		return
 	}
 
-	// try login
-	user, err := h.db.GetUserByUsername(people.GetID())
-	if err != nil && errors.Is(err, sql.ErrNoRows) {
-		// create user
-		user, err = h.db.CreateUser(people.GetID(), people.GetDisplayName(), people.GetEmail(), "", entity.UserCreateTypeGoogle)
-		if err != nil {
-			ctx.AbortWithError(http.StatusInternalServerError, err)
-			return
-		}
-	} else if err != nil {
+	user, err := userForIdentity(h.db, googleProviderName, people.Identity())
+	if err != nil {
 		ctx.AbortWithError(http.StatusForbidden, err)
 		return
 	}
 
-	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"id":       user.ID,
-		"username": user.Username,
-		"exp":      time.Now().Add(time.Hour * 24 * 30).Unix(),
-	})
-	tokenStr, err := jwtToken.SignedString([]byte(TOKEN_SECRET))
+	h.respondWithNewSession(ctx, user)
+}
+
+func (h *APIHandler) exchangeRefreshToken(ctx *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refreshToken" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	refreshToken, err := h.db.GetRefreshToken(hashToken(req.RefreshToken))
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
 		ctx.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, gin.H{
-		"token": tokenStr,
-		"user": model.User{
-			ID:          user.ID,
-			Username:    user.Username,
-			DisplayName: user.DisplayName,
-			Email:       user.Email,
-			CreateAt:    user.CreateAt,
-			UpdateAt:    user.UpdateAt,
-		},
-	})
-}
-
-func (h *APIHandler) refreshToken(ctx *gin.Context) {
-	anyObj, ok := ctx.Get("user")
-	if !ok {
+	if refreshToken.RevokeAt != nil {
+		// a rotated token is being replayed, revoke the whole session
+		if err := h.db.RevokeSession(refreshToken.SessionID, refreshToken.UserID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			slog.Error("revoke session", "error", err)
+		}
 		ctx.AbortWithStatus(http.StatusUnauthorized)
 		return
 	}
-	user, ok := anyObj.(entity.User)
-	if !ok {
+	if time.Now().After(refreshToken.ExpireAt) {
 		ctx.AbortWithStatus(http.StatusUnauthorized)
 		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"id":       user.ID,
-		"username": user.Username,
-		"exp":      time.Now().Add(time.Hour * 24 * 30).Unix(),
-	})
-	tokenStr, err := token.SignedString([]byte(TOKEN_SECRET))
+	session, err := h.db.GetSession(refreshToken.SessionID)
+	if err != nil || session.RevokeAt != nil {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	user, err := h.db.GetUser(refreshToken.UserID)
 	if err != nil {
+		ctx.AbortWithError(http.StatusForbidden, err)
+		return
+	}
+	if err := h.db.RevokeRefreshToken(refreshToken.ID); err != nil {
+		if errors.Is(err, db.ErrTokenAlreadyUsed) {
+			// a concurrent request rotated the same token, treat it as a replay
+			if err := h.db.RevokeSession(refreshToken.SessionID, refreshToken.UserID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+				slog.Error("revoke session", "error", err)
+			}
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
 		ctx.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
+	h.respondWithTokens(ctx, user, session.ID)
+}
 
-	ctx.JSON(http.StatusOK, gin.H{
-		"token": tokenStr,
-		"user": model.User{
-			ID:          user.ID,
-			Username:    user.Username,
-			DisplayName: user.DisplayName,
-			Email:       user.Email,
-			CreateAt:    user.CreateAt,
-			UpdateAt:    user.UpdateAt,
-		},
-	})
+func (h *APIHandler) logout(ctx *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refreshToken" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	refreshToken, err := h.db.GetRefreshToken(hashToken(req.RefreshToken))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.Status(http.StatusOK)
+			return
+		}
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	if err := h.db.RevokeSession(refreshToken.SessionID, refreshToken.UserID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	ctx.Status(http.StatusOK)
 }
 
 func (h *APIHandler) createUser(ctx *gin.Context) {
@@ -238,6 +288,12 @@ func (h *APIHandler) createUser(ctx *gin.Context) {
 		ctx.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
+
+	if user.Email != "" {
+		if err := h.sendEmailVerification(user); err != nil {
+			slog.Error("send email verification", "error", err)
+		}
+	}
 	ctx.JSON(http.StatusOK, model.User{
 		ID:          user.ID,
 		Username:    user.Username,
@@ -256,11 +312,12 @@ func (h *APIHandler) getGroups(ctx *gin.Context) {
 	}
 	ctx.JSON(http.StatusOK, lo.Map(groups, func(group entity.Group, _ int) model.Group {
 		return model.Group{
-			ID:           group.ID,
-			Name:         group.Name,
-			ConvertToTwd: group.ConvertToTwd.Bool(),
-			CreateAt:     group.CreateAt,
-			UpdateAt:     group.UpdateAt,
+			ID:                   group.ID,
+			Name:                 group.Name,
+			ConvertToTwd:         group.ConvertToTwd.Bool(),
+			ReminderIntervalDays: group.ReminderIntervalDays,
+			CreateAt:             group.CreateAt,
+			UpdateAt:             group.UpdateAt,
 		}
 	}))
 }
@@ -277,11 +334,12 @@ func (h *APIHandler) getGroup(ctx *gin.Context) {
 		return
 	}
 	ctx.JSON(http.StatusOK, model.Group{
-		ID:           group.ID,
-		Name:         group.Name,
-		ConvertToTwd: group.ConvertToTwd.Bool(),
-		CreateAt:     group.CreateAt,
-		UpdateAt:     group.UpdateAt,
+		ID:                   group.ID,
+		Name:                 group.Name,
+		ConvertToTwd:         group.ConvertToTwd.Bool(),
+		ReminderIntervalDays: group.ReminderIntervalDays,
+		CreateAt:             group.CreateAt,
+		UpdateAt:             group.UpdateAt,
 	})
 }
 
@@ -300,11 +358,12 @@ func (h *APIHandler) createGroup(ctx *gin.Context) {
 		return
 	}
 	ctx.JSON(http.StatusOK, model.Group{
-		ID:           group.ID,
-		Name:         group.Name,
-		ConvertToTwd: group.ConvertToTwd.Bool(),
-		CreateAt:     group.CreateAt,
-		UpdateAt:     group.UpdateAt,
+		ID:                   group.ID,
+		Name:                 group.Name,
+		ConvertToTwd:         group.ConvertToTwd.Bool(),
+		ReminderIntervalDays: group.ReminderIntervalDays,
+		CreateAt:             group.CreateAt,
+		UpdateAt:             group.UpdateAt,
 	})
 }
 
@@ -313,22 +372,25 @@ func (h *APIHandler) updateGroup(ctx *gin.Context) {
 	var req struct {
 		Name         string `json:"name" binding:"required"`
 		ConvertToTwd bool   `json:"convertToTwd"`
+		// ReminderIntervalDays is left unchanged when omitted.
+		ReminderIntervalDays *int `json:"reminderIntervalDays" binding:"omitempty,min=0,max=365"`
 	}
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.AbortWithError(http.StatusBadRequest, err)
 		return
 	}
-	group, err := h.db.UpdateGroup(id, req.Name, req.ConvertToTwd)
+	group, err := h.db.UpdateGroup(id, req.Name, req.ConvertToTwd, req.ReminderIntervalDays)
 	if err != nil {
 		ctx.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, model.Group{
-		ID:           group.ID,
-		Name:         group.Name,
-		ConvertToTwd: group.ConvertToTwd.Bool(),
-		CreateAt:     group.CreateAt,
-		UpdateAt:     group.UpdateAt,
+		ID:                   group.ID,
+		Name:                 group.Name,
+		ConvertToTwd:         group.ConvertToTwd.Bool(),
+		ReminderIntervalDays: group.ReminderIntervalDays,
+		CreateAt:             group.CreateAt,
+		UpdateAt:             group.UpdateAt,
 	})
 }
 
@@ -338,6 +400,11 @@ func (h *APIHandler) deleteGroup(ctx *gin.Context) {
 		ctx.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
+	h.events.Publish(event.Event{
+		Type:    event.GroupDeleted,
+		GroupID: id,
+		ActorID: GetUser(ctx).ID,
+	})
 	ctx.Status(http.StatusOK)
 }
 
@@ -464,6 +531,12 @@ func (h *APIHandler) createExpense(ctx *gin.Context) {
 		ctx.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
+	h.events.Publish(event.Event{
+		Type:      event.ExpenseCreated,
+		GroupID:   ctx.Param("id"),
+		ActorID:   GetUser(ctx).ID,
+		ExpenseID: expense.ID,
+	})
 	ctx.JSON(http.StatusOK, model.Expense{
 		ID:          expense.ID,
 		Amount:      expense.Amount,
@@ -541,6 +614,12 @@ func (h *APIHandler) updateExpense(ctx *gin.Context) {
 		ctx.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
+	h.events.Publish(event.Event{
+		Type:      event.ExpenseUpdated,
+		GroupID:   ctx.Param("id"),
+		ActorID:   GetUser(ctx).ID,
+		ExpenseID: expense.ID,
+	})
 	ctx.JSON(http.StatusOK, model.Expense{
 		ID:          expense.ID,
 		Amount:      expense.Amount,
@@ -601,7 +680,12 @@ func (h *APIHandler) removeGroupMember(ctx *gin.Context) {
 		}
 		return
 	}
-
+	h.events.Publish(event.Event{
+		Type:     event.MemberRemoved,
+		GroupID:  ctx.Param("id"),
+		ActorID:  GetUser(ctx).ID,
+		MemberID: ctx.Param("member_id"),
+	})
 	ctx.Status(http.StatusOK)
 }
 
@@ -615,14 +699,35 @@ func (h *APIHandler) inviteUserToGroup(ctx *gin.Context) {
 		ctx.AbortWithError(http.StatusBadRequest, err)
 		return
 	}
-	if err := h.db.AddUserToGroupByUsername(ctx.Param("id"), req.Username, req.Email); err != nil {
-		if errors.Is(err, db.ErrUserAlreadyInGroup) {
+	if (lo.FromPtr(req.Username) == "") == (lo.FromPtr(req.Email) == "") {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "either username or email is required"})
+		return
+	}
+	group, err := h.db.GetGroup(ctx.Param("id"), GetUser(ctx).ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.AbortWithStatus(http.StatusNotFound)
+		} else {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+		}
+		return
+	}
+	user, err := h.db.AddUserToGroupByUsername(group.ID, req.Username, req.Email)
+	if err != nil {
+		// unknown users get the same answer, so invites do not tell which accounts exist
+		if errors.Is(err, db.ErrUserAlreadyInGroup) || errors.Is(err, sql.ErrNoRows) {
 			ctx.Status(http.StatusOK)
 			return
 		}
 		ctx.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
+	h.events.Publish(event.Event{
+		Type:     event.MemberInvited,
+		GroupID:  group.ID,
+		ActorID:  GetUser(ctx).ID,
+		MemberID: user.ID,
+	})
 	ctx.Status(http.StatusOK)
 }
 
@@ -747,20 +852,48 @@ func (h *APIHandler) noRoute(ctx *gin.Context) {
 	ctx.Data(http.StatusOK, ctype, data)
 }
 
+// expenseAccessCheck allows only members of the group the expense :id belongs to,
+// it must run after jwtTokenCheck.
+func expenseAccessCheck(db db.Database) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if err := db.ExpenseAccessPermissions(GetUser(ctx).ID, ctx.Param("id")); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				// same as a missing expense, ids of other groups are not confirmed
+				ctx.AbortWithStatus(http.StatusNotFound)
+				return
+			}
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		ctx.Next()
+	}
+}
+
 func (h *APIHandler) getExpenseComments(ctx *gin.Context) {
 	cs, err := h.db.GetExpenseComments(ctx.Param("id"))
 	if err != nil {
 		ctx.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
+	attachments, err := h.db.GetCommentAttachments(ctx.Param("id"))
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	attachmentsByComment := lo.GroupBy(attachments, func(attachment entity.ExpenseAttachment) string {
+		return *attachment.CommentID
+	})
 	ctx.JSON(http.StatusOK, lo.Map(cs, func(c entity.Comment, _ int) model.Comment {
 		return model.Comment{
 			ID:          c.ID,
 			Content:     c.Content,
 			CreateBy:    c.CreateBy,
 			DisplayName: c.DisplayName,
-			CreateAt:    c.CreateAt,
-			UpdateAt:    c.UpdateAt,
+			Attachments: lo.Map(attachmentsByComment[c.ID], func(attachment entity.ExpenseAttachment, _ int) model.ExpenseAttachment {
+				return h.toAttachmentModel(attachment)
+			}),
+			CreateAt: c.CreateAt,
+			UpdateAt: c.UpdateAt,
 		}
 	}))
 }
@@ -784,101 +917,219 @@ func (h *APIHandler) createExpenseComment(ctx *gin.Context) {
 		ctx.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
+	if groupID, err := h.db.GetExpenseGroupID(req.ExpenseID); err != nil {
+		slog.Error("get expense group", "expense", req.ExpenseID, "error", err)
+	} else {
+		h.events.Publish(event.Event{
+			Type:      event.CommentAdded,
+			GroupID:   groupID,
+			ActorID:   GetUser(ctx).ID,
+			ExpenseID: req.ExpenseID,
+			CommentID: comment.ID,
+		})
+	}
 	ctx.JSON(http.StatusOK, model.Comment{
 		ID:          comment.ID,
 		Content:     comment.Content,
 		CreateBy:    comment.CreateBy,
 		DisplayName: comment.DisplayName,
+		Attachments: []model.ExpenseAttachment{},
 		CreateAt:    comment.CreateAt,
 		UpdateAt:    comment.UpdateAt,
 	})
 }
 
 func (h *APIHandler) deleteExpenseComment(ctx *gin.Context) {
-	err := h.db.DeleteComment(entity.DeleteCommentArguments{
-		ID:     ctx.Param("comment_id"),
+	comment, err := h.db.GetComment(ctx.Param("comment_id"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.Status(http.StatusOK)
+			return
+		}
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	// attachment rows go with the comment, the files have to be removed here
+	var attachments []entity.ExpenseAttachment
+	if comment.CreateBy == GetUser(ctx).ID {
+		commentAttachments, err := h.db.GetCommentAttachments(comment.ExpenseID)
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		attachments = lo.Filter(commentAttachments, func(attachment entity.ExpenseAttachment, _ int) bool {
+			return *attachment.CommentID == comment.ID
+		})
+	}
+
+	err = h.db.DeleteComment(entity.DeleteCommentArguments{
+		ID:     comment.ID,
 		UserID: GetUser(ctx).ID,
 	})
 	if err != nil {
 		ctx.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
+	for _, attachment := range attachments {
+		h.removeAttachmentBlobs(attachment.ID)
+	}
+	if comment.CreateBy == GetUser(ctx).ID {
+		if groupID, err := h.db.GetExpenseGroupID(comment.ExpenseID); err != nil {
+			slog.Error("get expense group", "expense", comment.ExpenseID, "error", err)
+		} else {
+			h.events.Publish(event.Event{
+				Type:      event.CommentDeleted,
+				GroupID:   groupID,
+				ActorID:   GetUser(ctx).ID,
+				ExpenseID: comment.ExpenseID,
+				CommentID: comment.ID,
+			})
+		}
+	}
 	ctx.Status(http.StatusOK)
 }
 
 func (h *APIHandler) uploadExpenseAttachment(ctx *gin.Context) {
+	// attachments of a comment are uploaded to /api/expense/:id/comment/:comment_id/attachment,
+	// membership of the group was checked by expenseAccessCheck before the body is read
+	var commentID *string
+	if ctx.Param("comment_id") != "" {
+		comment, err := h.db.GetComment(ctx.Param("comment_id"))
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		if err != nil || comment.ExpenseID != ctx.Param("id") {
+			ctx.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		if comment.CreateBy != GetUser(ctx).ID {
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		commentID = &comment.ID
+	}
 
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, h.maxUploadRequestSize())
 	form, err := ctx.MultipartForm()
 	if err != nil {
-		ctx.AbortWithError(http.StatusInternalServerError, err)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			abortWithUploadError(ctx, &uploadError{http.StatusRequestEntityTooLarge, "request is too large"})
+			return
+		}
+		ctx.AbortWithError(http.StatusBadRequest, err)
 		return
 	}
 
+	var files []uploadFile
 	for key, formValue := range form.File {
 		switch key {
-		case "image":
-			if err := h.handleImageForm(ctx, formValue); err != nil {
-				ctx.AbortWithError(http.StatusInternalServerError, err)
-				return
-			}
-		case "file":
-			if err := h.handleImageForm(ctx, formValue); err != nil {
-				ctx.AbortWithError(http.StatusInternalServerError, err)
-				return
+		case "image", "file":
+			for _, fileHeader := range formValue {
+				file, err := h.validateUpload(fileHeader)
+				if err != nil {
+					abortWithUploadError(ctx, err)
+					return
+				}
+				files = append(files, file)
 			}
 		default:
 			ctx.AbortWithError(http.StatusBadRequest, errors.New("invalid form"))
 			return
 		}
 	}
+	if len(files) > maxAttachmentsPerUpload {
+		abortWithUploadError(ctx, &uploadError{http.StatusBadRequest, fmt.Sprintf("at most %d files can be uploaded at once", maxAttachmentsPerUpload)})
+		return
+	}
+
+	groupID, err := h.db.GetExpenseGroupID(ctx.Param("id"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	if err := h.checkGroupQuota(groupID, lo.SumBy(files, func(file uploadFile) int64 { return file.size })); err != nil {
+		abortWithUploadError(ctx, err)
+		return
+	}
+
+	if err := h.handleImageForm(ctx, commentID, files); err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
 	ctx.Status(http.StatusOK)
 }
 
 func (h *APIHandler) deleteExpenseAttachment(ctx *gin.Context) {
-	attachmentID := ctx.Param("attachment_id")
+	attachment, err := h.db.GetExpenseAttachment(ctx.Param("attachment_id"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	// membership was checked for :id, the attachment has to belong to it
+	if attachment.ExpenseID != ctx.Param("id") {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	attachmentID := attachment.ID
 	if err := h.db.DeleteExpenseAttachment(attachmentID); err != nil {
 		ctx.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
-	if err := os.Remove(filepath.Join(h.dataDir, attachmentID)); err != nil {
+	if err := h.blobs.Delete(ctx, attachmentID); err != nil && !errors.Is(err, blobstore.ErrNotExist) {
 		ctx.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
+	if err := h.blobs.Delete(ctx, thumbnail.Key(attachmentID)); err != nil && !errors.Is(err, blobstore.ErrNotExist) {
+		slog.Error("remove thumbnail", "error", err)
+	}
 	ctx.Status(http.StatusOK)
 }
 
-func (h *APIHandler) handleImageForm(ctx *gin.Context, formValue []*multipart.FileHeader) error {
+func (h *APIHandler) handleImageForm(ctx *gin.Context, commentID *string, files []uploadFile) error {
 	var eps []entity.ExpenseAttachment
-	for _, fileHeader := range formValue {
+	for _, upload := range files {
 		ep, err := func() (entity.ExpenseAttachment, error) {
 			now := time.Now()
 			ID := xid.NewWithTime(now).String()
-			storePath := filepath.Join(h.dataDir, ID)
-			if err := ctx.SaveUploadedFile(fileHeader, storePath); err != nil {
-				return entity.ExpenseAttachment{}, err
-			}
-
-			ftype, err := filetype.MatchFile(storePath)
+			file, err := upload.header.Open()
 			if err != nil {
 				return entity.ExpenseAttachment{}, err
 			}
+			defer file.Close()
 
-			if ftype == filetype.Unknown {
-				ftype = filetype.NewType(filepath.Ext(fileHeader.Filename), fileHeader.Header.Get("Content-Type"))
-			}
-
-			return entity.ExpenseAttachment{
+			attachment := entity.ExpenseAttachment{
 				ID:       ID,
-				Filename: fileHeader.Filename,
-				Size:     fileHeader.Size,
-				MIME:     ftype.MIME.Value,
+				Filename: upload.header.Filename,
+				Size:     upload.size,
+				MIME:     upload.mime,
 				CreateAt: now,
-			}, nil
+			}
+			if err := h.blobs.Put(ctx, ID, file, upload.size, attachment.MIME); err != nil {
+				return entity.ExpenseAttachment{}, err
+			}
+			if thumbnail.Supported(attachment.MIME) {
+				// on failure the thumbnail is generated again on first request
+				attachment.ThumbnailSize, err = h.generateThumbnail(ctx, ID)
+				if err != nil {
+					slog.Warn("generate thumbnail", "id", ID, "error", err)
+				}
+			}
+			return attachment, nil
 		}()
 		if err != nil {
 			// upload failed, cleanup file
 			lo.ForEach(eps, func(ep entity.ExpenseAttachment, _ int) {
-				os.Remove(filepath.Join(h.dataDir, ep.ID))
+				h.removeAttachmentBlobs(ep.ID)
 			})
 			return err
 		}
@@ -887,15 +1138,19 @@ func (h *APIHandler) handleImageForm(ctx *gin.Context, formValue []*multipart.Fi
 
 	err := h.db.CreateExpenseAttachments(entity.CreateExpenseAttachmentsArgument{
 		ExpenseID:   ctx.Param("id"),
+		CommentID:   commentID,
 		Attachments: eps,
 	})
 	if err != nil {
 		// write database failed, cleanup file
 		lo.ForEach(eps, func(ep entity.ExpenseAttachment, _ int) {
-			os.Remove(filepath.Join(h.dataDir, ep.ID))
+			h.removeAttachmentBlobs(ep.ID)
 		})
 		return err
 	}
+	for _, ep := range eps {
+		h.receipts.Enqueue(ep.ID)
+	}
 	return nil
 }
 
@@ -907,49 +1162,92 @@ func (h *APIHandler) getExpenseAttachments(ctx *gin.Context) {
 		return
 	}
 	ctx.JSON(http.StatusOK, lo.Map(attachments, func(attachment entity.ExpenseAttachment, _ int) model.ExpenseAttachment {
-		return model.ExpenseAttachment{
-			ID:       attachment.ID,
-			Filename: attachment.Filename,
-			Size:     attachment.Size,
-			MIME:     attachment.MIME,
-			CreateAt: attachment.CreateAt,
-			UpdateAt: attachment.UpdateAt,
-		}
+		return h.toAttachmentModel(attachment)
 	}))
 }
 
+func (h *APIHandler) toAttachmentModel(attachment entity.ExpenseAttachment) model.ExpenseAttachment {
+	return model.ExpenseAttachment{
+		ID:            attachment.ID,
+		Filename:      attachment.Filename,
+		Size:          attachment.Size,
+		ThumbnailSize: attachment.ThumbnailSize,
+		MIME:          attachment.MIME,
+		URL:           h.tokenIssuer.AttachmentURL(attachment.ID, false),
+		ThumbnailURL:  lo.Ternary(thumbnail.Supported(attachment.MIME), h.tokenIssuer.AttachmentURL(attachment.ID, true), ""),
+		CreateAt:      attachment.CreateAt,
+		UpdateAt:      attachment.UpdateAt,
+	}
+}
+
 func (h *APIHandler) staticPhoto(ctx *gin.Context) {
 	attachment, err := h.db.GetExpenseAttachment(ctx.Param("id"))
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.AbortWithStatus(http.StatusNotFound)
+			return
+		}
 		ctx.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
 
-	filepath := filepath.Join(h.dataDir, attachment.ID)
+	key, mime := attachment.ID, attachment.MIME
 	if ctx.Query("thumbnail") != "" {
-		filepath = filepath + "-thumbnail"
+		if !thumbnail.Supported(attachment.MIME) {
+			ctx.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		if attachment.ThumbnailSize == 0 {
+			// uploaded before thumbnails existed, or generating at upload failed
+			attachment.ThumbnailSize, err = h.generateThumbnail(ctx, attachment.ID)
+			if err != nil {
+				if errors.Is(err, thumbnail.ErrTooLarge) {
+					ctx.AbortWithStatus(http.StatusNotFound)
+					return
+				}
+				ctx.AbortWithError(http.StatusInternalServerError, err)
+				return
+			}
+			if err := h.db.SetExpenseAttachmentThumbnail(attachment.ID, attachment.ThumbnailSize); err != nil {
+				slog.Error("set thumbnail size", "error", err)
+			}
+		}
+		key, mime = thumbnail.Key(attachment.ID), thumbnail.MIME
 	}
 
-	file, err := os.Open(filepath)
+	file, info, err := h.blobs.Get(ctx, key)
 	if err != nil {
-		ctx.AbortWithError(http.StatusNotFound, err)
+		if errors.Is(err, blobstore.ErrNotExist) {
+			ctx.AbortWithError(http.StatusNotFound, err)
+			return
+		}
+		ctx.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
 	defer file.Close()
 
-	ctx.Header("Cache-Control", "max-age=31536000")
-	ctx.DataFromReader(http.StatusOK, attachment.Size, attachment.MIME, file, nil)
+	// shared caches must not hand the file to users without access
+	ctx.Header("Cache-Control", "private, max-age=31536000")
+	ctx.Header("X-Content-Type-Options", "nosniff")
+	// anything a browser could run as a page is downloaded instead of shown
+	if !(strings.HasPrefix(mime, "image/") && mime != "image/svg+xml") && mime != "application/pdf" {
+		ctx.Header("Content-Disposition", "attachment")
+	}
+	ctx.DataFromReader(http.StatusOK, info.Size, mime, file, nil)
 }
 
 func (h *APIHandler) getMeSetting(ctx *gin.Context) {
 	setting, err := h.db.GetUserSetting(GetUser(ctx).ID)
-	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+	if errors.Is(err, sql.ErrNoRows) {
+		setting = entity.UserSetting{EmailDigest: true}
+	} else if err != nil {
 		ctx.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, model.UserSetting{
 		ThemeMode:        setting.ThemeMode,
 		PushNotification: setting.PushNotification,
+		EmailDigest:      setting.EmailDigest,
 	})
 }
 
@@ -957,13 +1255,14 @@ func (h *APIHandler) patchMeSetting(ctx *gin.Context) {
 	var req struct {
 		ThemeMode        *string `json:"themeMode"`
 		PushNotification *bool   `json:"pushNotification"`
+		EmailDigest      *bool   `json:"emailDigest"`
 	}
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.AbortWithError(http.StatusBadRequest, err)
 		return
 	}
 
-	setting, err := h.db.UpdateUserSetting(GetUser(ctx).ID, req.ThemeMode, req.PushNotification)
+	setting, err := h.db.UpdateUserSetting(GetUser(ctx).ID, req.ThemeMode, req.PushNotification, req.EmailDigest)
 	if err != nil {
 		ctx.AbortWithError(http.StatusInternalServerError, err)
 		return
@@ -971,5 +1270,6 @@ func (h *APIHandler) patchMeSetting(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, model.UserSetting{
 		ThemeMode:        setting.ThemeMode,
 		PushNotification: setting.PushNotification,
+		EmailDigest:      setting.EmailDigest,
 	})
 }
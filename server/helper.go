@@ -10,3 +10,9 @@ func GetUser(ctx *gin.Context) (user entity.User) {
 	user, _ = anyObj.(entity.User)
 	return
 }
+
+func GetSession(ctx *gin.Context) (session entity.Session) {
+	anyObj, _ := ctx.Get("session")
+	session, _ = anyObj.(entity.Session)
+	return
+}
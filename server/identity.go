@@ -0,0 +1,88 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/waylen888/tab-buddy/db"
+	"github.com/waylen888/tab-buddy/db/entity"
+	"github.com/waylen888/tab-buddy/oidc"
+)
+
+// googleProviderName is the provider name used by the built-in google login.
+const googleProviderName = "google"
+
+// userForIdentity returns the user linked to an external identity, creating the user on first login.
+func userForIdentity(db db.Database, provider string, identity oidc.Identity) (entity.User, error) {
+	if identity.Subject == "" {
+		return entity.User{}, errors.New("identity has no subject")
+	}
+	linked, err := db.GetUserIdentity(provider, identity.Subject)
+	if err == nil {
+		return db.GetUser(linked.UserID)
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return entity.User{}, fmt.Errorf("get identity: %w", err)
+	}
+
+	user, err := legacyGoogleUser(db, provider, identity)
+	if errors.Is(err, sql.ErrNoRows) {
+		user, err = createUserForIdentity(db, provider, identity)
+	}
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	_, err = db.CreateUserIdentity(entity.UserIdentity{
+		Provider: provider,
+		Subject:  identity.Subject,
+		UserID:   user.ID,
+		Email:    identity.Email,
+	})
+	if err != nil {
+		return entity.User{}, fmt.Errorf("create identity: %w", err)
+	}
+	return user, nil
+}
+
+// legacyGoogleUser finds users created by the google login before linked
+// accounts existed, they are keyed by the google id as username.
+func legacyGoogleUser(db db.Database, provider string, identity oidc.Identity) (entity.User, error) {
+	if provider != googleProviderName {
+		return entity.User{}, sql.ErrNoRows
+	}
+	user, err := db.GetUserByUsername(identity.Subject)
+	if err != nil {
+		return entity.User{}, err
+	}
+	if user.CreateType != entity.UserCreateTypeGoogle {
+		return entity.User{}, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+func createUserForIdentity(db db.Database, provider string, identity oidc.Identity) (entity.User, error) {
+	createType := entity.UserCreateTypeOIDC
+	if provider == googleProviderName {
+		createType = entity.UserCreateTypeGoogle
+	}
+	displayName := identity.Name
+	if displayName == "" {
+		displayName = identity.Email
+	}
+	if displayName == "" {
+		displayName = provider + " user"
+	}
+
+	user, err := db.CreateUser(provider+":"+identity.Subject, displayName, identity.Email, "", createType)
+	if err != nil {
+		return entity.User{}, fmt.Errorf("create user: %w", err)
+	}
+	if identity.Email != "" && identity.EmailVerified {
+		if err := db.SetUserEmailVerified(user.ID, identity.Email, time.Now()); err != nil {
+			return entity.User{}, fmt.Errorf("set email verified: %w", err)
+		}
+	}
+	return user, nil
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+	"github.com/waylen888/tab-buddy/importer"
+	"github.com/waylen888/tab-buddy/server/model"
+)
+
+const importMaxSize = 5 << 20
+
+// importGroupExpenses reads a Splitwise or generic CSV sent as the file form field. It only
+// previews the import unless preview=false, the members form field is a JSON object mapping
+// names in the file to user IDs.
+func (h *APIHandler) importGroupExpenses(ctx *gin.Context) {
+	var req struct {
+		Format     importer.Format `form:"format" binding:"required,oneof=splitwise generic"`
+		Preview    *bool           `form:"preview"`
+		Duplicates bool            `form:"duplicates"`
+	}
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+	user := GetUser(ctx)
+	if _, err := h.db.GetGroup(ctx.Param("id"), user.ID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.AbortWithStatus(http.StatusNotFound)
+		} else {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+		}
+		return
+	}
+	opts := importer.Options{
+		GroupID:    ctx.Param("id"),
+		UserID:     user.ID,
+		Duplicates: req.Duplicates,
+	}
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, importMaxSize)
+	if members := ctx.PostForm("members"); members != "" {
+		if err := json.Unmarshal([]byte(members), &opts.Members); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "members must be a JSON object of names to user IDs"})
+			return
+		}
+	}
+
+	fileHeader, err := ctx.FormFile("file")
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	defer file.Close()
+	rows, err := importer.Parse(req.Format, file)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	imports := importer.New(h.db, h.rateGetter)
+	var plan importer.Plan
+	if req.Preview == nil || *req.Preview {
+		plan, err = imports.Preview(rows, opts)
+	} else {
+		plan, err = imports.Import(rows, opts)
+	}
+	if err != nil {
+		if errors.Is(err, importer.ErrMember) {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+		}
+		return
+	}
+	ctx.JSON(http.StatusOK, toImportPlanModel(plan))
+}
+
+func toImportPlanModel(plan importer.Plan) model.ImportPlan {
+	return model.ImportPlan{
+		People: lo.Map(plan.People, func(person importer.Person, _ int) model.ImportPerson {
+			return model.ImportPerson{
+				Name:        person.Name,
+				UserID:      person.UserID,
+				DisplayName: person.DisplayName,
+				Placeholder: person.Placeholder,
+			}
+		}),
+		Expenses: lo.Map(plan.Expenses, func(expense importer.Expense, _ int) model.ImportExpense {
+			return model.ImportExpense{
+				Line:        expense.Line,
+				Date:        expense.Date,
+				Description: expense.Description,
+				Category:    expense.Category,
+				Currency:    expense.Currency,
+				Amount:      expense.Amount.String(),
+				TWDRate:     expense.TWDRate.String(),
+				PaidBy:      expense.PaidBy,
+				OwedBy:      append([]string{}, expense.OwedBy...),
+				Status:      string(expense.Status),
+				Problem:     expense.Problem,
+			}
+		}),
+		Imported: lo.CountBy(plan.Expenses, func(expense importer.Expense) bool {
+			return expense.Status == importer.StatusImported
+		}),
+	}
+}
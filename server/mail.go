@@ -0,0 +1,19 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/waylen888/tab-buddy/mail"
+)
+
+// sendMail renders the mail template name and queues it for the recipients.
+func (h *APIHandler) sendMail(name string, to []string, data any) error {
+	msg, err := mail.Render(name, to, data)
+	if err != nil {
+		return fmt.Errorf("render mail: %w", err)
+	}
+	if err := h.outbox.Enqueue(msg); err != nil {
+		return fmt.Errorf("queue mail: %w", err)
+	}
+	return nil
+}
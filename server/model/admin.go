@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+type OutboxMail struct {
+	ID         string   `json:"id"`
+	Recipients []string `json:"recipients"`
+	Subject    string   `json:"subject"`
+	// Status is queued, sent or dead.
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"lastError"`
+	NextAttemptAt time.Time  `json:"nextAttemptAt"`
+	SentAt        *time.Time `json:"sentAt"`
+	CreateAt      time.Time  `json:"createAt"`
+	UpdateAt      time.Time  `json:"updateAt"`
+}
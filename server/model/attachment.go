@@ -3,10 +3,34 @@ package model
 import "time"
 
 type ExpenseAttachment struct {
-	ID       string    `json:"id"`
-	Filename string    `json:"filename"`
-	Size     int64     `json:"size"`
-	MIME     string    `json:"mime"`
-	CreateAt time.Time `json:"createAt"`
-	UpdateAt time.Time `json:"updateAt"`
+	ID            string `json:"id"`
+	Filename      string `json:"filename"`
+	Size          int64  `json:"size"`
+	ThumbnailSize int64  `json:"thumbnailSize"`
+	MIME          string `json:"mime"`
+	// URL and ThumbnailURL are signed and expire, fetch the attachments again for new ones.
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnailUrl,omitempty"`
+	CreateAt     time.Time `json:"createAt"`
+	UpdateAt     time.Time `json:"updateAt"`
+}
+
+type StorageUsage struct {
+	Attachments int64 `json:"attachments"`
+	Used        int64 `json:"used"`
+	// Quota is 0 when groups are not limited.
+	Quota int64 `json:"quota"`
+}
+
+type Receipt struct {
+	// Status is pending, done, skipped or failed.
+	Status     string            `json:"status"`
+	Text       string            `json:"text"`
+	Suggestion ReceiptSuggestion `json:"suggestion"`
+}
+
+type ReceiptSuggestion struct {
+	Total    string     `json:"total,omitempty"`
+	Date     *time.Time `json:"date,omitempty"`
+	Merchant string     `json:"merchant,omitempty"`
 }
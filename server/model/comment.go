@@ -3,10 +3,12 @@ package model
 import "time"
 
 type Comment struct {
-	ID          string    `json:"id"`
-	Content     string    `json:"content"`
-	CreateBy    string    `json:"createBy"`
-	DisplayName string    `json:"displayName"`
-	CreateAt    time.Time `json:"createAt"`
-	UpdateAt    time.Time `json:"updateAt"`
+	ID          string `json:"id"`
+	Content     string `json:"content"`
+	CreateBy    string `json:"createBy"`
+	DisplayName string `json:"displayName"`
+	// Attachments are screenshots posted with the comment, e.g. of a bank transfer.
+	Attachments []ExpenseAttachment `json:"attachments"`
+	CreateAt    time.Time           `json:"createAt"`
+	UpdateAt    time.Time           `json:"updateAt"`
 }
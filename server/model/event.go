@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+type GroupEvent struct {
+	Type      string    `json:"type"`
+	GroupID   string    `json:"groupId"`
+	ActorID   string    `json:"actorId"`
+	ExpenseID string    `json:"expenseId,omitempty"`
+	CommentID string    `json:"commentId,omitempty"`
+	MemberID  string    `json:"memberId,omitempty"`
+	Time      time.Time `json:"time"`
+}
@@ -0,0 +1,41 @@
+package model
+
+import "time"
+
+type ExportGroup struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// ExportExpense is an expense in a group export, TWD amounts are converted with TWDRate.
+type ExportExpense struct {
+	ID          string        `json:"id"`
+	Date        time.Time     `json:"date"`
+	Description string        `json:"description"`
+	Category    string        `json:"category"`
+	Note        string        `json:"note"`
+	Currency    string        `json:"currency"`
+	Amount      string        `json:"amount"`
+	TWDRate     string        `json:"twdRate"`
+	TWDAmount   string        `json:"twdAmount"`
+	CreatedBy   string        `json:"createdBy"`
+	Splits      []ExportSplit `json:"splits"`
+	// Attachments and Comments are only exported as JSON.
+	Attachments []ExpenseAttachment `json:"attachments"`
+	Comments    []Comment           `json:"comments"`
+	CreateAt    time.Time           `json:"createAt"`
+	UpdateAt    time.Time           `json:"updateAt"`
+}
+
+// ExportSplit is the part of a member in an expense, Balance is what they are owed, negative when they owe.
+type ExportSplit struct {
+	UserID      string `json:"userId"`
+	DisplayName string `json:"displayName"`
+	Email       string `json:"email"`
+	Paid        bool   `json:"paid"`
+	Owed        bool   `json:"owed"`
+	Share       string `json:"share"`
+	TWDShare    string `json:"twdShare"`
+	Balance     string `json:"balance"`
+	TWDBalance  string `json:"twdBalance"`
+}
@@ -3,11 +3,13 @@ package model
 import "time"
 
 type Group struct {
-	ID           string    `json:"id"`
-	Name         string    `json:"name"`
-	ConvertToTwd bool      `json:"convertToTwd"`
-	CreateAt     time.Time `json:"createAt"`
-	UpdateAt     time.Time `json:"updateAt"`
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	ConvertToTwd bool   `json:"convertToTwd"`
+	// ReminderIntervalDays is how often members who owe are reminded automatically, 0 when never.
+	ReminderIntervalDays int       `json:"reminderIntervalDays"`
+	CreateAt             time.Time `json:"createAt"`
+	UpdateAt             time.Time `json:"updateAt"`
 }
 
 type Expense struct {
@@ -45,3 +47,19 @@ type GroupMember struct {
 	User
 	Amount string `json:"amount"`
 }
+
+type Reminder struct {
+	ID     string `json:"id"`
+	UserID string `json:"userId"`
+	// SentBy is empty for automatic reminders.
+	SentBy   string    `json:"sentBy"`
+	Amount   string    `json:"amount"`
+	CreateAt time.Time `json:"createAt"`
+}
+
+// RestoredGroup is a group created from an archive.
+type RestoredGroup struct {
+	Group
+	// Placeholders is how many people of the archive were restored as placeholders.
+	Placeholders int `json:"placeholders"`
+}
@@ -0,0 +1,9 @@
+package model
+
+import "time"
+
+type Identity struct {
+	Provider string    `json:"provider"`
+	Email    string    `json:"email"`
+	CreateAt time.Time `json:"createAt"`
+}
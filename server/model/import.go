@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+type ImportPlan struct {
+	People   []ImportPerson  `json:"people"`
+	Expenses []ImportExpense `json:"expenses"`
+	// Imported is how many expenses were created, always 0 for a preview.
+	Imported int `json:"imported"`
+}
+
+type ImportPerson struct {
+	Name        string `json:"name"`
+	UserID      string `json:"userId"`
+	DisplayName string `json:"displayName"`
+	Placeholder bool   `json:"placeholder"`
+}
+
+type ImportExpense struct {
+	Line        int       `json:"line"`
+	Date        time.Time `json:"date"`
+	Description string    `json:"description"`
+	Category    string    `json:"category"`
+	Currency    string    `json:"currency"`
+	Amount      string    `json:"amount"`
+	TWDRate     string    `json:"twdRate"`
+	PaidBy      string    `json:"paidBy"`
+	OwedBy      []string  `json:"owedBy"`
+	// Status is new, duplicate, invalid or imported.
+	Status  string `json:"status"`
+	Problem string `json:"problem,omitempty"`
+}
@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+type Notification struct {
+	ID        string     `json:"id"`
+	Event     string     `json:"event"`
+	GroupID   string     `json:"groupId"`
+	ExpenseID string     `json:"expenseId,omitempty"`
+	ActorID   string     `json:"actorId"`
+	Title     string     `json:"title"`
+	Body      string     `json:"body"`
+	Link      string     `json:"link"`
+	ReadAt    *time.Time `json:"readAt"`
+	CreateAt  time.Time  `json:"createAt"`
+}
+
+type Notifications struct {
+	Notifications []Notification `json:"notifications"`
+	// Unread counts all unread notifications, not only those in this page.
+	Unread int `json:"unread"`
+}
+
+type NotificationPreference struct {
+	// Event is expense_created, expense_updated, comment_added or member_invited.
+	Event string `json:"event" binding:"required"`
+	// Channel is inbox, email or push.
+	Channel string `json:"channel" binding:"required"`
+	Enabled bool   `json:"enabled"`
+}
@@ -0,0 +1,8 @@
+package model
+
+type Profile struct {
+	User
+	EmailVerified bool `json:"emailVerified"`
+	// HasPassword is false for users created by a third party login.
+	HasPassword bool `json:"hasPassword"`
+}
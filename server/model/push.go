@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// PushSubscription is the json of a browser PushSubscription.
+type PushSubscription struct {
+	Endpoint string `json:"endpoint" binding:"required"`
+	Keys     struct {
+		P256dh string `json:"p256dh" binding:"required"`
+		Auth   string `json:"auth" binding:"required"`
+	} `json:"keys"`
+}
+
+type PushDevice struct {
+	ID        string    `json:"id"`
+	Endpoint  string    `json:"endpoint"`
+	UserAgent string    `json:"userAgent"`
+	CreateAt  time.Time `json:"createAt"`
+}
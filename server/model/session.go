@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+type Session struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"userAgent"`
+	IP         string    `json:"ip"`
+	Current    bool      `json:"current"`
+	CreateAt   time.Time `json:"createAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+}
@@ -14,4 +14,5 @@ type User struct {
 type UserSetting struct {
 	ThemeMode        string `json:"themeMode"`
 	PushNotification bool   `json:"pushNotification"`
+	EmailDigest      bool   `json:"emailDigest"`
 }
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+	"github.com/waylen888/tab-buddy/db/entity"
+	"github.com/waylen888/tab-buddy/event"
+	"github.com/waylen888/tab-buddy/notify"
+	"github.com/waylen888/tab-buddy/server/model"
+)
+
+// getMeNotifications returns a page of the inbox, newest first. The next page starts
+// before the id of the last notification, e.g. ?before=:id&limit=20&unread=1.
+func (h *APIHandler) getMeNotifications(ctx *gin.Context) {
+	limit, err := strconv.Atoi(ctx.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 || limit > 100 {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+		return
+	}
+	unreadOnly, _ := strconv.ParseBool(ctx.DefaultQuery("unread", "0"))
+
+	userID := GetUser(ctx).ID
+	notifications, err := h.db.GetNotifications(userID, ctx.Query("before"), limit, unreadOnly)
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	unread, err := h.db.CountUnreadNotifications(userID)
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, model.Notifications{
+		Notifications: lo.Map(notifications, func(notification entity.Notification, _ int) model.Notification {
+			return model.Notification{
+				ID:        notification.ID,
+				Event:     notification.Event,
+				GroupID:   notification.GroupID,
+				ExpenseID: notification.ExpenseID,
+				ActorID:   notification.ActorID,
+				Title:     notification.Title,
+				Body:      notification.Body,
+				Link:      notification.Link,
+				ReadAt:    notification.ReadAt,
+				CreateAt:  notification.CreateAt,
+			}
+		}),
+		Unread: unread,
+	})
+}
+
+func (h *APIHandler) readMeNotification(ctx *gin.Context) {
+	if err := h.db.MarkNotificationRead(GetUser(ctx).ID, ctx.Param("notification_id"), time.Now()); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	ctx.Status(http.StatusOK)
+}
+
+func (h *APIHandler) readAllMeNotifications(ctx *gin.Context) {
+	if err := h.db.MarkAllNotificationsRead(GetUser(ctx).ID, time.Now()); err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	ctx.Status(http.StatusOK)
+}
+
+// getMeNotificationPreferences returns every event and channel pair, with the defaults
+// for those the user has not changed.
+func (h *APIHandler) getMeNotificationPreferences(ctx *gin.Context) {
+	h.respondWithNotificationPreferences(ctx, GetUser(ctx).ID)
+}
+
+func (h *APIHandler) putMeNotificationPreferences(ctx *gin.Context) {
+	var req []model.NotificationPreference
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+	preferences := make([]entity.NotificationPreference, 0, len(req))
+	for _, preference := range req {
+		if !slices.Contains(notify.Events, event.Type(preference.Event)) || !slices.Contains(notify.Channels, preference.Channel) {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "unknown event or channel"})
+			return
+		}
+		preferences = append(preferences, entity.NotificationPreference{
+			Event:   preference.Event,
+			Channel: preference.Channel,
+			Enabled: preference.Enabled,
+		})
+	}
+	userID := GetUser(ctx).ID
+	if err := h.db.SetNotificationPreferences(userID, preferences); err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	h.respondWithNotificationPreferences(ctx, userID)
+}
+
+func (h *APIHandler) respondWithNotificationPreferences(ctx *gin.Context, userID string) {
+	stored, err := h.db.GetNotificationPreferences(userID)
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	preferences := make([]model.NotificationPreference, 0, len(notify.Events)*len(notify.Channels))
+	for _, e := range notify.Events {
+		for _, channel := range notify.Channels {
+			enabled := notify.DefaultEnabled(e, channel)
+			for _, preference := range stored {
+				if preference.Event == string(e) && preference.Channel == channel {
+					enabled = preference.Enabled
+				}
+			}
+			preferences = append(preferences, model.NotificationPreference{
+				Event:   string(e),
+				Channel: channel,
+				Enabled: enabled,
+			})
+		}
+	}
+	ctx.JSON(http.StatusOK, preferences)
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/waylen888/tab-buddy/db"
+	"github.com/waylen888/tab-buddy/db/entity"
+	"golang.org/x/oauth2"
+)
+
+const (
+	oauthStateCookie = "oauth_state"
+	oauthStateTTL    = time.Minute * 10
+)
+
+// newOAuthState starts an authorization request and binds it to the browser with a cookie.
+func newOAuthState(db db.Database, ctx *gin.Context, provider string, linkUserID string) (entity.OAuthState, error) {
+	state, err := newRandomToken()
+	if err != nil {
+		return entity.OAuthState{}, fmt.Errorf("generate state: %w", err)
+	}
+	nonce, err := newRandomToken()
+	if err != nil {
+		return entity.OAuthState{}, fmt.Errorf("generate nonce: %w", err)
+	}
+	now := time.Now()
+	oauthState := entity.OAuthState{
+		State:        state,
+		Provider:     provider,
+		Nonce:        nonce,
+		CodeVerifier: oauth2.GenerateVerifier(),
+		LinkUserID:   linkUserID,
+		ExpireAt:     now.Add(oauthStateTTL),
+		CreateAt:     now,
+	}
+	if err := db.CreateOAuthState(oauthState); err != nil {
+		return entity.OAuthState{}, fmt.Errorf("create state: %w", err)
+	}
+
+	// lax, the callback is a top level navigation from the provider
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(oauthStateCookie, state, int(oauthStateTTL.Seconds()), "/", "", ctx.Request.TLS != nil, true)
+	return oauthState, nil
+}
+
+// takeOAuthState validates the state of a callback against the cookie and consumes it.
+func takeOAuthState(db db.Database, ctx *gin.Context, provider string) (entity.OAuthState, error) {
+	state := ctx.Query("state")
+	cookie, _ := ctx.Cookie(oauthStateCookie)
+	ctx.SetCookie(oauthStateCookie, "", -1, "/", "", ctx.Request.TLS != nil, true)
+	if state == "" || state != cookie {
+		return entity.OAuthState{}, errors.New("state mismatch")
+	}
+
+	oauthState, err := db.TakeOAuthState(state)
+	if err != nil {
+		return entity.OAuthState{}, fmt.Errorf("take state: %w", err)
+	}
+	if oauthState.Provider != provider {
+		return entity.OAuthState{}, errors.New("state provider mismatch")
+	}
+	if time.Now().After(oauthState.ExpireAt) {
+		return entity.OAuthState{}, errors.New("state expired")
+	}
+	return oauthState, nil
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+	"github.com/waylen888/tab-buddy/config"
+	"github.com/waylen888/tab-buddy/db"
+	"github.com/waylen888/tab-buddy/db/entity"
+	"github.com/waylen888/tab-buddy/oidc"
+	"github.com/waylen888/tab-buddy/server/model"
+)
+
+type OIDCHandler struct {
+	db          db.Database
+	providers   map[string]*oidc.Provider
+	tokenIssuer *TokenIssuer
+}
+
+func NewOIDCHandler(db db.Database, providerCfgs []config.OIDCProvider, tokenIssuer *TokenIssuer) *OIDCHandler {
+	providers := make(map[string]*oidc.Provider)
+	for _, cfg := range providerCfgs {
+		providers[cfg.Name] = oidc.NewProvider(cfg)
+	}
+	return &OIDCHandler{
+		db:          db,
+		providers:   providers,
+		tokenIssuer: tokenIssuer,
+	}
+}
+
+func (h *OIDCHandler) provider(ctx *gin.Context) (*oidc.Provider, bool) {
+	provider, ok := h.providers[ctx.Param("provider")]
+	if !ok {
+		ctx.AbortWithStatus(http.StatusNotFound)
+	}
+	return provider, ok
+}
+
+func (h *OIDCHandler) getProviders(ctx *gin.Context) {
+	names := lo.Keys(h.providers)
+	sort.Strings(names)
+	ctx.JSON(http.StatusOK, names)
+}
+
+func (h *OIDCHandler) Login(ctx *gin.Context) {
+	provider, ok := h.provider(ctx)
+	if !ok {
+		return
+	}
+	state, err := newOAuthState(h.db, ctx, provider.Name, "")
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	authURL, err := provider.AuthCodeURL(ctx.Request.Context(), state.State, state.Nonce, state.CodeVerifier)
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+	ctx.Redirect(http.StatusFound, authURL)
+	ctx.Abort()
+}
+
+func (h *OIDCHandler) Callback(ctx *gin.Context) {
+	provider, ok := h.provider(ctx)
+	if !ok {
+		return
+	}
+	state, err := takeOAuthState(h.db, ctx, provider.Name)
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+	if errCode := ctx.Query("error"); errCode != "" {
+		ctx.AbortWithError(http.StatusUnauthorized, errors.New(errCode+": "+ctx.Query("error_description")))
+		return
+	}
+
+	identity, err := provider.Exchange(ctx.Request.Context(), ctx.Query("code"), state.CodeVerifier, state.Nonce)
+	if err != nil {
+		ctx.AbortWithError(http.StatusUnauthorized, err)
+		return
+	}
+	slog.Info("oidc callback", "provider", provider.Name, "subject", identity.Subject)
+
+	if state.LinkUserID != "" {
+		_, err := h.db.CreateUserIdentity(entity.UserIdentity{
+			Provider: provider.Name,
+			Subject:  identity.Subject,
+			UserID:   state.LinkUserID,
+			Email:    identity.Email,
+		})
+		if err != nil {
+			if errors.Is(err, db.ErrIdentityAlreadyLinked) {
+				ctx.AbortWithError(http.StatusConflict, err)
+				return
+			}
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		ctx.Redirect(http.StatusFound, "/settings?"+url.Values{"linked": {provider.Name}}.Encode())
+		return
+	}
+
+	user, err := userForIdentity(h.db, provider.Name, identity)
+	if err != nil {
+		ctx.AbortWithError(http.StatusForbidden, err)
+		return
+	}
+	redirectWithNewSession(ctx, h.db, h.tokenIssuer, user)
+}
+
+// linkIdentity starts linking another provider to the logged in user, the app
+// navigates to the returned url since a redirect can not carry the bearer token.
+func (h *OIDCHandler) linkIdentity(ctx *gin.Context) {
+	provider, ok := h.provider(ctx)
+	if !ok {
+		return
+	}
+	state, err := newOAuthState(h.db, ctx, provider.Name, GetUser(ctx).ID)
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	authURL, err := provider.AuthCodeURL(ctx.Request.Context(), state.State, state.Nonce, state.CodeVerifier)
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"url": authURL})
+}
+
+func (h *OIDCHandler) getMeIdentities(ctx *gin.Context) {
+	identities, err := h.db.GetUserIdentities(GetUser(ctx).ID)
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, lo.Map(identities, func(identity entity.UserIdentity, _ int) model.Identity {
+		return model.Identity{
+			Provider: identity.Provider,
+			Email:    identity.Email,
+			CreateAt: identity.CreateAt,
+		}
+	}))
+}
+
+func (h *OIDCHandler) unlinkIdentity(ctx *gin.Context) {
+	user := GetUser(ctx)
+	identities, err := h.db.GetUserIdentities(user.ID)
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	// keep at least one way to log in
+	if user.CreateType != entity.UserCreateTypeDefault && len(identities) <= 1 {
+		ctx.AbortWithError(http.StatusBadRequest, errors.New("can not unlink the only login method"))
+		return
+	}
+	if err := h.db.DeleteUserIdentity(user.ID, ctx.Param("provider")); err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	ctx.Status(http.StatusOK)
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/waylen888/tab-buddy/db/entity"
+	"github.com/waylen888/tab-buddy/server/model"
+)
+
+func toProfile(user entity.User) model.Profile {
+	return model.Profile{
+		User: model.User{
+			ID:          user.ID,
+			Username:    user.Username,
+			DisplayName: user.DisplayName,
+			Email:       user.Email,
+			CreateAt:    user.CreateAt,
+			UpdateAt:    user.UpdateAt,
+		},
+		EmailVerified: user.EmailVerifiedAt != nil,
+		HasPassword:   user.CreateType == entity.UserCreateTypeDefault,
+	}
+}
+
+func (h *APIHandler) getMe(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, toProfile(GetUser(ctx)))
+}
+
+func (h *APIHandler) patchMe(ctx *gin.Context) {
+	var req struct {
+		DisplayName *string `json:"displayName"`
+		Email       *string `json:"email" binding:"omitempty,email"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	before := GetUser(ctx)
+	user, err := h.db.UpdateUser(before.ID, req.DisplayName, req.Email)
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	if user.Email != "" && user.Email != before.Email {
+		if err := h.sendEmailVerification(user); err != nil {
+			slog.Error("send email verification", "error", err)
+		}
+	}
+	ctx.JSON(http.StatusOK, toProfile(user))
+}
+
+func (h *APIHandler) changeMePassword(ctx *gin.Context) {
+	var req struct {
+		CurrentPassword string `json:"currentPassword"`
+		NewPassword     string `json:"newPassword" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	user := GetUser(ctx)
+	// users created by google never had a password to confirm
+	if user.CreateType == entity.UserCreateTypeDefault {
+		if err := user.CheckPassword(req.CurrentPassword); err != nil {
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+	}
+	if err := h.db.UpdateUserPassword(user.ID, req.NewPassword); err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	// keep the current device logged in, log out the others
+	if err := h.db.RevokeUserSessions(user.ID, GetSession(ctx).ID); err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	ctx.Status(http.StatusOK)
+}
+
+func (h *APIHandler) deleteMe(ctx *gin.Context) {
+	var req struct {
+		Password string `json:"password"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	user := GetUser(ctx)
+	if user.CreateType == entity.UserCreateTypeDefault {
+		if err := user.CheckPassword(req.Password); err != nil {
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+	}
+
+	groups, err := h.db.GetGroups(user.ID)
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	var unsettled []string
+	for _, group := range groups {
+		expenses, err := h.db.GetGroupExpenses(group.ID)
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		if hasOutstandingBalance(expenses, user.ID) {
+			unsettled = append(unsettled, group.ID)
+		}
+	}
+	if len(unsettled) > 0 {
+		ctx.Error(errors.New("the user still has outstanding balances"))
+		ctx.AbortWithStatusJSON(http.StatusConflict, gin.H{
+			"error":  "settle up before deleting the account",
+			"groups": unsettled,
+		})
+		return
+	}
+
+	if err := h.db.DeleteUser(user.ID); err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	ctx.Status(http.StatusOK)
+}
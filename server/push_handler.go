@@ -0,0 +1,107 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+	"github.com/waylen888/tab-buddy/db"
+	"github.com/waylen888/tab-buddy/db/entity"
+	"github.com/waylen888/tab-buddy/server/model"
+	"github.com/waylen888/tab-buddy/webpush"
+)
+
+// PushHandler manages the browsers users receive web push notifications on.
+type PushHandler struct {
+	db db.Database
+	// sender is nil when no VAPID keys are configured.
+	sender    *webpush.Sender
+	allowHTTP bool
+}
+
+func NewPushHandler(db db.Database, sender *webpush.Sender, allowHTTP bool) *PushHandler {
+	return &PushHandler{
+		db:        db,
+		sender:    sender,
+		allowHTTP: allowHTTP,
+	}
+}
+
+// getVAPIDPublicKey returns the applicationServerKey for PushManager.subscribe.
+func (h *PushHandler) getVAPIDPublicKey(ctx *gin.Context) {
+	if h.sender == nil {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "push notifications are not enabled"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"publicKey": h.sender.PublicKey()})
+}
+
+func (h *PushHandler) getPushSubscriptions(ctx *gin.Context) {
+	subscriptions, err := h.db.GetUserPushSubscriptions(GetUser(ctx).ID)
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, lo.Map(subscriptions, func(subscription entity.PushSubscription, _ int) model.PushDevice {
+		return model.PushDevice{
+			ID:        subscription.ID,
+			Endpoint:  subscription.Endpoint,
+			UserAgent: subscription.UserAgent,
+			CreateAt:  subscription.CreateAt,
+		}
+	}))
+}
+
+func (h *PushHandler) createPushSubscription(ctx *gin.Context) {
+	if h.sender == nil {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "push notifications are not enabled"})
+		return
+	}
+	var req model.PushSubscription
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+	subscription := webpush.Subscription{
+		Endpoint: req.Endpoint,
+		P256dh:   req.Keys.P256dh,
+		Auth:     req.Keys.Auth,
+	}
+	if err := subscription.Validate(h.allowHTTP); err != nil {
+		ctx.Error(err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	created, err := h.db.CreatePushSubscription(entity.CreatePushSubscriptionArguments{
+		UserID:    GetUser(ctx).ID,
+		Endpoint:  subscription.Endpoint,
+		P256dh:    subscription.P256dh,
+		Auth:      subscription.Auth,
+		UserAgent: ctx.Request.UserAgent(),
+	})
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, model.PushDevice{
+		ID:        created.ID,
+		Endpoint:  created.Endpoint,
+		UserAgent: created.UserAgent,
+		CreateAt:  created.CreateAt,
+	})
+}
+
+func (h *PushHandler) deletePushSubscription(ctx *gin.Context) {
+	var req struct {
+		Endpoint string `json:"endpoint" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+	if err := h.db.DeletePushSubscription(GetUser(ctx).ID, req.Endpoint); err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	ctx.Status(http.StatusOK)
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/waylen888/tab-buddy/db/entity"
+	"github.com/waylen888/tab-buddy/receipt"
+	"github.com/waylen888/tab-buddy/server/model"
+)
+
+var receiptStatus = map[entity.AttachmentTextStatus]string{
+	entity.AttachmentTextStatusDone:    "done",
+	entity.AttachmentTextStatusSkipped: "skipped",
+	entity.AttachmentTextStatusFailed:  "failed",
+}
+
+// getAttachmentReceipt returns the text read from an attachment and the expense fields it suggests,
+// the status is pending until the attachment was processed.
+func (h *APIHandler) getAttachmentReceipt(ctx *gin.Context) {
+	attachmentID := ctx.Param("attachment_id")
+	if err := h.db.AttachmentAccessPermissions(GetUser(ctx).ID, attachmentID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	text, err := h.db.GetAttachmentText(attachmentID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusOK, model.Receipt{Status: "pending"})
+			return
+		}
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, model.Receipt{
+		Status: receiptStatus[text.Status],
+		Text:   text.Text,
+		Suggestion: model.ReceiptSuggestion{
+			Total:    text.Total,
+			Date:     text.Date,
+			Merchant: text.Merchant,
+		},
+	})
+}
+
+// scanReceipt reads a receipt picked before its expense exists and returns the expense fields it
+// suggests. The file is not stored, the app uploads it as an attachment of the created expense.
+func (h *APIHandler) scanReceipt(ctx *gin.Context) {
+	if _, err := h.db.GetGroup(ctx.Param("id"), GetUser(ctx).ID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.AbortWithStatus(http.StatusNotFound)
+		} else {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	// leave room for the multipart headers
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, h.attachments.MaxFileSize+1<<20)
+	fileHeader, err := ctx.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			abortWithUploadError(ctx, &uploadError{http.StatusRequestEntityTooLarge, "request is too large"})
+			return
+		}
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+	upload, err := h.validateUpload(fileHeader)
+	if err != nil {
+		abortWithUploadError(ctx, err)
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	defer file.Close()
+
+	text, suggestion, err := h.receipts.Scan(ctx.Request.Context(), file, upload.mime)
+	switch {
+	case errors.Is(err, receipt.ErrUnsupported):
+		ctx.JSON(http.StatusOK, model.Receipt{Status: receiptStatus[entity.AttachmentTextStatusSkipped]})
+	case err != nil:
+		slog.Warn("scan receipt", "filename", fileHeader.Filename, "error", err)
+		ctx.JSON(http.StatusOK, model.Receipt{Status: receiptStatus[entity.AttachmentTextStatusFailed]})
+	default:
+		ctx.JSON(http.StatusOK, model.Receipt{
+			Status: receiptStatus[entity.AttachmentTextStatusDone],
+			Text:   text,
+			Suggestion: model.ReceiptSuggestion{
+				Total:    suggestion.Total,
+				Date:     suggestion.Date,
+				Merchant: suggestion.Merchant,
+			},
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/waylen888/tab-buddy/reminder"
+	"github.com/waylen888/tab-buddy/server/model"
+)
+
+func (h *APIHandler) remindGroupMember(ctx *gin.Context) {
+	groupID := ctx.Param("id")
+	memberID := ctx.Param("member_id")
+	user := GetUser(ctx)
+	if _, err := h.db.GetGroup(groupID, user.ID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.AbortWithStatus(http.StatusNotFound)
+		} else {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+		}
+		return
+	}
+	if memberID == user.ID {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "cannot remind yourself"})
+		return
+	}
+	if _, err := h.db.GetGroup(groupID, memberID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.AbortWithStatus(http.StatusNotFound)
+		} else {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	sent, err := h.reminders.Remind(groupID, memberID, user.ID)
+	if err != nil {
+		var throttled *reminder.ThrottledError
+		switch {
+		case errors.Is(err, reminder.ErrNothingOwed):
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.As(err, &throttled):
+			ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+			ctx.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		default:
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+		}
+		return
+	}
+	ctx.JSON(http.StatusOK, model.Reminder{
+		ID:       sent.ID,
+		UserID:   sent.UserID,
+		SentBy:   sent.SentBy,
+		Amount:   sent.Amount,
+		CreateAt: sent.CreateAt,
+	})
+}
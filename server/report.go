@@ -0,0 +1,55 @@
+package server
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/waylen888/tab-buddy/report"
+)
+
+// getGroupReport renders the settlement report of a group as a printable HTML page or a PDF.
+func (h *APIHandler) getGroupReport(ctx *gin.Context) {
+	format := ctx.DefaultQuery("format", "html")
+	if format != "html" && format != "pdf" {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "format must be html or pdf"})
+		return
+	}
+	group, err := h.db.GetGroup(ctx.Param("id"), GetUser(ctx).ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.AbortWithStatus(http.StatusNotFound)
+		} else {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+		}
+		return
+	}
+	now := time.Now()
+	data, err := report.Build(h.db, group, now)
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	// rendered to a buffer first, a failure can still be answered with an error status
+	var buf bytes.Buffer
+	contentType := "text/html; charset=utf-8"
+	if format == "pdf" {
+		contentType = "application/pdf"
+		err = h.reports.PDF(&buf, data)
+	} else {
+		err = h.reports.HTML(&buf, data)
+	}
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, fmt.Errorf("render report: %w", err))
+		return
+	}
+	filename := fmt.Sprintf("%s-report-%s.%s", group.Name, now.Format("20060102"), format)
+	ctx.Header("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": filename}))
+	ctx.Data(http.StatusOK, contentType, buf.Bytes())
+}
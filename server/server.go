@@ -7,28 +7,120 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/waylen888/tab-buddy/backup"
+	"github.com/waylen888/tab-buddy/blobstore"
 	"github.com/waylen888/tab-buddy/config"
 	"github.com/waylen888/tab-buddy/db"
+	"github.com/waylen888/tab-buddy/digest"
+	"github.com/waylen888/tab-buddy/event"
 	"github.com/waylen888/tab-buddy/finmind"
 	"github.com/waylen888/tab-buddy/mail"
+	"github.com/waylen888/tab-buddy/notify"
+	"github.com/waylen888/tab-buddy/receipt"
+	"github.com/waylen888/tab-buddy/reminder"
+	"github.com/waylen888/tab-buddy/report"
+	"github.com/waylen888/tab-buddy/webpush"
 )
 
 type Server struct {
 	handler       *APIHandler
 	googleHandler *GoogleHandler
+	oidcHandler   *OIDCHandler
+	pushHandler   *PushHandler
+	streamHandler *StreamHandler
+	digests       *digest.Scheduler
+	reminders     *reminder.Scheduler
+	backups       *backup.Scheduler
+	admins        []string
 }
 
 func New(db db.Database, cfg config.Config) (*Server, error) {
-	handler, err := NewAPIHandler(db, finmind.NewClient(), cfg.DataDir, mail.NewSender(cfg.SMTP))
+	tokenIssuer, err := NewTokenIssuer(cfg.Token)
+	if err != nil {
+		return nil, fmt.Errorf("new token issuer: %w", err)
+	}
+	blobs, err := blobstore.Open(cfg.Storage, cfg.Storage.Backend)
+	if err != nil {
+		return nil, fmt.Errorf("open storage: %w", err)
+	}
+	extractor, err := receipt.NewExtractor(cfg.Receipt)
+	if err != nil {
+		return nil, fmt.Errorf("new receipt extractor: %w", err)
+	}
+	outbox := mail.NewOutbox(db, mail.NewSender(cfg.SMTP), cfg.Outbox)
+	events := event.NewBus()
+	channels := []notify.Channel{
+		notify.NewInbox(db),
+		notify.NewEmail(outbox, cfg.HTTPSetting.PublicURL),
+	}
+	var pushSender *webpush.Sender
+	if cfg.WebPush.VAPIDPrivateKey != "" {
+		pushSender, err = webpush.NewSender(webpush.Keys{
+			PublicKey:  cfg.WebPush.VAPIDPublicKey,
+			PrivateKey: cfg.WebPush.VAPIDPrivateKey,
+		}, cfg.WebPush.Subject, cfg.WebPush.AllowHTTP)
+		if err != nil {
+			return nil, fmt.Errorf("new web push sender: %w", err)
+		}
+		channels = append(channels, notify.NewPush(db, pushSender))
+	}
+	dispatcher := notify.NewDispatcher(db, channels...)
+	// every subscriber runs on its own, streams are not held up while notifications wait on push services
+	broker := event.NewBroker()
+	events.Subscribe(broker.Handle)
+	events.Subscribe(dispatcher.Handle)
+	reminders := reminder.NewScheduler(db, events)
+	reports, err := report.NewRenderer(cfg.Report)
+	if err != nil {
+		return nil, fmt.Errorf("new report renderer: %w", err)
+	}
+	handler, err := NewAPIHandler(db, finmind.NewClient(), blobs, cfg.Attachment, receipt.NewProcessor(db, blobs, extractor), outbox, events, reminders, reports, tokenIssuer, cfg.HTTPSetting.PublicURL)
 	if err != nil {
 		return nil, fmt.Errorf("new handler: %w", err)
 	}
 	return &Server{
 		handler:       handler,
-		googleHandler: NewGoogleHandler(db, cfg.GoogleOAuth),
+		googleHandler: NewGoogleHandler(db, cfg.GoogleOAuth, tokenIssuer),
+		oidcHandler:   NewOIDCHandler(db, cfg.OIDC, tokenIssuer),
+		pushHandler:   NewPushHandler(db, pushSender, cfg.WebPush.AllowHTTP),
+		streamHandler: NewStreamHandler(db, broker),
+		digests:       digest.NewScheduler(db, outbox, cfg.Digest, cfg.HTTPSetting.PublicURL),
+		reminders:     reminders,
+		backups:       backup.NewScheduler(db, blobs, cfg.Backup),
+		admins:        cfg.Admins,
 	}, nil
 }
 
+// RunReceiptProcessor extracts the text of uploaded receipts until ctx is done.
+func (s *Server) RunReceiptProcessor(ctx context.Context) error {
+	return s.handler.receipts.Run(ctx)
+}
+
+// RunEvents delivers events to notifications and group streams until ctx is done.
+func (s *Server) RunEvents(ctx context.Context) error {
+	return s.handler.events.Run(ctx)
+}
+
+// RunDigests emails users their periodic digest until ctx is done.
+func (s *Server) RunDigests(ctx context.Context) error {
+	return s.digests.Run(ctx)
+}
+
+// RunReminders sends the automatic reminders of groups until ctx is done.
+func (s *Server) RunReminders(ctx context.Context) error {
+	return s.reminders.Run(ctx)
+}
+
+// RunBackups takes scheduled snapshots of the database and attachments until ctx is done.
+func (s *Server) RunBackups(ctx context.Context) error {
+	return s.backups.Run(ctx)
+}
+
+// RunOutbox sends queued emails until ctx is done.
+func (s *Server) RunOutbox(ctx context.Context) error {
+	return s.handler.outbox.Run(ctx)
+}
+
 func (s *Server) Run(ctx context.Context, httpSetting config.HTTPSetting) error {
 	engine := gin.New()
 	engine.Use(gin.Recovery())
@@ -39,37 +131,80 @@ func (s *Server) Run(ctx context.Context, httpSetting config.HTTPSetting) error
 
 	engine.GET("/api/google/oauth/login", s.googleHandler.Login)
 
+	engine.GET("/api/oidc/providers", s.oidcHandler.getProviders)
+	engine.GET("/api/oidc/:provider/login", s.oidcHandler.Login)
+	engine.GET("/api/oidc/:provider/callback", s.oidcHandler.Callback)
+
 	engine.NoRoute(s.handler.noRoute)
 
 	engine.POST("/api/auth/google/login", s.handler.loginByGoogleToken)
 	engine.POST("/api/auth/login", s.handler.login)
-	engine.GET("/api/auth/refresh_token", jwtTokenCheck(s.handler.db), s.handler.refreshToken)
+	engine.POST("/api/auth/token", s.handler.exchangeRefreshToken)
+	engine.POST("/api/auth/logout", s.handler.logout)
+	engine.POST("/api/auth/password_reset", s.handler.requestPasswordReset)
+	engine.POST("/api/auth/password_reset/confirm", s.handler.confirmPasswordReset)
+	engine.POST("/api/auth/email_verification/confirm", s.handler.confirmEmailVerification)
 
-	engine.GET("/static/photo/:id", s.handler.staticPhoto)
+	engine.GET("/static/photo/:id", attachmentAccessCheck(s.handler.db, s.handler.tokenIssuer), s.handler.staticPhoto)
 	engine.POST("/api/user", s.handler.createUser)
 
-	authRoute := engine.Group("", jwtTokenCheck(s.handler.db))
+	authRoute := engine.Group("", jwtTokenCheck(s.handler.db, s.handler.tokenIssuer))
 	authRoute.GET("/api/groups", s.handler.getGroups)
 	authRoute.GET("/api/group/:id", s.handler.getGroup)
 	authRoute.POST("/api/group", s.handler.createGroup)
+	authRoute.POST("/api/groups/restore", s.handler.restoreGroupArchive)
 	authRoute.PUT("/api/group/:id", s.handler.updateGroup)
 	authRoute.DELETE("/api/group/:id", s.handler.deleteGroup)
 	authRoute.GET("/api/group/:id/expenses", s.handler.getGroupExpenses)
 	authRoute.POST("/api/group/:id/expense", s.handler.createExpense)
 	authRoute.PUT("/api/group/:id/expense/:expense_id", s.handler.updateExpense)
+	authRoute.POST("/api/group/:id/receipt", s.handler.scanReceipt)
 	authRoute.GET("/api/group/:id/members", s.handler.getGroupMembers)
+	authRoute.POST("/api/group/:id/members/:member_id/remind", s.handler.remindGroupMember)
+	authRoute.GET("/api/group/:id/storage", s.handler.getGroupStorage)
+	authRoute.GET("/api/group/:id/export", s.handler.exportGroup)
+	authRoute.GET("/api/group/:id/report", s.handler.getGroupReport)
+	authRoute.POST("/api/group/:id/import", s.handler.importGroupExpenses)
+	authRoute.GET("/api/group/:id/archive", s.handler.getGroupArchive)
+	authRoute.GET("/api/group/:id/events", s.streamHandler.getGroupEvents)
 	authRoute.DELETE("/api/group/:id/member/:member_id", s.handler.removeGroupMember)
 	authRoute.POST("/api/group/:id/invite", s.handler.inviteUserToGroup)
 	authRoute.GET("/api/expense/:id", s.handler.getExpense)
 	authRoute.GET("/api/currencies", s.handler.getCurrencies)
-	authRoute.GET("/api/expense/:id/comments", s.handler.getExpenseComments)
+	authRoute.GET("/api/expense/:id/comments", expenseAccessCheck(s.handler.db), s.handler.getExpenseComments)
 	authRoute.POST("/api/expense/:id/comment", s.handler.createExpenseComment)
 	authRoute.DELETE("/api/expense/:id/comment/:comment_id", s.handler.deleteExpenseComment)
-	authRoute.POST("/api/expense/:id/attachment", s.handler.uploadExpenseAttachment)
-	authRoute.DELETE("/api/expense/:id/attachment/:attachment_id", s.handler.deleteExpenseAttachment)
-	authRoute.GET("/api/expense/:id/attachments", s.handler.getExpenseAttachments)
+	authRoute.POST("/api/expense/:id/comment/:comment_id/attachment", expenseAccessCheck(s.handler.db), s.handler.uploadExpenseAttachment)
+	authRoute.POST("/api/expense/:id/attachment", expenseAccessCheck(s.handler.db), s.handler.uploadExpenseAttachment)
+	authRoute.DELETE("/api/expense/:id/attachment/:attachment_id", expenseAccessCheck(s.handler.db), s.handler.deleteExpenseAttachment)
+	authRoute.GET("/api/expense/:id/attachments", expenseAccessCheck(s.handler.db), s.handler.getExpenseAttachments)
+	authRoute.GET("/api/expense/:id/attachment/:attachment_id/receipt", s.handler.getAttachmentReceipt)
+	authRoute.GET("/api/me", s.handler.getMe)
+	authRoute.PATCH("/api/me", s.handler.patchMe)
+	authRoute.DELETE("/api/me", s.handler.deleteMe)
+	authRoute.PUT("/api/me/password", s.handler.changeMePassword)
 	authRoute.GET("/api/me/setting", s.handler.getMeSetting)
 	authRoute.PATCH("/api/me/setting", s.handler.patchMeSetting)
+	authRoute.POST("/api/me/email_verification", s.handler.requestEmailVerification)
+	authRoute.GET("/api/me/identities", s.oidcHandler.getMeIdentities)
+	authRoute.POST("/api/me/identity/:provider", s.oidcHandler.linkIdentity)
+	authRoute.DELETE("/api/me/identity/:provider", s.oidcHandler.unlinkIdentity)
+	authRoute.GET("/api/me/notifications", s.handler.getMeNotifications)
+	authRoute.POST("/api/me/notifications/read", s.handler.readAllMeNotifications)
+	authRoute.POST("/api/me/notification/:notification_id/read", s.handler.readMeNotification)
+	authRoute.GET("/api/me/notification_preferences", s.handler.getMeNotificationPreferences)
+	authRoute.PUT("/api/me/notification_preferences", s.handler.putMeNotificationPreferences)
+	authRoute.GET("/api/push/vapid_public_key", s.pushHandler.getVAPIDPublicKey)
+	authRoute.GET("/api/me/push_subscriptions", s.pushHandler.getPushSubscriptions)
+	authRoute.POST("/api/me/push_subscriptions", s.pushHandler.createPushSubscription)
+	authRoute.DELETE("/api/me/push_subscriptions", s.pushHandler.deletePushSubscription)
+	authRoute.GET("/api/me/sessions", s.handler.getMeSessions)
+	authRoute.DELETE("/api/me/sessions", s.handler.deleteMeSessions)
+	authRoute.DELETE("/api/me/session/:session_id", s.handler.deleteMeSession)
+
+	adminRoute := authRoute.Group("/api/admin", adminCheck(s.admins))
+	adminRoute.GET("/outbox", s.handler.getOutbox)
+	adminRoute.POST("/outbox/:id/retry", s.handler.retryOutboxMail)
 
 	slog.Info("server start", "listen", httpSetting.Listen)
 	server := http.Server{
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+	"github.com/waylen888/tab-buddy/db/entity"
+	"github.com/waylen888/tab-buddy/server/model"
+)
+
+func (h *APIHandler) getMeSessions(ctx *gin.Context) {
+	sessions, err := h.db.GetUserSessions(GetUser(ctx).ID)
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	current := GetSession(ctx).ID
+	ctx.JSON(http.StatusOK, lo.Map(sessions, func(session entity.Session, _ int) model.Session {
+		return model.Session{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IP:         session.IP,
+			Current:    session.ID == current,
+			CreateAt:   session.CreateAt,
+			LastSeenAt: session.LastSeenAt,
+		}
+	}))
+}
+
+func (h *APIHandler) deleteMeSession(ctx *gin.Context) {
+	if err := h.db.RevokeSession(ctx.Param("session_id"), GetUser(ctx).ID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	ctx.Status(http.StatusOK)
+}
+
+// deleteMeSessions logs the user out everywhere, including the current session.
+func (h *APIHandler) deleteMeSessions(ctx *gin.Context) {
+	if err := h.db.RevokeUserSessions(GetUser(ctx).ID, ""); err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	ctx.Status(http.StatusOK)
+}
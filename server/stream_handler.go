@@ -0,0 +1,80 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/waylen888/tab-buddy/db"
+	"github.com/waylen888/tab-buddy/event"
+	"github.com/waylen888/tab-buddy/server/model"
+)
+
+// streamKeepAlive is how often a comment is sent on idle streams so proxies keep them open.
+const streamKeepAlive = time.Second * 25
+
+// StreamHandler pushes group changes to connected members as server-sent events.
+type StreamHandler struct {
+	db     db.Database
+	broker *event.Broker
+}
+
+func NewStreamHandler(db db.Database, broker *event.Broker) *StreamHandler {
+	return &StreamHandler{
+		db:     db,
+		broker: broker,
+	}
+}
+
+// getGroupEvents streams the group's events until the client goes away, the member is removed
+// or the client falls too far behind, clients reload the group when they reconnect. The
+// event name is the event type and the data a model.GroupEvent. Authentication uses the
+// Authorization header like other endpoints, so browsers read it with fetch rather than EventSource.
+func (h *StreamHandler) getGroupEvents(ctx *gin.Context) {
+	groupID := ctx.Param("id")
+	user := GetUser(ctx)
+	if _, err := h.db.GetGroup(groupID, user.ID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	stream := h.broker.Subscribe(groupID, user.ID)
+	defer h.broker.Unsubscribe(stream)
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	ctx.Header("Cache-Control", "no-cache")
+	// stop nginx from buffering the stream
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.SSEvent("ready", gin.H{"groupId": groupID})
+	ctx.Stream(func(w io.Writer) bool {
+		select {
+		case <-ctx.Request.Context().Done():
+			return false
+		case e, ok := <-stream.C:
+			if !ok {
+				return false
+			}
+			ctx.SSEvent(string(e.Type), model.GroupEvent{
+				Type:      string(e.Type),
+				GroupID:   e.GroupID,
+				ActorID:   e.ActorID,
+				ExpenseID: e.ExpenseID,
+				CommentID: e.CommentID,
+				MemberID:  e.MemberID,
+				Time:      e.Time,
+			})
+			return true
+		case <-keepAlive.C:
+			_, err := io.WriteString(w, ": keep-alive\n\n")
+			return err == nil
+		}
+	})
+}
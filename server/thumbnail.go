@@ -0,0 +1,43 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/waylen888/tab-buddy/blobstore"
+	"github.com/waylen888/tab-buddy/thumbnail"
+)
+
+// thumbnailMaxEdge is the longest edge in pixels of generated thumbnails.
+const thumbnailMaxEdge = 480
+
+// generateThumbnail stores the thumbnail of an attachment next to it and returns its size.
+func (h *APIHandler) generateThumbnail(ctx context.Context, attachmentID string) (int64, error) {
+	src, _, err := h.blobs.Get(ctx, attachmentID)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	var buf bytes.Buffer
+	if err := thumbnail.Generate(src, &buf, thumbnailMaxEdge); err != nil {
+		return 0, fmt.Errorf("generate thumbnail: %w", err)
+	}
+	size := int64(buf.Len())
+	if err := h.blobs.Put(ctx, thumbnail.Key(attachmentID), &buf, size, thumbnail.MIME); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// removeAttachmentBlobs removes the files of an attachment after its row is gone, failures are only logged.
+func (h *APIHandler) removeAttachmentBlobs(attachmentID string) {
+	for _, key := range []string{attachmentID, thumbnail.Key(attachmentID)} {
+		if err := h.blobs.Delete(context.Background(), key); err != nil && !errors.Is(err, blobstore.ErrNotExist) {
+			slog.Error("remove attachment blob", "key", key, "error", err)
+		}
+	}
+}
@@ -1,67 +1,236 @@
 package server
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/waylen888/tab-buddy/config"
 	"github.com/waylen888/tab-buddy/db"
+	"github.com/waylen888/tab-buddy/db/entity"
 )
 
-func extractBearerToken(header string) (string, error) {
-	if header == "" {
-		return "", errors.New("bad header value given")
+type TokenIssuer struct {
+	keys            map[string][]byte
+	activeKeyID     string
+	accessTokenTTL  time.Duration
+	refreshTokenTTL time.Duration
+	attachmentTTL   time.Duration
+}
+
+func NewTokenIssuer(cfg config.TokenSetting) (*TokenIssuer, error) {
+	issuer := &TokenIssuer{
+		keys:            make(map[string][]byte),
+		activeKeyID:     cfg.ActiveKeyID,
+		accessTokenTTL:  cfg.AccessTokenTTL.Duration(),
+		refreshTokenTTL: cfg.RefreshTokenTTL.Duration(),
+		attachmentTTL:   cfg.AttachmentURLTTL.Duration(),
+	}
+	for _, key := range cfg.Keys {
+		issuer.keys[key.ID] = []byte(key.Secret)
 	}
 
-	jwtToken := strings.Split(header, " ")
-	if len(jwtToken) != 2 {
-		return "", errors.New("incorrectly formatted authorization header")
+	if len(issuer.keys) == 0 {
+		// no key configured, tokens will not survive a restart
+		slog.Warn("no token key configured, using an ephemeral key")
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("generate key: %w", err)
+		}
+		issuer.activeKeyID = "ephemeral"
+		issuer.keys[issuer.activeKeyID] = secret
 	}
+	if _, ok := issuer.keys[issuer.activeKeyID]; !ok {
+		return nil, fmt.Errorf("active key %q not found", issuer.activeKeyID)
+	}
+	return issuer, nil
+}
 
-	return jwtToken[1], nil
+func (t *TokenIssuer) SignAccessToken(user entity.User, sessionID string) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"id":       user.ID,
+		"username": user.Username,
+		"sid":      sessionID,
+		"exp":      time.Now().Add(t.accessTokenTTL).Unix(),
+	})
+	token.Header["kid"] = t.activeKeyID
+	return token.SignedString(t.keys[t.activeKeyID])
 }
 
-func parseToken(jwtToken string) (*jwt.Token, error) {
+func (t *TokenIssuer) Parse(jwtToken string) (*jwt.Token, error) {
 	return jwt.Parse(jwtToken, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("bad signed method received")
 		}
-		return []byte(TOKEN_SECRET), nil
+		kid, _ := token.Header["kid"].(string)
+		key, ok := t.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+		return key, nil
 	})
 }
 
-func jwtTokenCheck(db db.Database) gin.HandlerFunc {
+// sessionTouchInterval limits how often last seen of a session is written.
+const sessionTouchInterval = time.Minute
+
+type tokenPair struct {
+	AccessToken  string
+	RefreshToken string
+}
+
+// newSession records a new login of user from the requesting device.
+func newSession(db db.Database, ctx *gin.Context, user entity.User) (entity.Session, error) {
+	return db.CreateSession(entity.CreateSessionArguments{
+		UserID:    user.ID,
+		UserAgent: ctx.Request.UserAgent(),
+		IP:        ctx.ClientIP(),
+	})
+}
+
+// issueTokenPair signs a new access token and persists a new refresh token for the user session.
+func issueTokenPair(db db.Database, issuer *TokenIssuer, user entity.User, sessionID string) (tokenPair, error) {
+	accessToken, err := issuer.SignAccessToken(user, sessionID)
+	if err != nil {
+		return tokenPair{}, fmt.Errorf("sign access token: %w", err)
+	}
+
+	refreshToken, err := newRandomToken()
+	if err != nil {
+		return tokenPair{}, fmt.Errorf("generate refresh token: %w", err)
+	}
+	_, err = db.CreateRefreshToken(entity.CreateRefreshTokenArguments{
+		UserID:    user.ID,
+		SessionID: sessionID,
+		TokenHash: hashToken(refreshToken),
+		ExpireAt:  time.Now().Add(issuer.refreshTokenTTL),
+	})
+	if err != nil {
+		return tokenPair{}, fmt.Errorf("create refresh token: %w", err)
+	}
+	return tokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// newRandomToken returns an opaque token, only its hash is meant to be stored.
+func newRandomToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// redirectWithNewSession starts a session for user and hands the tokens to the app
+// after a browser based login.
+func redirectWithNewSession(ctx *gin.Context, db db.Database, issuer *TokenIssuer, user entity.User) {
+	session, err := newSession(db, ctx, user)
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	pair, err := issueTokenPair(db, issuer, user, session.ID)
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	// in the fragment, which browsers do not send to servers, so the tokens stay out of
+	// access logs and Referer headers
+	ctx.Redirect(http.StatusFound, "/set-token#"+url.Values{
+		"access_token":  {pair.AccessToken},
+		"refresh_token": {pair.RefreshToken},
+	}.Encode())
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func extractBearerToken(header string) (string, error) {
+	if header == "" {
+		return "", errors.New("bad header value given")
+	}
+
+	jwtToken := strings.Split(header, " ")
+	if len(jwtToken) != 2 {
+		return "", errors.New("incorrectly formatted authorization header")
+	}
+
+	return jwtToken[1], nil
+}
+
+func jwtTokenCheck(db db.Database, issuer *TokenIssuer) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		jwtToken, err := extractBearerToken(ctx.GetHeader("Authorization"))
-		if err != nil {
-			ctx.AbortWithError(http.StatusUnauthorized, err)
+		if !authenticate(ctx, db, issuer) {
 			return
 		}
+		ctx.Next()
+	}
+}
 
-		token, err := parseToken(jwtToken)
-		if err != nil {
-			ctx.AbortWithError(http.StatusUnauthorized, err)
-			return
-		}
+// authenticate verifies the bearer token of the request and sets its user and session on ctx,
+// the request is aborted when it returns false.
+func authenticate(ctx *gin.Context, db db.Database, issuer *TokenIssuer) bool {
+	jwtToken, err := extractBearerToken(ctx.GetHeader("Authorization"))
+	if err != nil {
+		ctx.AbortWithError(http.StatusUnauthorized, err)
+		return false
+	}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			ctx.AbortWithError(http.StatusUnauthorized, err)
-			return
-		}
-		username, ok := claims["username"].(string)
-		if !ok {
-			ctx.AbortWithError(http.StatusUnauthorized, err)
-			return
-		}
-		user, err := db.GetUserByUsername(username)
-		if err != nil {
-			ctx.AbortWithError(http.StatusForbidden, err)
-			return
+	token, err := issuer.Parse(jwtToken)
+	if err != nil {
+		ctx.AbortWithError(http.StatusUnauthorized, err)
+		return false
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		ctx.AbortWithError(http.StatusUnauthorized, err)
+		return false
+	}
+	username, ok := claims["username"].(string)
+	if !ok {
+		ctx.AbortWithError(http.StatusUnauthorized, err)
+		return false
+	}
+	sessionID, ok := claims["sid"].(string)
+	if !ok {
+		ctx.AbortWithError(http.StatusUnauthorized, errors.New("missing session"))
+		return false
+	}
+	user, err := db.GetUserByUsername(username)
+	if err != nil {
+		ctx.AbortWithError(http.StatusForbidden, err)
+		return false
+	}
+	session, err := db.GetSession(sessionID)
+	if err != nil {
+		ctx.AbortWithError(http.StatusUnauthorized, err)
+		return false
+	}
+	if session.UserID != user.ID || session.RevokeAt != nil {
+		ctx.AbortWithError(http.StatusUnauthorized, errors.New("session revoked"))
+		return false
+	}
+	if now := time.Now(); now.Sub(session.LastSeenAt) > sessionTouchInterval {
+		if err := db.TouchSession(session.ID, ctx.ClientIP(), now); err != nil {
+			slog.Error("touch session", "error", err)
 		}
-		ctx.Set("user", user)
-		ctx.Next()
 	}
+	ctx.Set("user", user)
+	ctx.Set("session", session)
+	return true
 }
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/waylen888/tab-buddy/config"
+	"github.com/waylen888/tab-buddy/db/entity"
+)
+
+func newTestTokenIssuer(t *testing.T, activeKeyID string, keys ...config.TokenKey) *TokenIssuer {
+	t.Helper()
+	issuer, err := NewTokenIssuer(config.TokenSetting{
+		Keys:           keys,
+		ActiveKeyID:    activeKeyID,
+		AccessTokenTTL: config.Duration(15 * time.Minute),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return issuer
+}
+
+func TestTokenIssuerKeyRotation(t *testing.T) {
+	oldKey := config.TokenKey{ID: "2024-01", Secret: "old secret"}
+	newKey := config.TokenKey{ID: "2024-06", Secret: "new secret"}
+	user := entity.User{ID: "user", Username: "alice"}
+
+	before := newTestTokenIssuer(t, oldKey.ID, oldKey)
+	oldToken, err := before.SignAccessToken(user, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the new key signs, the old one still verifies tokens issued before the rotation
+	during := newTestTokenIssuer(t, newKey.ID, oldKey, newKey)
+	newToken, err := during.SignAccessToken(user, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := during.Parse(newToken)
+	if err != nil {
+		t.Fatalf("parse new token: %v", err)
+	}
+	if kid := token.Header["kid"]; kid != newKey.ID {
+		t.Errorf("new token kid = %v, want %s", kid, newKey.ID)
+	}
+	if claims := token.Claims.(jwt.MapClaims); claims["id"] != user.ID || claims["sid"] != "session" {
+		t.Errorf("claims = %v", claims)
+	}
+	if _, err := during.Parse(oldToken); err != nil {
+		t.Errorf("parse old token during rotation: %v", err)
+	}
+
+	// once the old key is retired its tokens are rejected
+	after := newTestTokenIssuer(t, newKey.ID, newKey)
+	if _, err := after.Parse(oldToken); err == nil {
+		t.Error("old token accepted after its key was removed")
+	}
+	if _, err := after.Parse(newToken); err != nil {
+		t.Errorf("parse new token after rotation: %v", err)
+	}
+}
+
+func TestTokenIssuerRejectsForgedKeyID(t *testing.T) {
+	issuer := newTestTokenIssuer(t, "a", config.TokenKey{ID: "a", Secret: "secret a"}, config.TokenKey{ID: "b", Secret: "secret b"})
+	// signed with the secret of a but claiming to be b
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"id": "user"})
+	token.Header["kid"] = "b"
+	forged, err := token.SignedString([]byte("secret a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := issuer.Parse(forged); err == nil {
+		t.Error("token with the wrong kid accepted")
+	}
+	token.Header["kid"] = "unknown"
+	unknown, err := token.SignedString([]byte("secret a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := issuer.Parse(unknown); err == nil {
+		t.Error("token with an unknown kid accepted")
+	}
+}
+
+func TestNewTokenIssuerActiveKeyMissing(t *testing.T) {
+	_, err := NewTokenIssuer(config.TokenSetting{
+		Keys:        []config.TokenKey{{ID: "a", Secret: "secret"}},
+		ActiveKeyID: "b",
+	})
+	if err == nil {
+		t.Error("issuer created without its active key")
+	}
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/h2non/filetype"
+	"github.com/samber/lo"
+	"github.com/waylen888/tab-buddy/server/model"
+)
+
+// maxAttachmentsPerUpload bounds the size of an upload request together with the max file size.
+const maxAttachmentsPerUpload = 10
+
+// mimeAliases maps content types sent by clients to the ones detected from the content.
+var mimeAliases = map[string]string{
+	"image/jpg":   "image/jpeg",
+	"image/pjpeg": "image/jpeg",
+	"image/heic":  "image/heif",
+}
+
+// uploadError is a rejected upload, reported to the client as is.
+type uploadError struct {
+	status  int
+	message string
+}
+
+func (e *uploadError) Error() string {
+	return e.message
+}
+
+func abortWithUploadError(ctx *gin.Context, err error) {
+	var uploadErr *uploadError
+	if !errors.As(err, &uploadErr) {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	ctx.Error(err)
+	ctx.AbortWithStatusJSON(uploadErr.status, gin.H{"error": uploadErr.message})
+}
+
+type uploadFile struct {
+	header *multipart.FileHeader
+	size   int64
+	mime   string
+}
+
+func (h *APIHandler) maxUploadRequestSize() int64 {
+	// leave room for the multipart headers
+	return h.attachments.MaxFileSize*maxAttachmentsPerUpload + 1<<20
+}
+
+// validateUpload checks the size and the content type detected from the content of an uploaded file.
+func (h *APIHandler) validateUpload(fileHeader *multipart.FileHeader) (uploadFile, error) {
+	file, err := fileHeader.Open()
+	if err != nil {
+		return uploadFile{}, err
+	}
+	defer file.Close()
+
+	size, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return uploadFile{}, err
+	}
+	if size > h.attachments.MaxFileSize {
+		return uploadFile{}, &uploadError{http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("%s is larger than %d bytes", fileHeader.Filename, h.attachments.MaxFileSize)}
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return uploadFile{}, err
+	}
+
+	// filetype only needs the first 261 bytes
+	head := make([]byte, 261)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return uploadFile{}, err
+	}
+	ftype, err := filetype.Match(head[:n])
+	if err != nil {
+		return uploadFile{}, err
+	}
+	if ftype == filetype.Unknown {
+		return uploadFile{}, &uploadError{http.StatusUnsupportedMediaType,
+			fmt.Sprintf("the type of %s is not recognized", fileHeader.Filename)}
+	}
+	detected := ftype.MIME.Value
+	if !lo.Contains(h.attachments.AllowedMIMETypes, detected) {
+		return uploadFile{}, &uploadError{http.StatusUnsupportedMediaType,
+			fmt.Sprintf("%s is %s, which is not allowed", fileHeader.Filename, detected)}
+	}
+
+	if claimed, _, err := mime.ParseMediaType(fileHeader.Header.Get("Content-Type")); err == nil && claimed != "application/octet-stream" {
+		if alias, ok := mimeAliases[claimed]; ok {
+			claimed = alias
+		}
+		if claimed != detected {
+			return uploadFile{}, &uploadError{http.StatusBadRequest,
+				fmt.Sprintf("%s was sent as %s but its content is %s", fileHeader.Filename, claimed, detected)}
+		}
+	}
+	return uploadFile{header: fileHeader, size: size, mime: detected}, nil
+}
+
+// checkGroupQuota rejects storing size more bytes when the group would go over its quota.
+func (h *APIHandler) checkGroupQuota(groupID string, size int64) error {
+	if h.attachments.GroupQuota == 0 {
+		return nil
+	}
+	usage, err := h.db.GetGroupAttachmentUsage(groupID)
+	if err != nil {
+		return fmt.Errorf("get attachment usage: %w", err)
+	}
+	if usage.Bytes+size > h.attachments.GroupQuota {
+		return &uploadError{http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("the group has used %d of %d bytes", usage.Bytes, h.attachments.GroupQuota)}
+	}
+	return nil
+}
+
+func (h *APIHandler) getGroupStorage(ctx *gin.Context) {
+	group, err := h.db.GetGroup(ctx.Param("id"), GetUser(ctx).ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.AbortWithStatus(http.StatusNotFound)
+		} else {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+		}
+		return
+	}
+	usage, err := h.db.GetGroupAttachmentUsage(group.ID)
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, model.StorageUsage{
+		Attachments: usage.Count,
+		Used:        usage.Bytes,
+		Quota:       h.attachments.GroupQuota,
+	})
+}
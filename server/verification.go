@@ -0,0 +1,203 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/waylen888/tab-buddy/db"
+	"github.com/waylen888/tab-buddy/db/entity"
+)
+
+const (
+	emailVerificationTTL = time.Hour * 24
+	passwordResetTTL     = time.Hour
+	// at most userTokenRateLimit tokens of the same purpose are sent to an email within userTokenRateWindow
+	userTokenRateLimit  = 3
+	userTokenRateWindow = time.Hour
+)
+
+var errUserTokenRateLimited = errors.New("too many requests for this email")
+
+// createUserToken creates a single-use token for user and returns its plain value.
+func (h *APIHandler) createUserToken(user entity.User, purpose entity.UserTokenPurpose, ttl time.Duration) (string, error) {
+	count, err := h.db.CountUserTokensSince(purpose, user.Email, time.Now().Add(-userTokenRateWindow))
+	if err != nil {
+		return "", fmt.Errorf("count user tokens: %w", err)
+	}
+	if count >= userTokenRateLimit {
+		return "", errUserTokenRateLimited
+	}
+
+	token, err := newRandomToken()
+	if err != nil {
+		return "", fmt.Errorf("generate token: %w", err)
+	}
+	_, err = h.db.CreateUserToken(entity.CreateUserTokenArguments{
+		UserID:    user.ID,
+		Purpose:   purpose,
+		Email:     user.Email,
+		TokenHash: hashToken(token),
+		ExpireAt:  time.Now().Add(ttl),
+	})
+	if err != nil {
+		return "", fmt.Errorf("create user token: %w", err)
+	}
+	return token, nil
+}
+
+// consumeUserToken validates a token and marks it used so it can not be used again.
+func (h *APIHandler) consumeUserToken(purpose entity.UserTokenPurpose, token string) (entity.UserToken, error) {
+	userToken, err := h.db.GetUserToken(purpose, hashToken(token))
+	if err != nil {
+		return entity.UserToken{}, err
+	}
+	if userToken.UseAt != nil {
+		return entity.UserToken{}, db.ErrTokenAlreadyUsed
+	}
+	if time.Now().After(userToken.ExpireAt) {
+		return entity.UserToken{}, errors.New("token expired")
+	}
+	if err := h.db.UseUserToken(userToken.ID); err != nil {
+		return entity.UserToken{}, err
+	}
+	return userToken, nil
+}
+
+func (h *APIHandler) sendEmailVerification(user entity.User) error {
+	token, err := h.createUserToken(user, entity.UserTokenPurposeEmailVerification, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+	link := h.publicURL + "/verify-email?" + url.Values{"token": {token}}.Encode()
+	return h.sendMail("email_verification", []string{user.Email}, map[string]string{
+		"DisplayName": user.DisplayName,
+		"Link":        link,
+	})
+}
+
+func (h *APIHandler) requestEmailVerification(ctx *gin.Context) {
+	user := GetUser(ctx)
+	if user.Email == "" {
+		ctx.AbortWithError(http.StatusBadRequest, errors.New("email is empty"))
+		return
+	}
+	if user.EmailVerifiedAt != nil {
+		ctx.Status(http.StatusOK)
+		return
+	}
+	if err := h.sendEmailVerification(user); err != nil {
+		if errors.Is(err, errUserTokenRateLimited) {
+			ctx.AbortWithError(http.StatusTooManyRequests, err)
+			return
+		}
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	ctx.Status(http.StatusOK)
+}
+
+func (h *APIHandler) confirmEmailVerification(ctx *gin.Context) {
+	var req struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	userToken, err := h.consumeUserToken(entity.UserTokenPurposeEmailVerification, req.Token)
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+	if err := h.db.SetUserEmailVerified(userToken.UserID, userToken.Email, time.Now()); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// email was changed after the token was sent
+			ctx.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	ctx.Status(http.StatusOK)
+}
+
+func (h *APIHandler) requestPasswordReset(ctx *gin.Context) {
+	var req struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	users, err := h.db.GetUsersByEmail(req.Email)
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	for _, user := range users {
+		// users created by google have no password to reset
+		if user.CreateType != entity.UserCreateTypeDefault {
+			continue
+		}
+		token, err := h.createUserToken(user, entity.UserTokenPurposePasswordReset, passwordResetTTL)
+		if err != nil {
+			if errors.Is(err, errUserTokenRateLimited) {
+				// answer as usual, a 429 would reveal the email is registered
+				slog.Warn("password reset rate limited", "email", user.Email)
+				continue
+			}
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		link := h.publicURL + "/reset-password?" + url.Values{"token": {token}}.Encode()
+		err = h.sendMail("password_reset", []string{user.Email}, map[string]string{
+			"DisplayName": user.DisplayName,
+			"Username":    user.Username,
+			"Link":        link,
+		})
+		if err != nil {
+			slog.Error("send password reset", "error", err)
+		}
+	}
+
+	// always succeed, so the response does not reveal which emails are registered
+	ctx.Status(http.StatusOK)
+}
+
+func (h *APIHandler) confirmPasswordReset(ctx *gin.Context) {
+	var req struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	userToken, err := h.consumeUserToken(entity.UserTokenPurposePasswordReset, req.Token)
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+	if err := h.db.UpdateUserPassword(userToken.UserID, req.Password); err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	// the mailbox is proven to be the user's, and whoever knew the old password is logged out
+	if err := h.db.SetUserEmailVerified(userToken.UserID, userToken.Email, time.Now()); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		slog.Error("set email verified", "error", err)
+	}
+	if err := h.db.RevokeUserSessions(userToken.UserID, ""); err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	ctx.Status(http.StatusOK)
+}
@@ -0,0 +1,331 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	netmail "net/mail"
+	"net/textproto"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/waylen888/tab-buddy/config"
+	"github.com/waylen888/tab-buddy/db"
+	"github.com/waylen888/tab-buddy/db/entity"
+	"github.com/waylen888/tab-buddy/db/sqlite"
+	"github.com/waylen888/tab-buddy/mail"
+)
+
+// fakeSMTP accepts every mail and hands its raw message to mails. It speaks just enough
+// SMTP for net/smtp without auth or TLS.
+type fakeSMTP struct {
+	listener net.Listener
+	mails    chan string
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	server := &fakeSMTP{listener: listener, mails: make(chan string, 10)}
+	go server.serve()
+	return server
+}
+
+func (s *fakeSMTP) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeSMTP) handle(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, _, _ := strings.Cut(strings.ToUpper(line), " ")
+		switch verb {
+		case "EHLO", "HELO", "MAIL", "RCPT", "RSET", "NOOP":
+			text.PrintfLine("250 OK")
+		case "DATA":
+			text.PrintfLine("354 go ahead")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.mails <- string(data)
+			text.PrintfLine("250 OK")
+		case "QUIT":
+			text.PrintfLine("221 bye")
+			return
+		default:
+			text.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+// receive waits for the next mail and returns its recipients, decoded subject and plain text body.
+func (s *fakeSMTP) receive(t *testing.T) (to string, subject string, text string) {
+	t.Helper()
+	var raw string
+	select {
+	case raw = <-s.mails:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no mail was sent")
+	}
+	msg, err := netmail.ReadMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject, err = new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject")); err != nil {
+		t.Fatal(err)
+	}
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the first part is the plain text, the reader undoes the quoted-printable encoding
+	part, err := multipart.NewReader(msg.Body, params["boundary"]).NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(part)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg.Header.Get("To"), subject, string(body)
+}
+
+// expectNoMail fails when a mail arrives within a short wait.
+func (s *fakeSMTP) expectNoMail(t *testing.T) {
+	t.Helper()
+	select {
+	case <-s.mails:
+		t.Fatal("unexpected mail")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+const testPublicURL = "https://tab.example.com"
+
+// newVerificationTest serves the verification endpoints from a fresh database. Requests
+// with an X-Username header are made as that user.
+func newVerificationTest(t *testing.T) (*gin.Engine, db.Database, *fakeSMTP) {
+	gin.SetMode(gin.TestMode)
+	database, err := sqlite.New(context.Background(), filepath.Join(t.TempDir(), "test.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	smtpServer := newFakeSMTP(t)
+	outbox := mail.NewOutbox(database, mail.NewSender(config.SMTPSetting{
+		Host: smtpServer.listener.Addr().String(),
+		From: "Tab Buddy <noreply@example.com>",
+		TLS:  mail.TLSNone,
+	}), config.OutboxSetting{
+		MaxAttempts: 3,
+		BaseDelay:   config.Duration(time.Second),
+		MaxDelay:    config.Duration(time.Minute),
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		outbox.Run(ctx)
+		close(done)
+	}()
+	// stop sending before the database is closed
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	handler, err := NewAPIHandler(database, nil, nil, config.AttachmentSetting{}, nil, outbox, nil, nil, nil, nil, testPublicURL+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := gin.New()
+	engine.POST("/api/auth/password_reset", handler.requestPasswordReset)
+	engine.POST("/api/auth/password_reset/confirm", handler.confirmPasswordReset)
+	engine.POST("/api/auth/email_verification/confirm", handler.confirmEmailVerification)
+	engine.POST("/api/me/email_verification", func(ctx *gin.Context) {
+		user, err := database.GetUserByUsername(ctx.GetHeader("X-Username"))
+		if err != nil {
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		ctx.Set("user", user)
+	}, handler.requestEmailVerification)
+	return engine, database, smtpServer
+}
+
+func post(engine *gin.Engine, path string, username string, body any) int {
+	data, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	if username != "" {
+		req.Header.Set("X-Username", username)
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w.Code
+}
+
+// linkToken returns the token of the link to path in a mail body.
+func linkToken(t *testing.T, text string, path string) string {
+	t.Helper()
+	match := regexp.MustCompile(regexp.QuoteMeta(testPublicURL+path) + `\?token=(\S+)`).FindStringSubmatch(text)
+	if match == nil {
+		t.Fatalf("no %s link in mail:\n%s", path, text)
+	}
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestPasswordReset(t *testing.T) {
+	engine, database, smtpServer := newVerificationTest(t)
+	user, err := database.CreateUser("alice", "Alice", "alice@example.com", "old password", entity.UserCreateTypeDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code := post(engine, "/api/auth/password_reset", "", gin.H{"email": "alice@example.com"}); code != http.StatusOK {
+		t.Fatalf("request reset: status %d", code)
+	}
+	to, subject, text := smtpServer.receive(t)
+	if to != "alice@example.com" {
+		t.Errorf("mail sent to %q", to)
+	}
+	if subject == "" {
+		t.Error("mail has no subject")
+	}
+	token := linkToken(t, text, "/reset-password")
+
+	if code := post(engine, "/api/auth/password_reset/confirm", "", gin.H{"token": "wrong", "password": "new password"}); code != http.StatusBadRequest {
+		t.Errorf("unknown token: status %d, want %d", code, http.StatusBadRequest)
+	}
+	if code := post(engine, "/api/auth/password_reset/confirm", "", gin.H{"token": token, "password": "new password"}); code != http.StatusOK {
+		t.Fatalf("confirm reset: status %d", code)
+	}
+	if code := post(engine, "/api/auth/password_reset/confirm", "", gin.H{"token": token, "password": "other password"}); code != http.StatusBadRequest {
+		t.Errorf("reused token: status %d, want %d", code, http.StatusBadRequest)
+	}
+
+	user, err = database.GetUser(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := user.CheckPassword("new password"); err != nil {
+		t.Errorf("new password rejected: %v", err)
+	}
+	if user.EmailVerifiedAt == nil {
+		t.Error("reset did not verify the email")
+	}
+}
+
+func TestPasswordResetUnknownEmail(t *testing.T) {
+	engine, _, smtpServer := newVerificationTest(t)
+	// the answer must not reveal whether the email is registered
+	if code := post(engine, "/api/auth/password_reset", "", gin.H{"email": "nobody@example.com"}); code != http.StatusOK {
+		t.Fatalf("status %d, want %d", code, http.StatusOK)
+	}
+	smtpServer.expectNoMail(t)
+}
+
+func TestPasswordResetRateLimit(t *testing.T) {
+	engine, database, smtpServer := newVerificationTest(t)
+	if _, err := database.CreateUser("alice", "Alice", "alice@example.com", "password", entity.UserCreateTypeDefault); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < userTokenRateLimit; i++ {
+		if code := post(engine, "/api/auth/password_reset", "", gin.H{"email": "alice@example.com"}); code != http.StatusOK {
+			t.Fatalf("request %d: status %d", i+1, code)
+		}
+		smtpServer.receive(t)
+	}
+	if code := post(engine, "/api/auth/password_reset", "", gin.H{"email": "alice@example.com"}); code != http.StatusOK {
+		t.Fatalf("rate limited request: status %d, want %d", code, http.StatusOK)
+	}
+	smtpServer.expectNoMail(t)
+}
+
+func TestEmailVerification(t *testing.T) {
+	engine, database, smtpServer := newVerificationTest(t)
+	user, err := database.CreateUser("bob", "Bob", "bob@example.com", "password", entity.UserCreateTypeDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code := post(engine, "/api/me/email_verification", "bob", nil); code != http.StatusOK {
+		t.Fatalf("request verification: status %d", code)
+	}
+	to, _, text := smtpServer.receive(t)
+	if to != "bob@example.com" {
+		t.Errorf("mail sent to %q", to)
+	}
+	token := linkToken(t, text, "/verify-email")
+
+	// a password reset token is not a verification token
+	if code := post(engine, "/api/auth/password_reset/confirm", "", gin.H{"token": token, "password": "new password"}); code != http.StatusBadRequest {
+		t.Errorf("verification token used for a reset: status %d, want %d", code, http.StatusBadRequest)
+	}
+	if code := post(engine, "/api/auth/email_verification/confirm", "", gin.H{"token": token}); code != http.StatusOK {
+		t.Fatalf("confirm verification: status %d", code)
+	}
+	if code := post(engine, "/api/auth/email_verification/confirm", "", gin.H{"token": token}); code != http.StatusBadRequest {
+		t.Errorf("reused token: status %d, want %d", code, http.StatusBadRequest)
+	}
+	user, err = database.GetUser(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.EmailVerifiedAt == nil {
+		t.Error("email is not verified")
+	}
+
+	// a verified email is not sent another link
+	if code := post(engine, "/api/me/email_verification", "bob", nil); code != http.StatusOK {
+		t.Fatalf("request again: status %d", code)
+	}
+	smtpServer.expectNoMail(t)
+}
+
+func TestEmailVerificationRateLimit(t *testing.T) {
+	engine, database, smtpServer := newVerificationTest(t)
+	if _, err := database.CreateUser("bob", "Bob", "bob@example.com", "password", entity.UserCreateTypeDefault); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < userTokenRateLimit; i++ {
+		if code := post(engine, "/api/me/email_verification", "bob", nil); code != http.StatusOK {
+			t.Fatalf("request %d: status %d", i+1, code)
+		}
+		smtpServer.receive(t)
+	}
+	if code := post(engine, "/api/me/email_verification", "bob", nil); code != http.StatusTooManyRequests {
+		t.Errorf("status %d, want %d", code, http.StatusTooManyRequests)
+	}
+}
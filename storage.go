@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/waylen888/tab-buddy/blobstore"
+	"github.com/waylen888/tab-buddy/config"
+	"github.com/waylen888/tab-buddy/db"
+	"github.com/waylen888/tab-buddy/thumbnail"
+)
+
+// migrateStorage copies every attachment from one storage backend to another. Only keys
+// of attachment rows and their thumbnails are copied, other files in the source stay put,
+// usage: tabbuddy migrate-storage -from local -to s3 [-delete]
+func migrateStorage(ctx context.Context, cfg config.Config, database db.Database, args []string) error {
+	flags := flag.NewFlagSet("migrate-storage", flag.ContinueOnError)
+	from := flags.String("from", blobstore.BackendLocal, "source backend")
+	to := flags.String("to", cfg.Storage.Backend, "destination backend")
+	deleteSource := flags.Bool("delete", false, "delete files from the source after copying")
+	dryRun := flags.Bool("dry-run", false, "only report what would be copied")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *from == *to {
+		return fmt.Errorf("source and destination are both %q", *from)
+	}
+
+	src, err := blobstore.Open(cfg.Storage, *from)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", *from, err)
+	}
+	dst, err := blobstore.Open(cfg.Storage, *to)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", *to, err)
+	}
+	ids, err := database.GetAttachmentIDs()
+	if err != nil {
+		return fmt.Errorf("get attachment ids: %w", err)
+	}
+
+	var copied, skipped int
+	for _, id := range ids {
+		for _, key := range []string{id, thumbnail.Key(id)} {
+			info, err := src.Stat(ctx, key)
+			if errors.Is(err, blobstore.ErrNotExist) {
+				continue
+			} else if err != nil {
+				return fmt.Errorf("stat %s: %w", key, err)
+			}
+			// already migrated by an earlier, interrupted run
+			if existing, err := dst.Stat(ctx, key); err == nil && existing.Size == info.Size {
+				skipped++
+			} else if err != nil && !errors.Is(err, blobstore.ErrNotExist) {
+				return fmt.Errorf("stat %s: %w", key, err)
+			} else if *dryRun {
+				slog.Info("would copy", "key", key, "size", info.Size)
+				copied++
+				continue
+			} else {
+				if err := copyBlob(ctx, src, dst, key); err != nil {
+					return fmt.Errorf("copy %s: %w", key, err)
+				}
+				copied++
+			}
+			if *deleteSource && !*dryRun {
+				if err := src.Delete(ctx, key); err != nil {
+					return fmt.Errorf("delete %s: %w", key, err)
+				}
+			}
+		}
+	}
+	slog.Info("migrate storage", "from", *from, "to", *to, "copied", copied, "skipped", skipped)
+	return nil
+}
+
+func copyBlob(ctx context.Context, src, dst blobstore.Store, key string) error {
+	r, info, err := src.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return dst.Put(ctx, key, r, info.Size, "")
+}
+
+// moveLocalAttachments moves attachments stored straight in data_dir, where the local
+// backend used to keep them, into the local storage dir. Only files named after an
+// attachment row or its thumbnail are moved, anything else in data_dir stays put.
+func moveLocalAttachments(cfg config.Config, database db.Database) error {
+	dir := cfg.Storage.Local.Dir
+	if dir == cfg.DataDir {
+		return nil
+	}
+	ids, err := database.GetAttachmentIDs()
+	if err != nil {
+		return fmt.Errorf("get attachment ids: %w", err)
+	}
+	var moved int
+	for _, id := range ids {
+		for _, key := range []string{id, thumbnail.Key(id)} {
+			if key != filepath.Base(key) {
+				continue
+			}
+			src := filepath.Join(cfg.DataDir, key)
+			if info, err := os.Stat(src); errors.Is(err, fs.ErrNotExist) || (err == nil && !info.Mode().IsRegular()) {
+				continue
+			} else if err != nil {
+				return err
+			}
+			dst := filepath.Join(dir, key)
+			if _, err := os.Stat(dst); err == nil {
+				slog.Warn("attachment is in both data dir and storage dir, keeping the latter", "key", key)
+				continue
+			} else if !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+			if moved == 0 {
+				if err := os.MkdirAll(dir, 0755); err != nil {
+					return fmt.Errorf("create storage dir: %w", err)
+				}
+			}
+			if err := os.Rename(src, dst); err != nil {
+				return fmt.Errorf("move %s: %w", key, err)
+			}
+			moved++
+		}
+	}
+	if moved > 0 {
+		slog.Info("moved attachments to storage dir", "dir", dir, "count", moved)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"strings"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// MIME is the content type of generated thumbnails.
+const MIME = "image/jpeg"
+
+// MaxPixels is the largest width*height decoded. A small, highly compressed image can decode
+// to gigabytes, larger images get no thumbnail.
+const MaxPixels = 40_000_000
+
+// ErrTooLarge is returned by Generate for images of more than MaxPixels.
+var ErrTooLarge = errors.New("image too large for a thumbnail")
+
+// keySuffix is appended to the attachment key to store its thumbnail.
+const keySuffix = "-thumbnail"
+
+// Key is the storage key of the thumbnail of an attachment.
+func Key(attachmentID string) string {
+	return attachmentID + keySuffix
+}
+
+// AttachmentID returns the attachment of a thumbnail storage key, ok is false for other keys.
+func AttachmentID(key string) (attachmentID string, ok bool) {
+	return strings.CutSuffix(key, keySuffix)
+}
+
+// Supported reports whether a thumbnail can be generated for the content type.
+func Supported(mime string) bool {
+	switch mime {
+	case "image/jpeg", "image/png", "image/webp":
+		return true
+	}
+	return false
+}
+
+// Generate decodes an image from r and writes it to w as a jpeg that fits
+// within maxEdge pixels, rotated upright according to its EXIF orientation.
+// Images of more than MaxPixels are not decoded, ErrTooLarge is returned.
+func Generate(r io.Reader, w io.Writer, maxEdge int) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("read image: %w", err)
+	}
+	// the header alone gives the size, check it before allocating the pixels
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("decode image config: %w", err)
+	}
+	if int64(config.Width)*int64(config.Height) > MaxPixels {
+		return fmt.Errorf("%w: %dx%d", ErrTooLarge, config.Width, config.Height)
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("decode image: %w", err)
+	}
+
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > maxEdge || height > maxEdge {
+		if width >= height {
+			width, height = maxEdge, max(1, height*maxEdge/width)
+		} else {
+			width, height = max(1, width*maxEdge/height), maxEdge
+		}
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Src, nil)
+
+	return jpeg.Encode(w, orient(dst, exifOrientation(data)), &jpeg.Options{Quality: 80})
+}
+
+// orient applies an EXIF orientation (1-8) to img.
+func orient(img *image.RGBA, orientation int) *image.RGBA {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	// orientations 5-8 swap width and height
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirror horizontal
+				dx, dy = w-1-x, y
+			case 3: // rotate 180
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirror vertical
+				dx, dy = x, h-1-y
+			case 5: // transpose
+				dx, dy = y, x
+			case 6: // rotate 90 clockwise
+				dx, dy = h-1-y, x
+			case 7: // transverse
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotate 90 counter clockwise
+				dx, dy = y, w-1-x
+			}
+			dst.SetRGBA(dx, dy, img.RGBAAt(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
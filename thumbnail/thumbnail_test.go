@@ -0,0 +1,56 @@
+package thumbnail
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// pngHeader returns the start of a PNG claiming width x height pixels, enough for image.DecodeConfig.
+func pngHeader(width, height uint32) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], width)
+	binary.BigEndian.PutUint32(ihdr[4:], height)
+	ihdr[8], ihdr[9] = 8, 2 // 8 bit RGB
+	chunk := append([]byte("IHDR"), ihdr...)
+	binary.Write(&buf, binary.BigEndian, uint32(len(ihdr)))
+	buf.Write(chunk)
+	binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(chunk))
+	return buf.Bytes()
+}
+
+func TestGenerateRefusesLargeImages(t *testing.T) {
+	err := Generate(bytes.NewReader(pngHeader(10000, 10000)), &bytes.Buffer{}, 320)
+	if !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("Generate() error = %v, want ErrTooLarge", err)
+	}
+}
+
+func TestGenerateFitsMaxEdge(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	for x := 0; x < 400; x++ {
+		src.Set(x, x%200, color.White)
+	}
+	var in, out bytes.Buffer
+	if err := png.Encode(&in, src); err != nil {
+		t.Fatal(err)
+	}
+	if err := Generate(&in, &out, 100); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	config, err := jpeg.DecodeConfig(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Width != 100 || config.Height != 50 {
+		t.Errorf("thumbnail is %dx%d, want 100x50", config.Width, config.Height)
+	}
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/waylen888/tab-buddy/webpush"
+)
+
+// printVAPIDKeys generates the keys web push needs, in the format of the config file,
+// usage: tabbuddy vapid-keys
+func printVAPIDKeys() error {
+	keys, err := webpush.GenerateKeys()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("[web_push]\nvapid_public_key = %q\nvapid_private_key = %q\nsubject = \"mailto:admin@example.com\"\n", keys.PublicKey, keys.PrivateKey)
+	return nil
+}
@@ -0,0 +1,98 @@
+package webpush
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	recordSize = 4096
+	// headerSize is the salt, the record size, the key id length and the server public key.
+	headerSize = 16 + 4 + 1 + 65
+	// maxPayload is what fits in a single record next to the delimiter and the AEAD tag,
+	// push services accept at most 4096 bytes of body including the header.
+	maxPayload = recordSize - headerSize - 16 - 1
+)
+
+var ErrPayloadTooLarge = errors.New("payload too large")
+
+// encrypt encrypts payload for the user agent with the aes128gcm content coding (RFC 8291, RFC 8188).
+// userPublicKey is the subscription's p256dh key and authSecret its auth key.
+func encrypt(payload, userPublicKey, authSecret []byte) ([]byte, error) {
+	serverKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return encryptWith(payload, userPublicKey, authSecret, serverKey, salt)
+}
+
+func encryptWith(payload, userPublicKey, authSecret []byte, serverKey *ecdh.PrivateKey, salt []byte) ([]byte, error) {
+	if len(payload) > maxPayload {
+		return nil, ErrPayloadTooLarge
+	}
+	userKey, err := ecdh.P256().NewPublicKey(userPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	sharedSecret, err := serverKey.ECDH(userKey)
+	if err != nil {
+		return nil, err
+	}
+	serverPublicKey := serverKey.PublicKey().Bytes()
+
+	// key_info = "WebPush: info" || 0x00 || ua_public || as_public
+	keyInfo := append([]byte("WebPush: info\x00"), userPublicKey...)
+	keyInfo = append(keyInfo, serverPublicKey...)
+	ikm, err := derive(sharedSecret, authSecret, keyInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+	cek, err := derive(ikm, salt, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := derive(ikm, salt, []byte("Content-Encoding: nonce\x00"), 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// header: salt || rs || idlen || keyid
+	header := make([]byte, 0, 16+4+1+len(serverPublicKey))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, recordSize)
+	header = append(header, byte(len(serverPublicKey)))
+	header = append(header, serverPublicKey...)
+
+	// a single record, terminated by the last record delimiter and no padding
+	plaintext := append(append([]byte{}, payload...), 0x02)
+	return gcm.Seal(header, nonce, plaintext, nil), nil
+}
+
+func derive(secret, salt, info []byte, length int) ([]byte, error) {
+	out := make([]byte, length)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, info), out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,63 @@
+package webpush
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+// TestEncryptRFC8291 checks encryptWith against the example in RFC 8291 Appendix A.
+func TestEncryptRFC8291(t *testing.T) {
+	decode := func(s string) []byte {
+		t.Helper()
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	serverKey, err := ecdh.P256().NewPrivateKey(decode("yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := serverKey.PublicKey().Bytes(), decode("BP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A8"); !bytes.Equal(got, want) {
+		t.Fatalf("server public key %x, want %x", got, want)
+	}
+
+	got, err := encryptWith(
+		[]byte("When I grow up, I want to be a watermelon"),
+		decode("BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4"),
+		decode("BTBZMqHH6r4Tts7J_aSIgg"),
+		serverKey,
+		decode("DGv6ra1nlYgDCS1FRnbzlw"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := decode("DGv6ra1nlYgDCS1FRnbzlwAAEABBBP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A_yl95bQpu6cVPTpK4Mqgkf1CXztLVBSt2Ks3oZwbuwXPXLWyouBWLVWGNWQexSgSxsj_Qulcy4a-fN")
+	if !bytes.Equal(got, want) {
+		t.Errorf("encrypted body\n%s\nwant\n%s", base64.RawURLEncoding.EncodeToString(got), base64.RawURLEncoding.EncodeToString(want))
+	}
+}
+
+func TestEncryptPayloadTooLarge(t *testing.T) {
+	userKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := encrypt(make([]byte, maxPayload), userKey.PublicKey().Bytes(), make([]byte, 16))
+	if err != nil {
+		t.Errorf("largest payload: %v", err)
+	}
+	// push services take at most 4096 bytes of body
+	if len(body) != 4096 {
+		t.Errorf("body of the largest payload has %d bytes, want 4096", len(body))
+	}
+	_, err = encrypt(make([]byte, maxPayload+1), userKey.PublicKey().Bytes(), make([]byte, 16))
+	if !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("err %v, want %v", err, ErrPayloadTooLarge)
+	}
+}
@@ -0,0 +1,174 @@
+package webpush
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdh"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrGone is returned when the push service no longer knows the subscription, it should be deleted.
+var ErrGone = errors.New("push subscription expired or unsubscribed")
+
+// Subscription is a browser PushSubscription, the keys are base64url encoded.
+type Subscription struct {
+	Endpoint string
+	P256dh   string
+	Auth     string
+}
+
+type Options struct {
+	// TTL is how long the push service keeps the message while the browser is offline.
+	TTL time.Duration
+	// Urgency is very-low, low, normal or high, empty leaves it to the push service.
+	Urgency string
+	// Topic replaces an undelivered message with the same topic.
+	Topic string
+}
+
+type Sender struct {
+	vapid  *vapid
+	client *http.Client
+}
+
+// NewSender signs requests with the VAPID keys, subject is a mailto: or https: contact url.
+// Endpoints are user input, connections to addresses that are not public are refused unless allowLocal.
+func NewSender(keys Keys, subject string, allowLocal bool) (*Sender, error) {
+	vapid, err := newVAPID(keys, subject)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: time.Second * 10}
+	if !allowLocal {
+		// checked on the resolved address, so names pointing inside the network are refused too
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !isPublic(addrPort.Addr()) {
+				return fmt.Errorf("push endpoint address %s is not public", addrPort.Addr())
+			}
+			return nil
+		}
+	}
+	return &Sender{
+		vapid: vapid,
+		client: &http.Client{
+			Timeout: time.Second * 30,
+			// no proxy, the connection has to go to the address that was checked
+			Transport: &http.Transport{
+				DialContext:         dialer.DialContext,
+				ForceAttemptHTTP2:   true,
+				MaxIdleConns:        100,
+				IdleConnTimeout:     90 * time.Second,
+				TLSHandshakeTimeout: 10 * time.Second,
+			},
+		},
+	}, nil
+}
+
+// PublicKey is the applicationServerKey browsers subscribe with.
+func (s *Sender) PublicKey() string {
+	return s.vapid.publicKey
+}
+
+// Send encrypts payload for the subscription and delivers it to its push service.
+func (s *Sender) Send(ctx context.Context, sub Subscription, payload []byte, opts Options) error {
+	userPublicKey, err := decode(sub.P256dh)
+	if err != nil {
+		return fmt.Errorf("decode p256dh: %w", err)
+	}
+	authSecret, err := decode(sub.Auth)
+	if err != nil {
+		return fmt.Errorf("decode auth: %w", err)
+	}
+	body, err := encrypt(payload, userPublicKey, authSecret)
+	if err != nil {
+		return fmt.Errorf("encrypt: %w", err)
+	}
+	authorization, err := s.vapid.authorization(sub.Endpoint, time.Now())
+	if err != nil {
+		return fmt.Errorf("vapid: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int(opts.TTL.Seconds())))
+	if opts.Urgency != "" {
+		req.Header.Set("Urgency", opts.Urgency)
+	}
+	if opts.Topic != "" {
+		req.Header.Set("Topic", opts.Topic)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ErrGone
+	case resp.StatusCode >= 300:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("push service: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// Validate checks the endpoint is an https url of a public host and the keys are a P-256 point
+// and a 16 byte secret. allowLocal also accepts http and local hosts, for testing with a local push service.
+func (sub Subscription) Validate(allowLocal bool) error {
+	u, err := url.Parse(sub.Endpoint)
+	if err != nil || u.Hostname() == "" {
+		return errors.New("invalid endpoint")
+	}
+	if u.Scheme != "https" && !(allowLocal && u.Scheme == "http") {
+		return errors.New("endpoint must use https")
+	}
+	if !allowLocal {
+		host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+		if addr, err := netip.ParseAddr(host); err == nil && !isPublic(addr) {
+			return errors.New("endpoint must be a public host")
+		}
+		if host == "localhost" || strings.HasSuffix(host, ".localhost") || !strings.Contains(host, ".") {
+			return errors.New("endpoint must be a public host")
+		}
+	}
+	userPublicKey, err := decode(sub.P256dh)
+	if err != nil {
+		return errors.New("invalid p256dh key")
+	}
+	if _, err := ecdh.P256().NewPublicKey(userPublicKey); err != nil {
+		return errors.New("invalid p256dh key")
+	}
+	if authSecret, err := decode(sub.Auth); err != nil || len(authSecret) != 16 {
+		return errors.New("invalid auth secret")
+	}
+	return nil
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, it is not routed on the internet either.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// isPublic reports whether addr can be a host on the internet.
+func isPublic(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !sharedAddressSpace.Contains(addr)
+}
@@ -0,0 +1,122 @@
+package webpush
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func newTestSubscription(t *testing.T, endpoint string) Subscription {
+	t.Helper()
+	userKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Subscription{
+		Endpoint: endpoint,
+		P256dh:   encode(userKey.PublicKey().Bytes()),
+		Auth:     encode(make([]byte, 16)),
+	}
+}
+
+func TestSubscriptionValidateEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint   string
+		valid      bool
+		validLocal bool
+	}{
+		{"https://fcm.googleapis.com/fcm/send/abc", true, true},
+		{"https://updates.push.services.mozilla.com/wpush/v2/abc", true, true},
+		{"https://8.8.8.8/push", true, true},
+		{"http://push.example.com/abc", false, true},
+		{"https://localhost/push", false, true},
+		{"https://push.localhost/push", false, true},
+		{"https://intranet/push", false, true},
+		{"https://127.0.0.1:8080/push", false, true},
+		{"https://10.0.0.1/push", false, true},
+		{"https://192.168.1.1/push", false, true},
+		{"https://100.64.0.1/push", false, true},
+		{"https://169.254.169.254/latest/meta-data", false, true},
+		{"https://[::1]/push", false, true},
+		{"https://[fd00::1]/push", false, true},
+		{"https://[fe80::1]/push", false, true},
+		{"https://[::ffff:127.0.0.1]/push", false, true},
+		{"https://0.0.0.0/push", false, true},
+		{"ftp://push.example.com/abc", false, false},
+		{"/relative", false, false},
+	}
+	for _, test := range tests {
+		sub := newTestSubscription(t, test.endpoint)
+		if err := sub.Validate(false); (err == nil) != test.valid {
+			t.Errorf("Validate(%s) = %v, want valid %v", test.endpoint, err, test.valid)
+		}
+		if err := sub.Validate(true); (err == nil) != test.validLocal {
+			t.Errorf("Validate(%s) allowing local = %v, want valid %v", test.endpoint, err, test.validLocal)
+		}
+	}
+}
+
+func TestIsPublic(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"8.8.8.8":         true,
+		"2001:4860::8888": true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.0.1":     false,
+		"100.100.0.1":     false,
+		"169.254.169.254": false,
+		"0.0.0.0":         false,
+		"224.0.0.1":       false,
+		"::1":             false,
+		"fd00::1":         false,
+		"fe80::1":         false,
+		"::ffff:10.0.0.1": false,
+	} {
+		if got := isPublic(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("isPublic(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestSenderRefusesLocalAddresses(t *testing.T) {
+	received := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received++
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+	keys, err := GenerateKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Send checks the address it dials, a public name may still resolve to a local address
+	sub := newTestSubscription(t, server.URL)
+
+	sender, err := NewSender(keys, "mailto:admin@example.com", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sender.Send(context.Background(), sub, []byte("hi"), Options{TTL: time.Minute}); err == nil {
+		t.Error("sent to a loopback address")
+	}
+	if received != 0 {
+		t.Errorf("push service received %d requests", received)
+	}
+
+	local, err := NewSender(keys, "mailto:admin@example.com", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := local.Send(context.Background(), sub, []byte("hi"), Options{TTL: time.Minute}); err != nil {
+		t.Errorf("send allowing local addresses: %v", err)
+	}
+	if received != 1 {
+		t.Errorf("push service received %d requests, want 1", received)
+	}
+}
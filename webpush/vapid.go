@@ -0,0 +1,104 @@
+package webpush
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/url"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// vapidTokenTTL is how long the signed token sent to push services stays valid, at most 24h (RFC 8292).
+const vapidTokenTTL = time.Hour * 12
+
+// Keys is a VAPID key pair, both encoded as unpadded base64url. PublicKey is the uncompressed
+// P-256 point given to browsers as applicationServerKey, PrivateKey the raw 32 byte scalar.
+type Keys struct {
+	PublicKey  string
+	PrivateKey string
+}
+
+// GenerateKeys creates a new VAPID key pair.
+func GenerateKeys() (Keys, error) {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return Keys{}, err
+	}
+	return Keys{
+		PublicKey:  encode(key.PublicKey().Bytes()),
+		PrivateKey: encode(key.Bytes()),
+	}, nil
+}
+
+type vapid struct {
+	publicKey  string
+	privateKey *ecdsa.PrivateKey
+	// subject is a contact for the push service, a mailto: or https: url.
+	subject string
+}
+
+func newVAPID(keys Keys, subject string) (*vapid, error) {
+	d, err := decode(keys.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("decode private key: %w", err)
+	}
+	key, err := ecdh.P256().NewPrivateKey(d)
+	if err != nil {
+		return nil, fmt.Errorf("parse private key: %w", err)
+	}
+	public := key.PublicKey().Bytes()
+	if keys.PublicKey != "" && keys.PublicKey != encode(public) {
+		return nil, fmt.Errorf("public key does not match the private key")
+	}
+	return &vapid{
+		publicKey: encode(public),
+		privateKey: &ecdsa.PrivateKey{
+			PublicKey: ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(public[1:33]),
+				Y:     new(big.Int).SetBytes(public[33:]),
+			},
+			D: new(big.Int).SetBytes(d),
+		},
+		subject: subject,
+	}, nil
+}
+
+// authorization returns the Authorization header for a request to endpoint (RFC 8292 section 3).
+func (v *vapid) authorization(endpoint string, now time.Time) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	claims := jwt.MapClaims{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": now.Add(vapidTokenTTL).Unix(),
+	}
+	if v.subject != "" {
+		claims["sub"] = v.subject
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodES256, claims).SignedString(v.privateKey)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("vapid t=%s, k=%s", token, v.publicKey), nil
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decode accepts base64url with or without padding, as browsers and libraries differ.
+func decode(s string) ([]byte, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return base64.URLEncoding.DecodeString(s)
+	}
+	return b, nil
+}